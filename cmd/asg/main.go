@@ -1,23 +1,56 @@
 package main
 
 import (
+    "flag"
     "fmt"
     "log"
     "net/http"
     "os"
+    "strconv"
+    "time"
 
     "installforge/internal/api"
     "installforge/internal/store"
+    "installforge/templates"
     "installforge/webembed"
 )
 
 func main() {
+    dataRoot := "data/projects"
+    st := store.New(dataRoot)
+    st.Templates = templates.Catalog
+    // ASSET_QUOTA_MB caps each project's assets and open uploads; 0 disables the limit.
+    st.Quota = 10 << 30
+    if v := os.Getenv("ASSET_QUOTA_MB"); v != "" {
+        mb, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || mb < 0 {
+            log.Fatalf("invalid ASSET_QUOTA_MB %q", v)
+        }
+        st.Quota = mb << 20
+    }
+    // IMPORT_ROOT is where bundles imported by path must lie; default data/import.
+    if v, ok := os.LookupEnv("IMPORT_ROOT"); ok {
+        st.Imports = v
+    }
+
+    if len(os.Args) > 1 && os.Args[1] == "migrate" {
+        runMigrate(st, os.Args[2:])
+        return
+    }
+    if len(os.Args) > 1 && os.Args[1] == "gc" {
+        runGC(st, os.Args[2:])
+        return
+    }
+
+    // assets stored before the blob store move once here, not on reads
+    if err := st.MigrateAssets(); err != nil {
+        log.Fatalf("migrate assets: %v", err)
+    }
+
     port := os.Getenv("PORT")
     if port == "" {
         port = "8080"
     }
-    dataRoot := "data/projects"
-    st := store.New(dataRoot)
 
     mux := http.NewServeMux()
     api.RegisterRoutes(mux, st)
@@ -29,3 +62,65 @@ func main() {
     log.Printf("InstallForge listening on http://%s", addr)
     log.Fatal(http.ListenAndServe(addr, mux))
 }
+
+// runMigrate upgrades all recipes under the data root, or reports what would change with -dry-run.
+func runMigrate(st *store.Store, args []string) {
+    fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+    dryRun := fs.Bool("dry-run", false, "report migrations without writing")
+    fs.Parse(args)
+
+    results, err := st.MigrateAll(*dryRun)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if !*dryRun {
+        if err := st.MigrateAssets(); err != nil {
+            log.Fatal(err)
+        }
+    }
+    failed := false
+    for _, pm := range results {
+        switch {
+        case pm.Error != "":
+            failed = true
+            fmt.Printf("%s %s: error: %s\n", pm.ID, pm.Name, pm.Error)
+        case !pm.Changed():
+            fmt.Printf("%s %s: up to date (%s)\n", pm.ID, pm.Name, pm.To)
+        default:
+            fmt.Printf("%s %s: %s -> %s\n", pm.ID, pm.Name, pm.From, pm.To)
+            for _, step := range pm.Applied {
+                fmt.Printf("    %s\n", step)
+            }
+        }
+    }
+    if *dryRun {
+        fmt.Println("dry run: no recipes were written")
+    }
+    if failed {
+        os.Exit(1)
+    }
+}
+
+// runGC removes asset blobs no project references any more, or lists them with -dry-run.
+func runGC(st *store.Store, args []string) {
+    fs := flag.NewFlagSet("gc", flag.ExitOnError)
+    dryRun := fs.Bool("dry-run", false, "list orphaned blobs without removing them")
+    fs.DurationVar(&st.UploadTTL, "upload-ttl", 7*24*time.Hour, "drop upload sessions idle for longer than this; 0 keeps them")
+    fs.Parse(args)
+
+    rep, err := st.GC(*dryRun)
+    if err != nil {
+        log.Fatal(err)
+    }
+    for _, u := range rep.Uploads {
+        fmt.Printf("expired upload %s\n", u)
+    }
+    for _, sum := range rep.Removed {
+        fmt.Printf("orphaned %s\n", sum)
+    }
+    verb := "removed"
+    if *dryRun {
+        verb = "would remove"
+    }
+    fmt.Printf("%d blobs in use, %s %d (%d bytes) and %d idle uploads, %d reference counts corrected\n", rep.Blobs, verb, len(rep.Removed), rep.Freed, len(rep.Uploads), rep.Corrected)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+
+    "installforge/internal/inspect"
+    "installforge/internal/recipe"
+    "installforge/internal/store"
+)
+
+// renameRequest is the body of POST /assets/{name}/rename. With UpdateSteps
+// the recipe's references are rewritten and saved as a new revision.
+type renameRequest struct {
+    Filename    string `json:"filename"`
+    UpdateSteps bool   `json:"updateSteps"`
+    store.Commit
+}
+
+// assetRoutes serves /api/projects/{id}/assets/{name}:
+//
+//  GET    /assets/{name}           download the asset
+//  DELETE /assets/{name}           delete it; 409 while steps reference it unless ?force=true
+//  POST   /assets/{name}/rename    rename it
+//  GET    /assets/{name}/contents  archive listing or RPM header
+func assetRoutes(st *store.Store, id string, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        name := parts[0]
+        switch {
+        case len(parts) == 1 && r.Method == http.MethodGet:
+            downloadAsset(st, id, name)(w, r)
+        case len(parts) == 1 && r.Method == http.MethodDelete:
+            deleteAsset(st, id, name)(w, r)
+        case len(parts) == 2 && parts[1] == "rename" && r.Method == http.MethodPost:
+            renameAsset(st, id, name)(w, r)
+        case len(parts) == 2 && parts[1] == "contents" && r.Method == http.MethodGet:
+            res, err := st.InspectAsset(id, name)
+            writeInspection(w, res, err)
+        case len(parts) <= 2:
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }
+}
+
+func downloadAsset(st *store.Store, id, name string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        meta, err := st.AssetMeta(id, name)
+        if err != nil {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        f, err := st.OpenAsset(id, name)
+        if err != nil {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        defer f.Close()
+        w.Header().Set("Content-Type", meta.MIMEType)
+        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+        w.Header().Set("ETag", `"`+meta.SHA256+`"`)
+        http.ServeContent(w, r, name, meta.UploadedAt, f)
+    }
+}
+
+func deleteAsset(st *store.Store, id, name string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        meta, err := st.AssetMeta(id, name)
+        if err != nil {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        if len(meta.ReferencedBy) > 0 && r.URL.Query().Get("force") != "true" {
+            writeJSON(w, http.StatusConflict, map[string]interface{}{
+                "error":        fmt.Sprintf("asset %s is referenced by steps; delete with ?force=true to keep the references", name),
+                "referencedBy": meta.ReferencedBy,
+            })
+            return
+        }
+        if err := st.DeleteAsset(id, name); err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+    }
+}
+
+func renameAsset(st *store.Store, id, name string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req renameRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+            return
+        }
+        // rewriting the steps saves a revision, so it needs If-Match like any save
+        var rec recipe.Recipe
+        if req.UpdateSteps {
+            base, ok, err := ifMatchRevision(r)
+            if !ok {
+                writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match header is required with updateSteps; send the ETag from GET /api/projects/" + id})
+                return
+            }
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+                return
+            }
+            if rec, err = st.LoadRecipe(id); err != nil {
+                writeLoadError(w, err)
+                return
+            }
+            if base != nil && *base != rec.Revision {
+                writeConflict(w, st, id, rec, &store.ConflictError{Base: *base, Current: rec.Revision})
+                return
+            }
+        }
+        meta, err := st.RenameAsset(id, name, req.Filename)
+        switch {
+        case errors.Is(err, store.ErrAssetExists):
+            writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+            return
+        case errors.Is(err, os.ErrNotExist):
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        case err != nil:
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
+        res := map[string]interface{}{"asset": meta}
+        if req.UpdateSteps {
+            updated, steps, err := recipe.RenameAssetRefs(rec, name, req.Filename)
+            if err == nil && len(steps) > 0 {
+                if req.Message == "" {
+                    req.Message = fmt.Sprintf("rename asset %s to %s", name, req.Filename)
+                }
+                base := rec.Revision
+                req.BaseRevision = &base
+                updated.Project.ID = id
+                rec, err = st.SaveRecipe(updated, req.Commit)
+            }
+            if err != nil {
+                // the steps still name the old file, so the file keeps it too
+                if _, rerr := st.RenameAsset(id, req.Filename, name); rerr != nil {
+                    err = fmt.Errorf("%v; renaming the asset back failed: %v", err, rerr)
+                }
+                var conflict *store.ConflictError
+                if errors.As(err, &conflict) {
+                    writeConflict(w, st, id, updated, conflict)
+                    return
+                }
+                writeLoadError(w, err)
+                return
+            }
+            if len(steps) > 0 {
+                w.Header().Set("ETag", etag(rec))
+                res["recipe"] = rec
+            }
+            res["updatedSteps"] = steps
+        }
+        writeJSON(w, http.StatusOK, res)
+    }
+}
+
+// writeInspection answers a contents request: 415 for files that are not
+// archives or RPMs, 422 for ones that cannot be read as such.
+func writeInspection(w http.ResponseWriter, res inspect.Result, err error) {
+    switch {
+    case errors.Is(err, os.ErrNotExist):
+        writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+    case errors.Is(err, inspect.ErrUnsupported):
+        writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+    case err != nil:
+        writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+    default:
+        writeJSON(w, http.StatusOK, res)
+    }
+}
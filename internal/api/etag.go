@@ -0,0 +1,53 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "installforge/internal/recipe"
+    "installforge/internal/store"
+)
+
+// etag derives a recipe's entity tag from its revision.
+func etag(rec recipe.Recipe) string {
+    return fmt.Sprintf(`"%d"`, rec.Revision)
+}
+
+// ifMatchRevision reads the revision the client edited from If-Match. It
+// reports ok=false when the header is missing and base=nil for "*".
+func ifMatchRevision(r *http.Request) (base *int, ok bool, err error) {
+    v := strings.TrimSpace(r.Header.Get("If-Match"))
+    if v == "" {
+        return nil, false, nil
+    }
+    if v == "*" {
+        return nil, true, nil
+    }
+    n, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(v, "W/"), `"`))
+    if err != nil {
+        return nil, true, fmt.Errorf("If-Match must be an ETag returned by GET")
+    }
+    return &n, true, nil
+}
+
+// writeConflict answers a stale save with the current recipe and what changed
+// on the server since the client's base revision.
+func writeConflict(w http.ResponseWriter, st *store.Store, id string, submitted recipe.Recipe, conflict *store.ConflictError) {
+    current, err := st.LoadRecipe(id)
+    if err != nil {
+        writeLoadError(w, err)
+        return
+    }
+    base := submitted
+    if _, rec, err := st.LoadRevision(id, conflict.Base); err == nil {
+        base = rec
+    }
+    w.Header().Set("ETag", etag(current))
+    writeJSON(w, http.StatusConflict, map[string]interface{}{
+        "error":  conflict.Error(),
+        "recipe": current,
+        "diff":   recipe.Diff(base, current),
+    })
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+    "os"
+    "path/filepath"
+
+    "installforge/internal/recipe"
+    "installforge/internal/render"
+    "installforge/internal/store"
+)
+
+// exportRequest is the body of POST /api/projects/{id}/export.
+type exportRequest struct {
+    Format string `json:"format"`
+    render.DockerOptions
+}
+
+// exporter writes one export format into a fresh target directory. rec is
+// the recipe as stored; opts are its validation options, which the scripts
+// use to install bundled RPMs in dependency order.
+type exporter struct {
+    prefix string
+    write  func(st *store.Store, rec recipe.Recipe, opts recipe.Options, req exportRequest, target string) ([]string, error)
+}
+
+var exporters = map[string]exporter{
+    "dir":        {prefix: "bundle", write: writeDirBundle},
+    "docker":     {prefix: "docker", write: writeDockerContext},
+    "rpm":        {prefix: "rpm", write: writeRPMSources},
+    "kickstart":  {prefix: "kickstart", write: writeKickstart},
+    "cloud-init": {prefix: "cloudinit", write: writeCloudInit},
+}
+
+// writeDirBundle writes install.sh, uninstall.sh, README.txt, recipe.json and assets/.
+func writeDirBundle(st *store.Store, rec recipe.Recipe, opts recipe.Options, req exportRequest, target string) ([]string, error) {
+    if err := st.WriteBundle(rec, target); err != nil {
+        return nil, err
+    }
+    renderRes, err := render.Render(rec, opts)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "install.sh"), []byte(renderRes.InstallSh), 0o755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "uninstall.sh"), []byte(renderRes.UninstallSh), 0o755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "README.txt"), []byte(renderRes.Readme), 0o644); err != nil {
+        return nil, err
+    }
+    return nil, nil
+}
+
+// writeDockerContext writes a Dockerfile build context: the bundle plus Dockerfile and helper scripts.
+func writeDockerContext(st *store.Store, rec recipe.Recipe, opts recipe.Options, req exportRequest, target string) ([]string, error) {
+    res, err := render.Dockerfile(recipe.OrderRPMs(rec, opts), req.DockerOptions)
+    if err != nil {
+        return nil, err
+    }
+    if err := st.WriteBundle(rec, target); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "Dockerfile"), []byte(res.Dockerfile), 0o644); err != nil {
+        return nil, err
+    }
+    for name, content := range res.Files {
+        path := filepath.Join(target, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            return nil, err
+        }
+        if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+            return nil, err
+        }
+    }
+    return res.Warnings, nil
+}
+
+// writeRPMSources writes SPECS/<name>.spec and SOURCES/<name>-<version>.tar.gz
+// holding the dir bundle, and DEPS/ with the bundled RPMs the spec requires.
+func writeRPMSources(st *store.Store, rec recipe.Recipe, opts recipe.Options, req exportRequest, target string) ([]string, error) {
+    res, err := render.RPMSpec(rec, opts)
+    if err != nil {
+        return nil, err
+    }
+    staging, err := os.MkdirTemp("", "installforge-rpm-")
+    if err != nil {
+        return nil, err
+    }
+    defer os.RemoveAll(staging)
+    if _, err := writeDirBundle(st, rec, opts, req, staging); err != nil {
+        return nil, err
+    }
+    if err := store.WriteTarGz(staging, res.SourceDir, filepath.Join(target, "SOURCES", res.SourceFile)); err != nil {
+        return nil, err
+    }
+    for _, f := range res.Packages {
+        if err := os.MkdirAll(filepath.Join(target, "DEPS"), 0o755); err != nil {
+            return nil, err
+        }
+        if err := store.LinkFile(filepath.Join(staging, "assets", f), filepath.Join(target, "DEPS", f)); err != nil {
+            return nil, err
+        }
+    }
+    if err := os.MkdirAll(filepath.Join(target, "SPECS"), 0o755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "SPECS", res.Name+".spec"), []byte(res.Spec), 0o644); err != nil {
+        return nil, err
+    }
+    return res.Warnings, nil
+}
+
+// writeKickstart writes ks-post.cfg, a kickstart %post section running install.sh.
+func writeKickstart(st *store.Store, rec recipe.Recipe, opts recipe.Options, req exportRequest, target string) ([]string, error) {
+    assets, err := inlineAssets(st, rec)
+    if err != nil {
+        return nil, err
+    }
+    res, err := render.Kickstart(recipe.OrderRPMs(rec, opts), assets)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.MkdirAll(target, 0o755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "ks-post.cfg"), []byte(res.Document), 0o644); err != nil {
+        return nil, err
+    }
+    return res.Warnings, nil
+}
+
+// writeCloudInit writes a cloud-init user-data document running install.sh.
+func writeCloudInit(st *store.Store, rec recipe.Recipe, opts recipe.Options, req exportRequest, target string) ([]string, error) {
+    assets, err := inlineAssets(st, rec)
+    if err != nil {
+        return nil, err
+    }
+    res, err := render.CloudInit(recipe.OrderRPMs(rec, opts), assets)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.MkdirAll(target, 0o755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(target, "user-data"), []byte(res.Document), 0o644); err != nil {
+        return nil, err
+    }
+    return res.Warnings, nil
+}
+
+// inlineAssets loads bundle assets small enough to embed into provisioning documents.
+func inlineAssets(st *store.Store, rec recipe.Recipe) ([]render.InlineAsset, error) {
+    metas, err := st.BundleAssets(rec)
+    if err != nil {
+        return nil, err
+    }
+    var res []render.InlineAsset
+    for _, m := range metas {
+        a := render.InlineAsset{Name: m.Filename, Size: m.Size}
+        if a.Size <= render.InlineLimit {
+            if a.Data, err = st.ReadBlob(m.SHA256); err != nil {
+                return nil, err
+            }
+        }
+        res = append(res, a)
+    }
+    return res, nil
+}
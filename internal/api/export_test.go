@@ -0,0 +1,65 @@
+package api
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "installforge/internal/inspect"
+    "installforge/internal/recipe"
+    "installforge/internal/store"
+)
+
+func TestWriteDirBundleKeepsRecipe(t *testing.T) {
+    st := store.New(filepath.Join(t.TempDir(), "projects"))
+    rec, err := st.CreateProject("demo", "", []string{"oracle_linux_6_9"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, name := range []string{"app.rpm", "lib.rpm"} {
+        if _, err := st.SaveAsset(rec.Project.ID, name, "test", strings.NewReader(name)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    rec.Steps = []recipe.Step{{ID: "s1", Name: "rpms", Type: "rpm_install", Config: map[string]interface{}{"rpms": []interface{}{"assets/*.rpm"}, "mode": "install"}}}
+    if rec, err = st.SaveRecipe(rec, store.Commit{}); err != nil {
+        t.Fatal(err)
+    }
+    opts := recipe.Options{
+        Assets: []string{"app.rpm", "lib.rpm"},
+        RPM: func(name string) (inspect.RPMInfo, bool) {
+            info := inspect.RPMInfo{Name: strings.TrimSuffix(name, ".rpm"), Version: "1.0", Release: "1"}
+            if name == "app.rpm" {
+                info.Requires = []string{"lib"}
+            }
+            return info, true
+        },
+    }
+
+    target := t.TempDir()
+    if _, err := writeDirBundle(st, rec, opts, exportRequest{}, target); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(filepath.Join(target, "recipe.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var exported recipe.Recipe
+    if err := json.Unmarshal(data, &exported); err != nil {
+        t.Fatal(err)
+    }
+    if got := exported.Steps[0].Config["rpms"]; !reflect.DeepEqual(got, []interface{}{"assets/*.rpm"}) {
+        t.Errorf("exported rpms = %v, want the glob as saved", got)
+    }
+    install, err := os.ReadFile(filepath.Join(target, "install.sh"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    lib, app := strings.Index(string(install), "lib.rpm"), strings.Index(string(install), "app.rpm")
+    if lib < 0 || app < 0 || lib > app {
+        t.Errorf("install.sh does not install lib.rpm before app.rpm:\n%s", install)
+    }
+}
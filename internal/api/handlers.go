@@ -2,10 +2,12 @@ package api
 
 import (
     "encoding/json"
+    "errors"
     "fmt"
+    "io"
     "net/http"
     "os"
-    "path/filepath"
+    "strconv"
     "strings"
 
     "installforge/internal/recipe"
@@ -26,6 +28,35 @@ func RegisterRoutes(mux *http.ServeMux, st *store.Store) {
         }
     })
 
+    mux.HandleFunc("/api/validate", validateRecipe)
+    mux.HandleFunc("/api/preview", previewRecipe)
+
+    mux.HandleFunc("/api/rules", func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            w.WriteHeader(http.StatusMethodNotAllowed)
+            return
+        }
+        writeJSON(w, http.StatusOK, recipe.Rules)
+    })
+
+    mux.HandleFunc("/api/trash", trashRoutes(st, nil))
+    mux.HandleFunc("/api/trash/", func(w http.ResponseWriter, r *http.Request) {
+        rest := strings.TrimPrefix(r.URL.Path, "/api/trash/")
+        trashRoutes(st, strings.Split(rest, "/"))(w, r)
+    })
+
+    mux.HandleFunc("/api/templates", templateRoutes(st, nil))
+    mux.HandleFunc("/api/templates/", func(w http.ResponseWriter, r *http.Request) {
+        rest := strings.TrimPrefix(r.URL.Path, "/api/templates/")
+        templateRoutes(st, strings.Split(rest, "/"))(w, r)
+    })
+
+    mux.HandleFunc("/api/library", libraryRoutes(st, nil))
+    mux.HandleFunc("/api/library/", func(w http.ResponseWriter, r *http.Request) {
+        rest := strings.TrimPrefix(r.URL.Path, "/api/library/")
+        libraryRoutes(st, strings.Split(rest, "/"))(w, r)
+    })
+
     mux.HandleFunc("/api/projects/", func(w http.ResponseWriter, r *http.Request) {
         rest := strings.TrimPrefix(r.URL.Path, "/api/projects/")
         parts := strings.Split(rest, "/")
@@ -34,12 +65,24 @@ func RegisterRoutes(mux *http.ServeMux, st *store.Store) {
             return
         }
         id := parts[0]
+        if id == "import" && len(parts) == 1 {
+            if r.Method == http.MethodPost {
+                importProject(st)(w, r)
+            } else {
+                w.WriteHeader(http.StatusMethodNotAllowed)
+            }
+            return
+        }
         if len(parts) == 1 {
             switch r.Method {
             case http.MethodGet:
                 getProject(st, id)(w, r)
             case http.MethodPut:
                 saveProject(st, id)(w, r)
+            case http.MethodPatch:
+                patchProject(st, id)(w, r)
+            case http.MethodDelete:
+                deleteProject(st, id)(w, r)
             default:
                 w.WriteHeader(http.StatusMethodNotAllowed)
             }
@@ -48,13 +91,23 @@ func RegisterRoutes(mux *http.ServeMux, st *store.Store) {
         if len(parts) >= 2 {
             switch parts[1] {
             case "assets":
-                if r.Method == http.MethodGet {
+                if len(parts) > 2 && parts[2] != "" {
+                    assetRoutes(st, id, parts[2:])(w, r)
+                } else if r.Method == http.MethodGet {
                     listAssets(st, id)(w, r)
                 } else if r.Method == http.MethodPost {
                     uploadAssets(st, id)(w, r)
                 } else {
                     w.WriteHeader(http.StatusMethodNotAllowed)
                 }
+            case "uploads":
+                uploadRoutes(st, id, parts[2:])(w, r)
+            case "clone", "rename", "archive", "unarchive":
+                if len(parts) == 2 {
+                    projectActions(st, id, parts[1])(w, r)
+                } else {
+                    w.WriteHeader(http.StatusNotFound)
+                }
             case "generate":
                 if r.Method == http.MethodPost {
                     generatePreview(st, id)(w, r)
@@ -67,6 +120,24 @@ func RegisterRoutes(mux *http.ServeMux, st *store.Store) {
                 } else {
                     w.WriteHeader(http.StatusMethodNotAllowed)
                 }
+            case "steps":
+                stepRoutes(st, id, parts[2:])(w, r)
+            case "revisions":
+                revisionRoutes(st, id, parts[2:])(w, r)
+            case "fixes":
+                if len(parts) != 3 || parts[2] == "" {
+                    w.WriteHeader(http.StatusNotFound)
+                } else if r.Method == http.MethodPost {
+                    applyFix(st, id, parts[2])(w, r)
+                } else {
+                    w.WriteHeader(http.StatusMethodNotAllowed)
+                }
+            case "diff":
+                if r.Method == http.MethodGet {
+                    diffRevisions(st, id)(w, r)
+                } else {
+                    w.WriteHeader(http.StatusMethodNotAllowed)
+                }
             default:
                 w.WriteHeader(http.StatusNotFound)
             }
@@ -80,22 +151,75 @@ func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
     _ = json.NewEncoder(w).Encode(payload)
 }
 
+// writeLoadError answers a project that could not be loaded or saved: 404
+// when the project does not exist, 422 with the migration error when its
+// schema is newer or unknown.
+func writeLoadError(w http.ResponseWriter, err error) {
+    var schemaErr *recipe.SchemaError
+    switch {
+    case errors.As(err, &schemaErr):
+        writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+    case errors.Is(err, os.ErrNotExist):
+        writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+    default:
+        writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+    }
+}
+
+// Paged project listings return defaultProjectLimit projects per page
+// unless ?limit= asks for up to maxProjectLimit.
+const (
+    defaultProjectLimit = 50
+    maxProjectLimit     = 500
+)
+
+// listProjects returns projects from the project index, filtered by ?q=
+// and ?target=, sorted by ?sort=updated (default) or name; archived ones
+// are included with ?archived=true. Without ?limit= or ?cursor= it answers
+// the bare array of every match, as before paging existed; with either it
+// answers a page that continues after ?cursor=.
 func listProjects(st *store.Store) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        projects, err := st.ListProjects()
+        v := r.URL.Query()
+        q := store.ProjectQuery{Q: v.Get("q"), Target: v.Get("target"), Archived: v.Get("archived") == "true", Sort: v.Get("sort"), Cursor: v.Get("cursor")}
+        paged := v.Has("limit") || v.Has("cursor")
+        if paged {
+            q.Limit = defaultProjectLimit
+        }
+        if raw := v.Get("limit"); raw != "" {
+            n, err := strconv.Atoi(raw)
+            if err != nil || n < 1 || n > maxProjectLimit {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxProjectLimit)})
+                return
+            }
+            q.Limit = n
+        }
+        page, err := st.ListProjects(q)
+        if errors.Is(err, store.ErrInvalidQuery) {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
         if err != nil {
             writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
         }
-        writeJSON(w, http.StatusOK, projects)
+        if !paged {
+            writeJSON(w, http.StatusOK, page.Projects)
+            return
+        }
+        writeJSON(w, http.StatusOK, page)
     }
 }
 
+// createProject creates a project with an empty recipe, or from the recipe
+// of the template named by "template"; name, description and target
+// override the template's when given.
 func createProject(st *store.Store) http.HandlerFunc {
     type req struct {
         Name        string   `json:"name"`
         Description string   `json:"description"`
         Target      []string `json:"target"`
+        Template    string   `json:"template"`
     }
     return func(w http.ResponseWriter, r *http.Request) {
         var body req
@@ -103,7 +227,17 @@ func createProject(st *store.Store) http.HandlerFunc {
             writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
             return
         }
-        rec, err := st.CreateProject(body.Name, body.Description, body.Target)
+        var rec recipe.Recipe
+        var err error
+        if body.Template != "" {
+            rec, err = st.CreateProjectFromTemplate(body.Template, body.Name, body.Description, body.Target)
+            if errors.Is(err, os.ErrNotExist) {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown template %s", body.Template)})
+                return
+            }
+        } else {
+            rec, err = st.CreateProject(body.Name, body.Description, body.Target)
+        }
         if err != nil {
             writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
@@ -116,69 +250,135 @@ func getProject(st *store.Store, id string) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         rec, err := st.LoadRecipe(id)
         if err != nil {
-            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            writeLoadError(w, err)
             return
         }
+        w.Header().Set("ETag", etag(rec))
         writeJSON(w, http.StatusOK, rec)
     }
 }
 
+// saveProject stores the recipe body as a new revision, migrating older schemas.
+// Optional top-level "author" and "message" fields describe the revision.
+// If-Match must carry the ETag of the revision the client edited.
 func saveProject(st *store.Store, id string) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        var rec recipe.Recipe
-        if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+        base, ok, err := ifMatchRevision(r)
+        if !ok {
+            writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match header is required; send the ETag from GET /api/projects/" + id})
+            return
+        }
+        if err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
+        data, err := io.ReadAll(r.Body)
+        if err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
+        rec, _, err := recipe.Migrate(data)
+        if err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
+        var commit store.Commit
+        if err := json.Unmarshal(data, &commit); err != nil {
             writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
             return
         }
         rec.Project.ID = id
-        if err := st.SaveRecipe(rec); err != nil {
-            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+        commit.BaseRevision = base
+        saved, err := st.SaveRecipe(rec, commit)
+        var conflict *store.ConflictError
+        if errors.As(err, &conflict) {
+            writeConflict(w, st, id, rec, conflict)
+            return
+        }
+        if err != nil {
+            writeLoadError(w, err)
             return
         }
-        issues := recipe.Validate(rec)
-        writeJSON(w, http.StatusOK, map[string]interface{}{"recipe": rec, "issues": issues})
+        lint := lintProject(st, id, saved)
+        w.Header().Set("ETag", etag(saved))
+        writeJSON(w, http.StatusOK, map[string]interface{}{"recipe": saved, "issues": lint.Issues, "suppressed": lint.Suppressed})
     }
 }
 
+// lintProject lints rec against the project's assets, falling back to
+// recipe-only checks when they cannot be listed.
+func lintProject(st *store.Store, id string, rec recipe.Recipe) recipe.LintReport {
+    opts, _ := st.ValidationOptions(id, rec.Library)
+    return recipe.Lint(rec, opts)
+}
+
+// listAssets lists the project's uploaded assets followed by the library
+// files its recipe pins, which carry a "library" field.
 func listAssets(st *store.Store, id string) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        entries, err := st.AssetList(id)
+        rec, err := st.LoadRecipe(id)
+        if err != nil {
+            writeLoadError(w, err)
+            return
+        }
+        metas, err := st.AssetMetas(id)
         if err != nil {
             writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
             return
         }
-        var res []map[string]interface{}
-        for _, e := range entries {
-            info, _ := e.Info()
-            res = append(res, map[string]interface{}{
-                "filename": e.Name(),
-                "size":     info.Size(),
-            })
+        lib, err := st.LibraryAssets(rec)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
         }
-        writeJSON(w, http.StatusOK, res)
+        writeJSON(w, http.StatusOK, append(metas, lib...))
     }
 }
 
+// uploadAssets streams the multipart "files" into the store one part at a
+// time; an optional "uploader" field, sent before the files, is recorded in
+// their metadata.
 func uploadAssets(st *store.Store, id string) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        if err := r.ParseMultipartForm(32 << 20); err != nil {
+        mr, err := r.MultipartReader()
+        if err != nil {
             writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
             return
         }
-        files := r.MultipartForm.File["files"]
-        for _, fh := range files {
-            f, err := fh.Open()
+        uploader := ""
+        saved := []store.AssetMeta{}
+        for {
+            part, err := mr.NextPart()
+            if err == io.EOF {
+                break
+            }
             if err != nil {
-                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
                 return
             }
-            defer f.Close()
-            if err := st.SaveAsset(id, fh.Filename, f); err != nil {
-                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-                return
+            switch {
+            case part.FormName() == "uploader" && part.FileName() == "":
+                data, err := io.ReadAll(io.LimitReader(part, 1<<10))
+                if err != nil {
+                    writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+                    return
+                }
+                uploader = string(data)
+            case part.FormName() == "files" && part.FileName() != "":
+                meta, err := st.SaveAsset(id, part.FileName(), uploader, part)
+                if errors.Is(err, store.ErrQuotaExceeded) {
+                    writeJSON(w, http.StatusRequestEntityTooLarge, map[string]interface{}{"error": err.Error(), "assets": saved})
+                    return
+                }
+                if err != nil {
+                    writeLoadError(w, err)
+                    return
+                }
+                saved = append(saved, meta)
             }
+            part.Close()
         }
-        writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+        writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "assets": saved})
     }
 }
 
@@ -186,10 +386,15 @@ func generatePreview(st *store.Store, id string) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         rec, err := st.LoadRecipe(id)
         if err != nil {
-            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            writeLoadError(w, err)
+            return
+        }
+        opts, err := st.ValidationOptions(id, rec.Library)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
         }
-        renderRes, err := render.Render(rec)
+        renderRes, err := render.Render(rec, opts)
         if err != nil {
             writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
@@ -199,54 +404,227 @@ func generatePreview(st *store.Store, id string) http.HandlerFunc {
 }
 
 func exportBundle(st *store.Store, id string) http.HandlerFunc {
-    type req struct {
-        Format string `json:"format"`
-    }
     return func(w http.ResponseWriter, r *http.Request) {
-        var body req
+        var body exportRequest
         if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
             writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
             return
         }
+        if body.Format == "" {
+            body.Format = "dir"
+        }
+        exp, ok := exporters[body.Format]
+        if !ok {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported format %s", body.Format)})
+            return
+        }
         rec, err := st.LoadRecipe(id)
         if err != nil {
-            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            writeLoadError(w, err)
+            return
+        }
+        opts, err := st.ValidationOptions(id, rec.Library)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
         }
-        issues := recipe.Validate(rec)
+        lint := recipe.Lint(rec, opts)
         hasError := false
-        for _, is := range issues {
+        for _, is := range lint.Issues {
             if is.Level == "error" {
                 hasError = true
             }
         }
         if hasError {
-            writeJSON(w, http.StatusBadRequest, map[string]interface{}{"issues": issues})
+            writeJSON(w, http.StatusBadRequest, map[string]interface{}{"issues": lint.Issues, "suppressed": lint.Suppressed})
             return
         }
-        target := filepath.Join(os.TempDir(), fmt.Sprintf("bundle_%s", rec.Project.Name))
-        if err := os.RemoveAll(target); err != nil {
+        // a fresh directory per export, named by ID: project names are user input
+        target, err := os.MkdirTemp("", fmt.Sprintf("%s_%s-", exp.prefix, id))
+        if err != nil {
             writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
         }
-        if err := st.WriteBundle(rec, target); err != nil {
+        warnings, err := exp.write(st, rec, opts, body, target)
+        if err != nil {
+            os.RemoveAll(target)
             writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
             return
         }
-        renderRes, err := render.Render(rec)
+        res := map[string]interface{}{"path": target}
+        if len(warnings) > 0 {
+            res["warnings"] = warnings
+        }
+        writeJSON(w, http.StatusOK, res)
+    }
+}
+
+func revisionRoutes(st *store.Store, id string, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if len(parts) == 0 || parts[0] == "" {
+            if r.Method != http.MethodGet {
+                w.WriteHeader(http.StatusMethodNotAllowed)
+                return
+            }
+            revs, err := st.ListRevisions(id)
+            if err != nil {
+                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+                return
+            }
+            writeJSON(w, http.StatusOK, revs)
+            return
+        }
+        n, err := strconv.Atoi(parts[0])
         if err != nil {
-            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid revision"})
             return
         }
-        if err := os.WriteFile(filepath.Join(target, "install.sh"), []byte(renderRes.InstallSh), 0o755); err != nil {
-            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+        switch {
+        case len(parts) == 1 && r.Method == http.MethodGet:
+            rev, rec, err := st.LoadRevision(id, n)
+            if err != nil {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+                return
+            }
+            writeJSON(w, http.StatusOK, map[string]interface{}{"revision": rev, "recipe": rec})
+        case len(parts) == 2 && parts[1] == "restore" && r.Method == http.MethodPost:
+            base, ok, err := ifMatchRevision(r)
+            if !ok {
+                writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match header is required; send the ETag from GET /api/projects/" + id})
+                return
+            }
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+                return
+            }
+            var commit store.Commit
+            if r.ContentLength != 0 {
+                if err := json.NewDecoder(r.Body).Decode(&commit); err != nil {
+                    writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+                    return
+                }
+            }
+            _, old, err := st.LoadRevision(id, n)
+            if err != nil {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+                return
+            }
+            commit.BaseRevision = base
+            rec, err := st.RestoreRevision(id, n, commit)
+            var conflict *store.ConflictError
+            if errors.As(err, &conflict) {
+                writeConflict(w, st, id, old, conflict)
+                return
+            }
+            if err != nil {
+                writeLoadError(w, err)
+                return
+            }
+            lint := lintProject(st, id, rec)
+            w.Header().Set("ETag", etag(rec))
+            writeJSON(w, http.StatusOK, map[string]interface{}{"recipe": rec, "issues": lint.Issues, "suppressed": lint.Suppressed})
+        case len(parts) <= 2:
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }
+}
+
+// fixRequest selects one of an issue's fixes (default the first) and
+// optionally describes the revision it creates.
+type fixRequest struct {
+    Fix int `json:"fix"`
+    store.Commit
+}
+
+// applyFix applies a fix of the current recipe's issue and saves the result as a new revision.
+func applyFix(st *store.Store, id, issueID string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req fixRequest
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+                return
+            }
+        }
+        rec, err := st.LoadRecipe(id)
+        if err != nil {
+            writeLoadError(w, err)
             return
         }
-        if err := os.WriteFile(filepath.Join(target, "README.txt"), []byte(renderRes.Readme), 0o644); err != nil {
-            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+        issue, ok := lintProject(st, id, rec).FindIssue(issueID)
+        if !ok {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "issue not found"})
+            return
+        }
+        if req.Fix < 0 || req.Fix >= len(issue.Fixes) {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "issue has no such fix"})
+            return
+        }
+        fix := issue.Fixes[req.Fix]
+        // the fix was computed from this revision
+        base := rec.Revision
+        req.BaseRevision = &base
+        rec, err = recipe.ApplyPatch(rec, fix.Patch)
+        if err != nil {
+            writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+            return
+        }
+        if req.Message == "" {
+            req.Message = "apply fix: " + fix.Title
+        }
+        rec.Project.ID = id
+        rec, err = st.SaveRecipe(rec, req.Commit)
+        var conflict *store.ConflictError
+        if errors.As(err, &conflict) {
+            writeJSON(w, http.StatusConflict, map[string]string{"error": conflict.Error()})
+            return
+        }
+        if err != nil {
+            writeLoadError(w, err)
+            return
+        }
+        lint := lintProject(st, id, rec)
+        w.Header().Set("ETag", etag(rec))
+        writeJSON(w, http.StatusOK, map[string]interface{}{"recipe": rec, "issues": lint.Issues, "suppressed": lint.Suppressed})
+    }
+}
+
+// diffRevisions compares revision ?from= with ?to= (default: the current recipe).
+func diffRevisions(st *store.Store, id string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        load := func(param string) (recipe.Recipe, bool) {
+            v := r.URL.Query().Get(param)
+            if v == "" && param == "to" {
+                rec, err := st.LoadRecipe(id)
+                if err != nil {
+                    writeLoadError(w, err)
+                    return recipe.Recipe{}, false
+                }
+                return rec, true
+            }
+            n, err := strconv.Atoi(v)
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid %s revision", param)})
+                return recipe.Recipe{}, false
+            }
+            _, rec, err := st.LoadRevision(id, n)
+            if err != nil {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("revision %d not found", n)})
+                return recipe.Recipe{}, false
+            }
+            return rec, true
+        }
+        from, ok := load("from")
+        if !ok {
+            return
+        }
+        to, ok := load("to")
+        if !ok {
             return
         }
-        writeJSON(w, http.StatusOK, map[string]string{"path": target})
+        writeJSON(w, http.StatusOK, map[string]interface{}{"from": from.Revision, "to": to.Revision, "diff": recipe.Diff(from, to)})
     }
 }
 
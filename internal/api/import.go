@@ -0,0 +1,183 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "os"
+
+    "installforge/internal/store"
+)
+
+// importRequest holds the options of POST /api/projects/import, sent as a
+// JSON body or as multipart fields ahead of the "file" part.
+type importRequest struct {
+    Path       string `json:"path"`
+    Project    string `json:"project"`
+    OnConflict string `json:"onConflict"`
+    DryRun     bool   `json:"dryRun"`
+    store.Commit
+}
+
+// importProject imports an exported bundle: a JSON body names a bundle
+// directory or archive under the store's import directory with "path", a
+// multipart body uploads the archive as "file". It answers 201 for a new project, 200 for
+// a new revision of "project", and 409 with the conflicts when bundle
+// assets differ from existing ones and no "onConflict" policy was given.
+// Importing into "project" needs If-Match like a save, and answers 409
+// with the current recipe when the project moved on.
+func importProject(st *store.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req importRequest
+        path := ""
+        mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+        if mediaType == "multipart/form-data" {
+            tmp, err := receiveBundle(r, &req)
+            if errors.Is(err, store.ErrInvalidBundle) {
+                writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+                return
+            }
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+                return
+            }
+            defer os.Remove(tmp)
+            path = tmp
+        } else {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+                return
+            }
+            if req.Path == "" {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "path is required"})
+                return
+            }
+            resolved, err := st.ImportPath(req.Path)
+            if errors.Is(err, store.ErrImportPath) {
+                writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+                return
+            }
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+                return
+            }
+            path = resolved
+        }
+        base, ok, err := ifMatchRevision(r)
+        if !ok && req.Project != "" && !req.DryRun {
+            writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match header is required to import into a project; send the ETag from GET /api/projects/" + req.Project})
+            return
+        }
+        if err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
+        req.BaseRevision = base
+        if req.OnConflict != "" && req.OnConflict != "replace" && req.OnConflict != "keep" {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "onConflict must be replace or keep"})
+            return
+        }
+
+        dir, cleanup, err := store.OpenBundle(path)
+        if err != nil {
+            status := http.StatusUnprocessableEntity
+            if errors.Is(err, os.ErrNotExist) {
+                status = http.StatusBadRequest
+            }
+            writeJSON(w, status, map[string]string{"error": err.Error()})
+            return
+        }
+        defer cleanup()
+        res, err := st.ImportBundle(dir, store.ImportOptions{Project: req.Project, OnConflict: req.OnConflict, DryRun: req.DryRun, Commit: req.Commit})
+        var conflict *store.ConflictError
+        switch {
+        case errors.As(err, &conflict):
+            writeConflict(w, st, req.Project, res.Recipe, conflict)
+            return
+        case errors.Is(err, store.ErrImportConflict):
+            writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "conflicts": res.Conflicts})
+            return
+        case errors.Is(err, store.ErrInvalidBundle):
+            writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+            return
+        case errors.Is(err, store.ErrQuotaExceeded):
+            writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+            return
+        case errors.Is(err, os.ErrNotExist):
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+            return
+        case err != nil:
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        status := http.StatusOK
+        body := map[string]interface{}{"import": res}
+        if !req.DryRun {
+            lint := lintProject(st, res.Recipe.Project.ID, res.Recipe)
+            body["issues"] = lint.Issues
+            body["suppressed"] = lint.Suppressed
+            w.Header().Set("ETag", etag(res.Recipe))
+            if res.Created {
+                status = http.StatusCreated
+            }
+        }
+        writeJSON(w, status, body)
+    }
+}
+
+// receiveBundle reads the option fields of a multipart import into req and
+// streams the "file" part to a temp file, returning its path. Files over
+// store.MaxBundleBytes fail with store.ErrInvalidBundle.
+func receiveBundle(r *http.Request, req *importRequest) (string, error) {
+    mr, err := r.MultipartReader()
+    if err != nil {
+        return "", err
+    }
+    for {
+        part, err := mr.NextPart()
+        if err == io.EOF {
+            return "", fmt.Errorf("file is required")
+        }
+        if err != nil {
+            return "", err
+        }
+        if part.FormName() == "file" && part.FileName() != "" {
+            f, err := os.CreateTemp("", "asg-bundle-")
+            if err != nil {
+                return "", err
+            }
+            n, err := io.Copy(f, io.LimitReader(part, store.MaxBundleBytes+1))
+            if err == nil && n > store.MaxBundleBytes {
+                err = fmt.Errorf("%w: archive is larger than %d bytes", store.ErrInvalidBundle, store.MaxBundleBytes)
+            }
+            if cerr := f.Close(); err == nil {
+                err = cerr
+            }
+            if err != nil {
+                os.Remove(f.Name())
+                return "", err
+            }
+            return f.Name(), nil
+        }
+        value, err := io.ReadAll(io.LimitReader(part, 4096))
+        part.Close()
+        if err != nil {
+            return "", err
+        }
+        switch part.FormName() {
+        case "project":
+            req.Project = string(value)
+        case "onConflict":
+            req.OnConflict = string(value)
+        case "dryRun":
+            req.DryRun = string(value) == "true"
+        case "author":
+            req.Author = string(value)
+        case "message":
+            req.Message = string(value)
+        }
+    }
+}
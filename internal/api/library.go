@@ -0,0 +1,221 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+
+    "installforge/internal/store"
+)
+
+// libraryUpdate is the body of PATCH /api/library/{name}/{version}; absent
+// fields are left unchanged.
+type libraryUpdate struct {
+    Description     *string `json:"description"`
+    Deprecated      *bool   `json:"deprecated"`
+    DeprecationNote *string `json:"deprecationNote"`
+}
+
+// libraryRoutes serves the shared package library:
+//
+//  GET    /api/library                             list packages, ?name= filters
+//  POST   /api/library                             add a version (multipart name, version, description, uploader, file)
+//  GET    /api/library/{name}/{version}            package metadata and the projects pinning it
+//  PATCH  /api/library/{name}/{version}            update description or deprecation
+//  DELETE /api/library/{name}/{version}            remove it; 409 while pinned unless ?force=true
+//  GET    /api/library/{name}/{version}/download   download the file
+//  GET    /api/library/{name}/{version}/contents   archive listing or RPM header
+func libraryRoutes(st *store.Store, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch {
+        case len(parts) == 0 || len(parts) == 1 && parts[0] == "":
+            switch r.Method {
+            case http.MethodGet:
+                listLibrary(st)(w, r)
+            case http.MethodPost:
+                addLibraryEntry(st)(w, r)
+            default:
+                w.WriteHeader(http.StatusMethodNotAllowed)
+            }
+        case len(parts) == 2 && r.Method == http.MethodGet:
+            getLibraryEntry(st, parts[0], parts[1])(w, r)
+        case len(parts) == 2 && r.Method == http.MethodPatch:
+            updateLibraryEntry(st, parts[0], parts[1])(w, r)
+        case len(parts) == 2 && r.Method == http.MethodDelete:
+            deleteLibraryEntry(st, parts[0], parts[1])(w, r)
+        case len(parts) == 3 && parts[2] == "download" && r.Method == http.MethodGet:
+            downloadLibraryEntry(st, parts[0], parts[1])(w, r)
+        case len(parts) == 3 && parts[2] == "contents" && r.Method == http.MethodGet:
+            e, err := st.LibraryEntry(parts[0], parts[1])
+            if err != nil {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+                return
+            }
+            res, err := st.Inspect(e.SHA256, e.Filename)
+            writeInspection(w, res, err)
+        case len(parts) == 2 || len(parts) == 3 && (parts[2] == "download" || parts[2] == "contents"):
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }
+}
+
+func listLibrary(st *store.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        entries, err := st.LibraryEntries()
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        if name := r.URL.Query().Get("name"); name != "" {
+            filtered := []store.LibraryEntry{}
+            for _, e := range entries {
+                if e.Name == name {
+                    filtered = append(filtered, e)
+                }
+            }
+            entries = filtered
+        }
+        writeJSON(w, http.StatusOK, entries)
+    }
+}
+
+// addLibraryEntry streams the multipart "file" into the library. The text
+// fields must come before the file.
+func addLibraryEntry(st *store.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        mr, err := r.MultipartReader()
+        if err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+            return
+        }
+        fields := map[string]string{}
+        for {
+            part, err := mr.NextPart()
+            if err == io.EOF {
+                break
+            }
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+                return
+            }
+            if part.FileName() == "" {
+                data, err := io.ReadAll(io.LimitReader(part, 4<<10))
+                if err != nil {
+                    writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+                    return
+                }
+                fields[part.FormName()] = string(data)
+                continue
+            }
+            if part.FormName() != "file" {
+                part.Close()
+                continue
+            }
+            e, err := st.AddLibraryEntry(fields["name"], fields["version"], part.FileName(), fields["description"], fields["uploader"], part)
+            switch {
+            case errors.Is(err, store.ErrLibraryExists):
+                writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+            case err != nil:
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            default:
+                writeJSON(w, http.StatusCreated, e)
+            }
+            return
+        }
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file is required"})
+    }
+}
+
+func getLibraryEntry(st *store.Store, name, version string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        e, err := st.LibraryEntry(name, version)
+        if err != nil {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        users, err := st.LibraryUsers(name, version)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        writeJSON(w, http.StatusOK, map[string]interface{}{"package": e, "usedBy": users})
+    }
+}
+
+func updateLibraryEntry(st *store.Store, name, version string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req libraryUpdate
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+            return
+        }
+        e, err := st.UpdateLibraryEntry(name, version, func(e *store.LibraryEntry) {
+            if req.Description != nil {
+                e.Description = *req.Description
+            }
+            if req.Deprecated != nil {
+                e.Deprecated = *req.Deprecated
+            }
+            if req.DeprecationNote != nil {
+                e.DeprecationNote = *req.DeprecationNote
+            }
+        })
+        if errors.Is(err, os.ErrNotExist) {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        writeJSON(w, http.StatusOK, e)
+    }
+}
+
+func deleteLibraryEntry(st *store.Store, name, version string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        err := st.DeleteLibraryEntry(name, version, r.URL.Query().Get("force") == "true")
+        var inUse *store.LibraryInUseError
+        if errors.As(err, &inUse) {
+            writeJSON(w, http.StatusConflict, map[string]interface{}{
+                "error":  fmt.Sprintf("%s %s is pinned by projects; deprecate it instead, or delete with ?force=true", name, version),
+                "usedBy": inUse.Users,
+            })
+            return
+        }
+        if errors.Is(err, os.ErrNotExist) {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+    }
+}
+
+func downloadLibraryEntry(st *store.Store, name, version string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        e, err := st.LibraryEntry(name, version)
+        if err != nil {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        f, err := st.OpenBlob(e.SHA256)
+        if err != nil {
+            writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            return
+        }
+        defer f.Close()
+        w.Header().Set("Content-Type", e.MIMEType)
+        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.Filename))
+        w.Header().Set("ETag", `"`+e.SHA256+`"`)
+        http.ServeContent(w, r, e.Filename, e.UploadedAt, f)
+    }
+}
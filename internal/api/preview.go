@@ -0,0 +1,121 @@
+package api
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+
+    "installforge/internal/recipe"
+    "installforge/internal/render"
+)
+
+// readRecipe reads a full recipe from the request body, migrating older schema versions.
+func readRecipe(r *http.Request) (recipe.Recipe, error) {
+    data, err := io.ReadAll(r.Body)
+    if err != nil {
+        return recipe.Recipe{}, err
+    }
+    rec, _, err := recipe.Migrate(data)
+    return rec, err
+}
+
+// validateRecipe lints the posted recipe without touching the store.
+func validateRecipe(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+    rec, err := readRecipe(r)
+    if err != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+        return
+    }
+    lint := recipe.Lint(rec, recipe.Options{})
+    writeJSON(w, http.StatusOK, map[string]interface{}{"issues": lint.Issues, "suppressed": lint.Suppressed})
+}
+
+// previewRecipe renders the posted recipe without touching the store. With
+// ?step=<id>, or a ?from=<id>&to=<id> range, it returns only those steps'
+// snippets and issues.
+func previewRecipe(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+    rec, err := readRecipe(r)
+    if err != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+        return
+    }
+    q := r.URL.Query()
+    from, to := q.Get("from"), q.Get("to")
+    if step := q.Get("step"); step != "" {
+        from, to = step, step
+    }
+    if from == "" && to == "" {
+        res, err := render.Render(rec, recipe.Options{})
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        writeJSON(w, http.StatusOK, res)
+        return
+    }
+
+    steps, err := stepRange(rec.Steps, from, to)
+    if err != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+        return
+    }
+    snippets, err := render.RenderSnippets(steps)
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+        return
+    }
+    inRange := map[string]bool{}
+    for _, s := range steps {
+        inRange[s.ID] = true
+    }
+    lint := recipe.Lint(rec, recipe.Options{})
+    var issues []recipe.Issue
+    for _, is := range lint.Issues {
+        if inRange[is.StepID] {
+            issues = append(issues, is)
+        }
+    }
+    var suppressed []recipe.SuppressedIssue
+    for _, is := range lint.Suppressed {
+        if inRange[is.StepID] {
+            suppressed = append(suppressed, is)
+        }
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"steps": snippets, "issues": issues, "suppressed": suppressed})
+}
+
+// stepRange returns the steps from the step with ID from through the step
+// with ID to; an empty bound means the first or last step.
+func stepRange(steps []recipe.Step, from, to string) ([]recipe.Step, error) {
+    find := func(id string, def int) (int, error) {
+        if id == "" {
+            return def, nil
+        }
+        for i, s := range steps {
+            if s.ID == id {
+                return i, nil
+            }
+        }
+        return 0, fmt.Errorf("unknown step %s", id)
+    }
+    start, err := find(from, 0)
+    if err != nil {
+        return nil, err
+    }
+    end, err := find(to, len(steps)-1)
+    if err != nil {
+        return nil, err
+    }
+    if start > end {
+        return nil, fmt.Errorf("step %s comes after step %s", from, to)
+    }
+    return steps[start : end+1], nil
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+
+    "installforge/internal/recipe"
+    "installforge/internal/store"
+)
+
+// projectRequest is the body of the clone, rename and archive endpoints.
+type projectRequest struct {
+    Name string `json:"name"`
+    store.Commit
+}
+
+// projectActions serves the lifecycle endpoints of /api/projects/{id}:
+//
+//  POST /clone      copy recipe and assets to a new project
+//  POST /rename     rename the project
+//  POST /archive    hide the project from the project list
+//  POST /unarchive  list it again
+func projectActions(st *store.Store, id, action string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            w.WriteHeader(http.StatusMethodNotAllowed)
+            return
+        }
+        var req projectRequest
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+                return
+            }
+        }
+        edit := func(message string, fn func(rec *recipe.Recipe)) {
+            if req.Message == "" {
+                req.Message = message
+            }
+            mutateRecipe(st, id, w, r, req.Commit, func(rec *recipe.Recipe) error {
+                fn(rec)
+                return nil
+            })
+        }
+        switch action {
+        case "clone":
+            rec, err := st.CloneProject(id, strings.TrimSpace(req.Name))
+            if errors.Is(err, os.ErrNotExist) {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+                return
+            }
+            if err != nil {
+                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+                return
+            }
+            w.Header().Set("ETag", etag(rec))
+            writeJSON(w, http.StatusCreated, rec)
+        case "rename":
+            name := strings.TrimSpace(req.Name)
+            if name == "" {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name is required"})
+                return
+            }
+            edit(fmt.Sprintf("rename project to %s", name), func(rec *recipe.Recipe) {
+                rec.Project.Name = name
+            })
+        case "archive":
+            edit("archive project", func(rec *recipe.Recipe) {
+                rec.Project.Archived = true
+            })
+        case "unarchive":
+            edit("unarchive project", func(rec *recipe.Recipe) {
+                rec.Project.Archived = false
+            })
+        }
+    }
+}
+
+// deleteProject moves a project to the trash; with ?permanent=true it is
+// purged right away.
+func deleteProject(st *store.Store, id string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        e, err := st.TrashProject(id)
+        if err == nil && r.URL.Query().Get("permanent") == "true" {
+            writeTrashResult(w, nil, st.PurgeProject(id))
+            return
+        }
+        writeTrashResult(w, e, err)
+    }
+}
+
+// trashRoutes serves /api/trash:
+//
+//  GET    /api/trash               list deleted projects
+//  POST   /api/trash/{id}/restore  move a project back
+//  DELETE /api/trash/{id}          purge it and release its assets
+func trashRoutes(st *store.Store, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch {
+        case len(parts) == 0 || parts[0] == "":
+            if r.Method != http.MethodGet {
+                w.WriteHeader(http.StatusMethodNotAllowed)
+                return
+            }
+            entries, err := st.ListTrash()
+            if err != nil {
+                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+                return
+            }
+            writeJSON(w, http.StatusOK, entries)
+        case len(parts) == 1 && r.Method == http.MethodDelete:
+            writeTrashResult(w, nil, st.PurgeProject(parts[0]))
+        case len(parts) == 2 && parts[1] == "restore" && r.Method == http.MethodPost:
+            rec, err := st.RestoreProject(parts[0])
+            if err == nil {
+                w.Header().Set("ETag", etag(rec))
+            }
+            writeTrashResult(w, rec, err)
+        case len(parts) <= 2:
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }
+}
+
+// writeTrashResult answers a trash operation with res, or {"status":"ok"}
+// when res is nil.
+func writeTrashResult(w http.ResponseWriter, res interface{}, err error) {
+    switch {
+    case errors.Is(err, os.ErrNotExist):
+        writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+    case errors.Is(err, store.ErrProjectExists):
+        writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+    case err != nil:
+        writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+    case res == nil:
+        writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+    default:
+        writeJSON(w, http.StatusOK, res)
+    }
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+
+    "installforge/internal/store"
+)
+
+func TestListProjectsResponse(t *testing.T) {
+    st := store.New(filepath.Join(t.TempDir(), "projects"))
+    for _, name := range []string{"alpha", "bravo", "charlie"} {
+        if _, err := st.CreateProject(name, "", nil); err != nil {
+            t.Fatal(err)
+        }
+    }
+    tests := []struct {
+        query  string
+        status int
+        paged  bool
+        count  int
+    }{
+        {"", http.StatusOK, false, 3},
+        {"?q=bravo&sort=name", http.StatusOK, false, 1},
+        {"?limit=2", http.StatusOK, true, 2},
+        {"?limit=0", http.StatusBadRequest, false, 0},
+        {"?limit=x", http.StatusBadRequest, false, 0},
+        {"?sort=size", http.StatusBadRequest, false, 0},
+        {"?cursor=bad", http.StatusBadRequest, false, 0},
+    }
+    for _, tt := range tests {
+        rec := httptest.NewRecorder()
+        listProjects(st)(rec, httptest.NewRequest(http.MethodGet, "/api/projects"+tt.query, nil))
+        if rec.Code != tt.status {
+            t.Errorf("%s: status %d, want %d: %s", tt.query, rec.Code, tt.status, rec.Body)
+            continue
+        }
+        if tt.status != http.StatusOK {
+            continue
+        }
+        var projects []store.ProjectSummary
+        if tt.paged {
+            var page store.ProjectPage
+            if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+                t.Fatalf("%s: %v", tt.query, err)
+            }
+            if page.Total != 3 || page.NextCursor == "" {
+                t.Errorf("%s: page = %+v", tt.query, page)
+            }
+            projects = page.Projects
+        } else if err := json.Unmarshal(rec.Body.Bytes(), &projects); err != nil {
+            t.Fatalf("%s: want a bare array: %v", tt.query, err)
+        }
+        if len(projects) != tt.count {
+            t.Errorf("%s: %d projects, want %d", tt.query, len(projects), tt.count)
+        }
+    }
+}
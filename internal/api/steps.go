@@ -0,0 +1,191 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+
+    "installforge/internal/recipe"
+    "installforge/internal/store"
+)
+
+// stepRequest is the body of the step endpoints; which fields apply depends on the endpoint.
+type stepRequest struct {
+    Step     recipe.Step `json:"step"`
+    Position *int        `json:"position"`
+    Order    []string    `json:"order"`
+    store.Commit
+}
+
+// stepRoutes serves /api/projects/{id}/steps:
+//
+//  POST   /steps                  add a step at position (default: append)
+//  PUT    /steps                  reorder all steps by order
+//  PUT    /steps/{step}           replace one step
+//  DELETE /steps/{step}           delete one step
+//  POST   /steps/{step}/move      move a step to position
+//  POST   /steps/{step}/duplicate insert a copy after the step
+func stepRoutes(st *store.Store, id string, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req stepRequest
+        if r.ContentLength != 0 && r.Method != http.MethodDelete {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+                return
+            }
+        }
+        pos := -1
+        if req.Position != nil {
+            pos = *req.Position
+        }
+        edit := func(message string, fn func(rec *recipe.Recipe) error) {
+            if req.Message == "" {
+                req.Message = message
+            }
+            mutateRecipe(st, id, w, r, req.Commit, fn)
+        }
+
+        switch {
+        case len(parts) == 0 || parts[0] == "":
+            switch r.Method {
+            case http.MethodPost:
+                edit("add step", func(rec *recipe.Recipe) error {
+                    _, err := rec.InsertStep(req.Step, pos)
+                    return err
+                })
+            case http.MethodPut:
+                edit("reorder steps", func(rec *recipe.Recipe) error {
+                    return rec.ReorderSteps(req.Order)
+                })
+            default:
+                w.WriteHeader(http.StatusMethodNotAllowed)
+            }
+        case len(parts) == 1:
+            sid := parts[0]
+            switch r.Method {
+            case http.MethodPut:
+                edit("update step "+sid, func(rec *recipe.Recipe) error {
+                    _, err := rec.ReplaceStep(sid, req.Step)
+                    return err
+                })
+            case http.MethodDelete:
+                edit("delete step "+sid, func(rec *recipe.Recipe) error {
+                    return rec.DeleteStep(sid)
+                })
+            default:
+                w.WriteHeader(http.StatusMethodNotAllowed)
+            }
+        case len(parts) == 2 && (parts[1] == "move" || parts[1] == "duplicate"):
+            sid := parts[0]
+            if r.Method != http.MethodPost {
+                w.WriteHeader(http.StatusMethodNotAllowed)
+                return
+            }
+            if parts[1] == "move" {
+                if req.Position == nil {
+                    writeJSON(w, http.StatusBadRequest, map[string]string{"error": "position is required"})
+                    return
+                }
+                edit(fmt.Sprintf("move step %s to %d", sid, pos), func(rec *recipe.Recipe) error {
+                    return rec.MoveStep(sid, pos)
+                })
+                return
+            }
+            edit("duplicate step "+sid, func(rec *recipe.Recipe) error {
+                _, err := rec.DuplicateStep(sid)
+                return err
+            })
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }
+}
+
+// patchProject applies an RFC 6902 JSON Patch to the recipe.
+func patchProject(st *store.Store, id string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var ops []recipe.PatchOp
+        if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body must be a JSON Patch array"})
+            return
+        }
+        mutateRecipe(st, id, w, r, store.Commit{Message: fmt.Sprintf("apply patch (%d ops)", len(ops))}, func(rec *recipe.Recipe) error {
+            patched, err := recipe.ApplyPatch(*rec, ops)
+            *rec = patched
+            return err
+        })
+    }
+}
+
+// mutateRecipe applies edit to the current recipe and saves the result as a
+// new revision. If-Match is honoured when sent; either way the save fails
+// with 409 if another save lands in between. The response carries the issues
+// of the steps the edit added, changed or moved, plus recipe-level issues.
+func mutateRecipe(st *store.Store, id string, w http.ResponseWriter, r *http.Request, c store.Commit, edit func(rec *recipe.Recipe) error) {
+    old, err := st.LoadRecipe(id)
+    if err != nil {
+        writeLoadError(w, err)
+        return
+    }
+    base, _, err := ifMatchRevision(r)
+    if err != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+        return
+    }
+    if base == nil {
+        rev := old.Revision
+        base = &rev
+    }
+    if *base != old.Revision {
+        writeConflict(w, st, id, old, &store.ConflictError{Base: *base, Current: old.Revision})
+        return
+    }
+
+    rec := old
+    rec.Steps = append([]recipe.Step(nil), old.Steps...)
+    if err := edit(&rec); err != nil {
+        status := http.StatusBadRequest
+        if errors.Is(err, recipe.ErrStepNotFound) {
+            status = http.StatusNotFound
+        } else if errors.Is(err, recipe.ErrPatchTest) {
+            status = http.StatusConflict
+        }
+        writeJSON(w, status, map[string]string{"error": err.Error()})
+        return
+    }
+    rec.Project.ID = id
+    c.BaseRevision = base
+    saved, err := st.SaveRecipe(rec, c)
+    var conflict *store.ConflictError
+    if errors.As(err, &conflict) {
+        writeConflict(w, st, id, rec, conflict)
+        return
+    }
+    if err != nil {
+        writeLoadError(w, err)
+        return
+    }
+
+    affected := map[string]bool{"": true}
+    for _, ch := range recipe.Diff(old, saved).Steps {
+        if ch.Kind != "removed" {
+            affected[ch.StepID] = true
+        }
+    }
+    lint := lintProject(st, id, saved)
+    var issues []recipe.Issue
+    for _, is := range lint.Issues {
+        if affected[is.StepID] {
+            issues = append(issues, is)
+        }
+    }
+    var suppressed []recipe.SuppressedIssue
+    for _, is := range lint.Suppressed {
+        if affected[is.StepID] {
+            suppressed = append(suppressed, is)
+        }
+    }
+    w.Header().Set("ETag", etag(saved))
+    writeJSON(w, http.StatusOK, map[string]interface{}{"recipe": saved, "issues": issues, "suppressed": suppressed})
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "os"
+
+    "installforge/internal/recipe"
+    "installforge/internal/store"
+)
+
+// templateRequest is the body of PUT /api/templates/{id}: the template's
+// recipe is either given inline or taken from the current recipe of project.
+type templateRequest struct {
+    Name        string          `json:"name"`
+    Description string          `json:"description"`
+    Recipe      json.RawMessage `json:"recipe"`
+    Project     string          `json:"project"`
+}
+
+// templateRoutes serves /api/templates:
+//
+//  GET    /api/templates       list templates, without their recipes
+//  GET    /api/templates/{id}  read one template with its recipe
+//  PUT    /api/templates/{id}  add or replace a workspace template
+//  DELETE /api/templates/{id}  delete a workspace template
+func templateRoutes(st *store.Store, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if len(parts) == 0 || parts[0] == "" {
+            if r.Method != http.MethodGet {
+                w.WriteHeader(http.StatusMethodNotAllowed)
+                return
+            }
+            list, err := st.ListTemplates()
+            if err != nil {
+                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+                return
+            }
+            writeJSON(w, http.StatusOK, list)
+            return
+        }
+        if len(parts) > 1 {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        id := parts[0]
+        switch r.Method {
+        case http.MethodGet:
+            t, err := st.Template(id)
+            if errors.Is(err, os.ErrNotExist) {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+                return
+            }
+            if err != nil {
+                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+                return
+            }
+            writeJSON(w, http.StatusOK, t)
+        case http.MethodPut:
+            saveTemplate(st, id)(w, r)
+        case http.MethodDelete:
+            err := st.DeleteTemplate(id)
+            switch {
+            case errors.Is(err, store.ErrBuiltinTemplate):
+                writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+            case errors.Is(err, os.ErrNotExist):
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+            case err != nil:
+                writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            default:
+                writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+            }
+        default:
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        }
+    }
+}
+
+func saveTemplate(st *store.Store, id string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req templateRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+            return
+        }
+        if (req.Recipe == nil) == (req.Project == "") {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "give either recipe or project"})
+            return
+        }
+        var rec recipe.Recipe
+        if req.Recipe != nil {
+            migrated, _, err := recipe.Migrate(req.Recipe)
+            if err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+                return
+            }
+            rec = migrated
+        } else {
+            loaded, err := st.LoadRecipe(req.Project)
+            if err != nil {
+                writeJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+                return
+            }
+            rec = loaded
+        }
+        t, err := st.SaveTemplate(id, req.Name, req.Description, rec)
+        if errors.Is(err, store.ErrInvalidTemplateID) {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+            return
+        }
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+            return
+        }
+        writeJSON(w, http.StatusOK, t)
+    }
+}
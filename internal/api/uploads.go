@@ -0,0 +1,156 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "os"
+    "strconv"
+
+    "installforge/internal/store"
+)
+
+// uploadRequest is the body of POST /uploads. SHA256 may instead be given on finalize.
+type uploadRequest struct {
+    Filename string `json:"filename"`
+    Size     int64  `json:"size"`
+    SHA256   string `json:"sha256"`
+    Uploader string `json:"uploader"`
+}
+
+// uploadRoutes serves the resumable upload protocol under /api/projects/{id}/uploads:
+//
+//  POST   /uploads                 open a session for {filename, size, sha256?}
+//  GET    /uploads                 list open sessions
+//  GET    /uploads/{uid}           session state; offset is where the next chunk goes
+//  PUT    /uploads/{uid}           append the body at the Upload-Offset header
+//  POST   /uploads/{uid}/finalize  check {sha256} and move the file into assets
+//  DELETE /uploads/{uid}           abort the session
+func uploadRoutes(st *store.Store, id string, parts []string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if _, err := st.LoadRecipe(id); err != nil {
+            writeLoadError(w, err)
+            return
+        }
+        switch {
+        case len(parts) == 0 || parts[0] == "":
+            switch r.Method {
+            case http.MethodPost:
+                createUpload(st, id)(w, r)
+            case http.MethodGet:
+                uploads, err := st.ListUploads(id)
+                if err != nil {
+                    writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+                    return
+                }
+                if uploads == nil {
+                    uploads = []store.Upload{}
+                }
+                writeJSON(w, http.StatusOK, uploads)
+            default:
+                w.WriteHeader(http.StatusMethodNotAllowed)
+            }
+        case len(parts) == 1 && r.Method == http.MethodGet:
+            u, err := st.LoadUpload(id, parts[0])
+            if err != nil {
+                writeUploadError(w, err)
+                return
+            }
+            w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+            writeJSON(w, http.StatusOK, u)
+        case len(parts) == 1 && r.Method == http.MethodPut:
+            writeUploadChunk(st, id, parts[0])(w, r)
+        case len(parts) == 1 && r.Method == http.MethodDelete:
+            if err := st.AbortUpload(id, parts[0]); err != nil {
+                writeUploadError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+        case len(parts) == 2 && parts[1] == "finalize" && r.Method == http.MethodPost:
+            finalizeUpload(st, id, parts[0])(w, r)
+        case len(parts) <= 2:
+            w.WriteHeader(http.StatusMethodNotAllowed)
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }
+}
+
+func createUpload(st *store.Store, id string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req uploadRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+            return
+        }
+        u, err := st.CreateUpload(id, req.Filename, req.Size, req.SHA256, req.Uploader)
+        if err != nil {
+            writeUploadError(w, err)
+            return
+        }
+        w.Header().Set("Location", r.URL.Path+"/"+u.ID)
+        w.Header().Set("Upload-Offset", "0")
+        writeJSON(w, http.StatusCreated, u)
+    }
+}
+
+// writeUploadChunk streams the request body straight into the upload's part file.
+func writeUploadChunk(st *store.Store, id, uid string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        raw := r.Header.Get("Upload-Offset")
+        if raw == "" {
+            raw = r.URL.Query().Get("offset")
+        }
+        offset, err := strconv.ParseInt(raw, 10, 64)
+        if err != nil || offset < 0 {
+            writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Upload-Offset header is required"})
+            return
+        }
+        u, err := st.WriteUploadChunk(id, uid, offset, r.Body)
+        if u.ID != "" {
+            w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+        }
+        if err != nil {
+            writeUploadError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, u)
+    }
+}
+
+func finalizeUpload(st *store.Store, id, uid string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var req struct {
+            SHA256 string `json:"sha256"`
+        }
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+                return
+            }
+        }
+        meta, err := st.FinalizeUpload(id, uid, req.SHA256)
+        if err != nil {
+            writeUploadError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "asset": meta})
+    }
+}
+
+// writeUploadError maps store upload errors to their HTTP status.
+func writeUploadError(w http.ResponseWriter, err error) {
+    var offErr *store.OffsetError
+    switch {
+    case errors.As(err, &offErr):
+        writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "offset": offErr.Offset})
+    case errors.Is(err, os.ErrNotExist):
+        writeJSON(w, http.StatusNotFound, map[string]string{"error": "upload not found"})
+    case errors.Is(err, store.ErrQuotaExceeded):
+        writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+    case errors.Is(err, store.ErrChecksumMismatch):
+        writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+    default:
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+    }
+}
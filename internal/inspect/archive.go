@@ -0,0 +1,58 @@
+package inspect
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "io"
+)
+
+func listTarGz(r io.Reader) ([]Entry, bool, error) {
+    gz, err := gzip.NewReader(r)
+    if err != nil {
+        return nil, false, err
+    }
+    defer gz.Close()
+    tr := tar.NewReader(gz)
+    entries := []Entry{}
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            return entries, false, nil
+        }
+        if err != nil {
+            return nil, false, err
+        }
+        name := cleanName(hdr.Name)
+        if name == "" || hdr.Typeflag == tar.TypeXGlobalHeader {
+            continue
+        }
+        if len(entries) == MaxEntries {
+            return entries, true, nil
+        }
+        e := Entry{Name: name, Size: hdr.Size, Dir: hdr.Typeflag == tar.TypeDir}
+        if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+            e.Link = hdr.Linkname
+        }
+        entries = append(entries, e)
+    }
+}
+
+func listZip(ra io.ReaderAt, size int64) ([]Entry, bool, error) {
+    zr, err := zip.NewReader(ra, size)
+    if err != nil {
+        return nil, false, err
+    }
+    entries := []Entry{}
+    for _, f := range zr.File {
+        name := cleanName(f.Name)
+        if name == "" {
+            continue
+        }
+        if len(entries) == MaxEntries {
+            return entries, true, nil
+        }
+        entries = append(entries, Entry{Name: name, Size: int64(f.UncompressedSize64), Dir: f.FileInfo().IsDir()})
+    }
+    return entries, false, nil
+}
@@ -0,0 +1,91 @@
+// Package inspect lists the contents of archives and reads RPM headers so
+// the server can show what an uploaded asset holds.
+package inspect
+
+import (
+    "errors"
+    "io"
+    "path"
+    "strings"
+)
+
+// MaxEntries caps how many archive entries are listed.
+const MaxEntries = 100000
+
+// Format is the version of Result; cached results of another format are
+// read again. It was 0 before RPM file lists were read.
+const Format = 1
+
+// ErrUnsupported is returned for files that are neither archives nor RPMs.
+var ErrUnsupported = errors.New("file type cannot be inspected")
+
+// Entry is one file, directory or link in an archive. Name is relative,
+// without a leading "./" or trailing "/".
+type Entry struct {
+    Name string `json:"name"`
+    Size int64  `json:"size"`
+    Dir  bool   `json:"dir,omitempty"`
+    Link string `json:"link,omitempty"`
+}
+
+// Result is what inspecting an asset found. Truncated is set when the
+// archive had more than MaxEntries entries.
+type Result struct {
+    Format    int      `json:"format,omitempty"`
+    Kind      string   `json:"kind"`
+    Entries   []Entry  `json:"entries,omitempty"`
+    Truncated bool     `json:"truncated,omitempty"`
+    RPM       *RPMInfo `json:"rpm,omitempty"`
+}
+
+// Kind names the format of a file from its name: "tar.gz", "zip", "rpm" or "".
+func Kind(name string) string {
+    lower := strings.ToLower(name)
+    switch {
+    case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+        return "tar.gz"
+    case strings.HasSuffix(lower, ".zip"):
+        return "zip"
+    case strings.HasSuffix(lower, ".rpm"):
+        return "rpm"
+    }
+    return ""
+}
+
+// Inspect reads the file named name of the given size.
+func Inspect(name string, ra io.ReaderAt, size int64) (Result, error) {
+    kind := Kind(name)
+    res := Result{Format: Format, Kind: kind}
+    var err error
+    switch kind {
+    case "tar.gz":
+        res.Entries, res.Truncated, err = listTarGz(io.NewSectionReader(ra, 0, size))
+    case "zip":
+        res.Entries, res.Truncated, err = listZip(ra, size)
+    case "rpm":
+        var info RPMInfo
+        info, err = ReadRPM(io.NewSectionReader(ra, 0, size))
+        res.RPM = &info
+    default:
+        return Result{}, ErrUnsupported
+    }
+    if err != nil {
+        return Result{}, err
+    }
+    return res, nil
+}
+
+// Paths lists the entry names of an archive result.
+func (r Result) Paths() []string {
+    paths := make([]string, 0, len(r.Entries))
+    for _, e := range r.Entries {
+        paths = append(paths, e.Name)
+    }
+    return paths
+}
+
+// cleanName normalizes an archive member name; it returns "" for the root.
+func cleanName(name string) string {
+    name = path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+    return strings.TrimPrefix(name, "/")
+}
@@ -0,0 +1,256 @@
+package inspect
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+)
+
+// RPMInfo is the package metadata read from an RPM header. Provides and
+// Requires are formatted like rpm -q --provides, e.g. "libfoo.so.1()(64bit)"
+// or "glibc >= 2.12". Files lists the absolute paths the package installs,
+// which rpm also counts as provided.
+type RPMInfo struct {
+    Name     string   `json:"name"`
+    Epoch    string   `json:"epoch,omitempty"`
+    Version  string   `json:"version"`
+    Release  string   `json:"release"`
+    Arch     string   `json:"arch"`
+    Summary  string   `json:"summary,omitempty"`
+    License  string   `json:"license,omitempty"`
+    Provides []string `json:"provides"`
+    Requires []string `json:"requires"`
+    Files    []string `json:"files,omitempty"`
+}
+
+// RPM header tags and data types used here; see rpmtag.h.
+const (
+    tagName           = 1000
+    tagVersion        = 1001
+    tagRelease        = 1002
+    tagEpoch          = 1003
+    tagSummary        = 1004
+    tagLicense        = 1014
+    tagArch           = 1022
+    tagProvideName    = 1047
+    tagRequireFlags   = 1048
+    tagRequireName    = 1049
+    tagRequireVersion = 1050
+    tagProvideFlags   = 1112
+    tagProvideVersion = 1113
+    tagDirIndexes     = 1116
+    tagBaseNames      = 1117
+    tagDirNames       = 1118
+
+    typeInt32       = 4
+    typeString      = 6
+    typeStringArray = 8
+    typeI18NString  = 9
+
+    senseLess    = 0x02
+    senseGreater = 0x04
+    senseEqual   = 0x08
+)
+
+const (
+    leadSize      = 96
+    maxHeaderData = 64 << 20
+    maxIndex      = 1 << 16
+)
+
+var (
+    leadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+    headerMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+var errNotRPM = errors.New("not an RPM package")
+
+// header is a parsed RPM header structure.
+type header struct {
+    index []indexEntry
+    data  []byte
+}
+
+type indexEntry struct {
+    Tag    int32
+    Type   uint32
+    Offset int32
+    Count  uint32
+}
+
+// ReadRPM reads the metadata of an RPM package from the start of r.
+func ReadRPM(r io.Reader) (RPMInfo, error) {
+    lead := make([]byte, leadSize)
+    if _, err := io.ReadFull(r, lead); err != nil {
+        return RPMInfo{}, errNotRPM
+    }
+    if !bytes.Equal(lead[:4], leadMagic) {
+        return RPMInfo{}, errNotRPM
+    }
+    _, n, err := readHeader(r)
+    if err != nil {
+        return RPMInfo{}, fmt.Errorf("signature header: %w", err)
+    }
+    // the signature header is padded to a multiple of 8 bytes
+    if pad := (8 - n%8) % 8; pad > 0 {
+        if _, err := io.CopyN(io.Discard, r, int64(pad)); err != nil {
+            return RPMInfo{}, err
+        }
+    }
+    h, _, err := readHeader(r)
+    if err != nil {
+        return RPMInfo{}, fmt.Errorf("header: %w", err)
+    }
+    info := RPMInfo{
+        Name:     h.str(tagName),
+        Version:  h.str(tagVersion),
+        Release:  h.str(tagRelease),
+        Arch:     h.str(tagArch),
+        Summary:  h.str(tagSummary),
+        License:  h.str(tagLicense),
+        Provides: h.deps(tagProvideName, tagProvideFlags, tagProvideVersion),
+        Requires: h.deps(tagRequireName, tagRequireFlags, tagRequireVersion),
+        Files:    h.files(),
+    }
+    if epoch := h.ints(tagEpoch); len(epoch) > 0 {
+        info.Epoch = strconv.Itoa(int(epoch[0]))
+    }
+    if info.Name == "" {
+        return RPMInfo{}, errors.New("header has no package name")
+    }
+    return info, nil
+}
+
+// readHeader reads one header structure and returns it with its size in bytes.
+func readHeader(r io.Reader) (header, int, error) {
+    intro := make([]byte, 16)
+    if _, err := io.ReadFull(r, intro); err != nil {
+        return header{}, 0, err
+    }
+    if !bytes.Equal(intro[:4], headerMagic) {
+        return header{}, 0, errors.New("bad header magic")
+    }
+    nindex := binary.BigEndian.Uint32(intro[8:12])
+    hsize := binary.BigEndian.Uint32(intro[12:16])
+    if nindex > maxIndex || hsize > maxHeaderData {
+        return header{}, 0, errors.New("header too large")
+    }
+    h := header{index: make([]indexEntry, nindex), data: make([]byte, hsize)}
+    if err := binary.Read(r, binary.BigEndian, h.index); err != nil {
+        return header{}, 0, err
+    }
+    if _, err := io.ReadFull(r, h.data); err != nil {
+        return header{}, 0, err
+    }
+    return h, 16 + int(nindex)*16 + int(hsize), nil
+}
+
+func (h header) find(tag int32) (indexEntry, bool) {
+    for _, e := range h.index {
+        if e.Tag == tag {
+            return e, true
+        }
+    }
+    return indexEntry{}, false
+}
+
+// strs returns a string, string array or i18n string tag as a slice.
+func (h header) strs(tag int32) []string {
+    e, ok := h.find(tag)
+    if !ok || e.Offset < 0 || int(e.Offset) >= len(h.data) {
+        return nil
+    }
+    switch e.Type {
+    case typeString, typeStringArray, typeI18NString:
+    default:
+        return nil
+    }
+    count := int(e.Count)
+    if e.Type == typeString {
+        count = 1
+    }
+    var res []string
+    data := h.data[e.Offset:]
+    for i := 0; i < count; i++ {
+        end := bytes.IndexByte(data, 0)
+        if end < 0 {
+            break
+        }
+        res = append(res, string(data[:end]))
+        data = data[end+1:]
+    }
+    return res
+}
+
+func (h header) str(tag int32) string {
+    if s := h.strs(tag); len(s) > 0 {
+        return s[0]
+    }
+    return ""
+}
+
+func (h header) ints(tag int32) []int32 {
+    e, ok := h.find(tag)
+    if !ok || e.Type != typeInt32 || e.Offset < 0 {
+        return nil
+    }
+    end := int(e.Offset) + int(e.Count)*4
+    if end > len(h.data) {
+        return nil
+    }
+    res := make([]int32, e.Count)
+    for i := range res {
+        res[i] = int32(binary.BigEndian.Uint32(h.data[int(e.Offset)+i*4:]))
+    }
+    return res
+}
+
+// deps joins dependency names with their version constraints.
+func (h header) deps(nameTag, flagsTag, versionTag int32) []string {
+    names := h.strs(nameTag)
+    flags := h.ints(flagsTag)
+    versions := h.strs(versionTag)
+    res := make([]string, 0, len(names))
+    for i, name := range names {
+        if i < len(versions) && i < len(flags) && versions[i] != "" {
+            if op := senseOp(flags[i]); op != "" {
+                name += " " + op + " " + versions[i]
+            }
+        }
+        res = append(res, name)
+    }
+    return res
+}
+
+// files joins the compressed file list, each base name with the directory
+// its dir index points at. Entries with an index out of range are dropped.
+func (h header) files() []string {
+    bases := h.strs(tagBaseNames)
+    dirs := h.strs(tagDirNames)
+    indexes := h.ints(tagDirIndexes)
+    var res []string
+    for i, base := range bases {
+        if i >= len(indexes) || indexes[i] < 0 || int(indexes[i]) >= len(dirs) {
+            continue
+        }
+        res = append(res, dirs[indexes[i]]+base)
+    }
+    return res
+}
+
+func senseOp(flags int32) string {
+    op := ""
+    if flags&senseLess != 0 {
+        op += "<"
+    }
+    if flags&senseGreater != 0 {
+        op += ">"
+    }
+    if flags&senseEqual != 0 {
+        op += "="
+    }
+    return op
+}
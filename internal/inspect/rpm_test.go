@@ -0,0 +1,253 @@
+package inspect
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// entry is one tag of a test header; value is an int32 slice, a string or a
+// string slice.
+type entry struct {
+    tag   int32
+    typ   uint32
+    value interface{}
+}
+
+// buildHeader lays out a header structure the way rpm writes one.
+func buildHeader(entries []entry) []byte {
+    var index, data bytes.Buffer
+    for _, e := range entries {
+        offset := data.Len()
+        var count int
+        switch v := e.value.(type) {
+        case []int32:
+            for data.Len()%4 != 0 {
+                data.WriteByte(0)
+            }
+            offset, count = data.Len(), len(v)
+            binary.Write(&data, binary.BigEndian, v)
+        case string:
+            count = 1
+            data.WriteString(v + "\x00")
+        case []string:
+            count = len(v)
+            for _, s := range v {
+                data.WriteString(s + "\x00")
+            }
+        }
+        binary.Write(&index, binary.BigEndian, indexEntry{e.tag, e.typ, int32(offset), uint32(count)})
+    }
+    var h bytes.Buffer
+    h.Write(headerMagic)
+    h.Write(make([]byte, 4))
+    binary.Write(&h, binary.BigEndian, uint32(len(entries)))
+    binary.Write(&h, binary.BigEndian, uint32(data.Len()))
+    h.Write(index.Bytes())
+    h.Write(data.Bytes())
+    return h.Bytes()
+}
+
+// buildRPM wraps a main header in a lead and a padded signature header.
+func buildRPM(main []byte) []byte {
+    var b bytes.Buffer
+    b.Write(leadMagic)
+    b.Write(make([]byte, leadSize-len(leadMagic)))
+    sig := buildHeader([]entry{{1000, typeInt32, []int32{1234}}, {1004, typeString, "0123456789abcde"}})
+    b.Write(sig)
+    b.Write(make([]byte, (8-len(sig)%8)%8))
+    b.Write(main)
+    b.WriteString("PAYLOAD")
+    return b.Bytes()
+}
+
+var demoHeader = []entry{
+    {tagName, typeString, "demo-agent"},
+    {tagVersion, typeString, "2.4.1"},
+    {tagRelease, typeString, "3.el6"},
+    {tagEpoch, typeInt32, []int32{1}},
+    {tagSummary, typeI18NString, []string{"Demo agent"}},
+    {tagLicense, typeString, "GPLv2"},
+    {tagArch, typeString, "x86_64"},
+    {tagProvideName, typeStringArray, []string{"demo-agent", "libdemo.so.1()(64bit)"}},
+    {tagProvideFlags, typeInt32, []int32{senseEqual, 0}},
+    {tagProvideVersion, typeStringArray, []string{"1:2.4.1-3.el6", ""}},
+    {tagRequireName, typeStringArray, []string{"/bin/sh", "glibc", "rpmlib(CompressedFileNames)"}},
+    {tagRequireFlags, typeInt32, []int32{0, senseGreater | senseEqual, 0x1000000 | senseLess | senseEqual}},
+    {tagRequireVersion, typeStringArray, []string{"", "2.12", "3.0.4-1"}},
+    {tagDirIndexes, typeInt32, []int32{0, 1, 1}},
+    {tagBaseNames, typeStringArray, []string{"demo-agent", "demo.conf", "demo.d"}},
+    {tagDirNames, typeStringArray, []string{"/usr/bin/", "/etc/demo/"}},
+}
+
+func TestReadRPM(t *testing.T) {
+    info, err := ReadRPM(bytes.NewReader(buildRPM(buildHeader(demoHeader))))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := RPMInfo{
+        Name:     "demo-agent",
+        Epoch:    "1",
+        Version:  "2.4.1",
+        Release:  "3.el6",
+        Arch:     "x86_64",
+        Summary:  "Demo agent",
+        License:  "GPLv2",
+        Provides: []string{"demo-agent = 1:2.4.1-3.el6", "libdemo.so.1()(64bit)"},
+        Requires: []string{"/bin/sh", "glibc >= 2.12", "rpmlib(CompressedFileNames) <= 3.0.4-1"},
+        Files:    []string{"/usr/bin/demo-agent", "/etc/demo/demo.conf", "/etc/demo/demo.d"},
+    }
+    if !reflect.DeepEqual(info, want) {
+        t.Errorf("got %+v\nwant %+v", info, want)
+    }
+}
+
+func TestReadRPMNotRPM(t *testing.T) {
+    for _, data := range [][]byte{nil, []byte("PK\x03\x04"), bytes.Repeat([]byte{0}, 200)} {
+        if _, err := ReadRPM(bytes.NewReader(data)); !errors.Is(err, errNotRPM) {
+            t.Errorf("%q: err = %v, want errNotRPM", data[:min(len(data), 8)], err)
+        }
+    }
+}
+
+func TestReadRPMTruncated(t *testing.T) {
+    // cutting the package anywhere before the payload must fail cleanly
+    data := buildRPM(buildHeader(demoHeader))
+    end := len(data) - len("PAYLOAD")
+    for n := 0; n < end; n++ {
+        if _, err := ReadRPM(bytes.NewReader(data[:n])); err == nil {
+            t.Fatalf("truncated at %d of %d: no error", n, end)
+        }
+    }
+}
+
+func TestReadRPMMalicious(t *testing.T) {
+    // bad index entries are ignored rather than read out of bounds
+    valid := buildHeader(demoHeader)
+    indexAt := func(i int) int { return 16 + i*16 }
+    patch := func(i, field int, v uint32) []byte {
+        h := append([]byte(nil), valid...)
+        binary.BigEndian.PutUint32(h[indexAt(i)+field*4:], v)
+        return h
+    }
+    const (
+        fieldType   = 1
+        fieldOffset = 2
+        fieldCount  = 3
+    )
+    tests := []struct {
+        name   string
+        header []byte
+        err    string // substring of the expected error, "" when it parses
+        check  func(t *testing.T, info RPMInfo)
+    }{
+        {
+            name:   "huge index count",
+            header: func() []byte { h := append([]byte(nil), valid...); binary.BigEndian.PutUint32(h[8:], 0xffffffff); return h }(),
+            err:    "header too large",
+        },
+        {
+            name:   "huge data size",
+            header: func() []byte { h := append([]byte(nil), valid...); binary.BigEndian.PutUint32(h[12:], 0xffffffff); return h }(),
+            err:    "header too large",
+        },
+        {
+            name:   "bad header magic",
+            header: append([]byte{0, 0, 0, 0}, valid[4:]...),
+            err:    "bad header magic",
+        },
+        {
+            name:   "name offset past data",
+            header: patch(0, fieldOffset, 0x7fffffff),
+            err:    "no package name",
+        },
+        {
+            name:   "negative name offset",
+            header: patch(0, fieldOffset, 0xffffffff),
+            err:    "no package name",
+        },
+        {
+            name:   "name with wrong type",
+            header: patch(0, fieldType, typeInt32),
+            err:    "no package name",
+        },
+        {
+            name:   "int count past data",
+            header: patch(3, fieldCount, 0xffffffff),
+            check: func(t *testing.T, info RPMInfo) {
+                if info.Epoch != "" {
+                    t.Errorf("epoch = %q", info.Epoch)
+                }
+            },
+        },
+        {
+            name:   "negative int offset",
+            header: patch(3, fieldOffset, 0xfffffffc),
+            check: func(t *testing.T, info RPMInfo) {
+                if info.Epoch != "" {
+                    t.Errorf("epoch = %q", info.Epoch)
+                }
+            },
+        },
+        {
+            name:   "string array count past data",
+            header: patch(12, fieldCount, 0xffffffff),
+            check: func(t *testing.T, info RPMInfo) {
+                if want := []string{"/bin/sh", "glibc >= 2.12", "rpmlib(CompressedFileNames) <= 3.0.4-1"}; !reflect.DeepEqual(info.Requires, want) {
+                    t.Errorf("requires = %v, want %v", info.Requires, want)
+                }
+            },
+        },
+        {
+            name:   "dir names count past data",
+            header: patch(15, fieldCount, 0xffffffff),
+            check: func(t *testing.T, info RPMInfo) {
+                if len(info.Files) != 3 {
+                    t.Errorf("files = %v", info.Files)
+                }
+            },
+        },
+        {
+            name:   "dir index out of range",
+            header: patch(15, fieldCount, 1),
+            check: func(t *testing.T, info RPMInfo) {
+                if want := []string{"/usr/bin/demo-agent"}; !reflect.DeepEqual(info.Files, want) {
+                    t.Errorf("files = %v, want %v", info.Files, want)
+                }
+            },
+        },
+        {
+            name: "string without NUL",
+            header: func() []byte {
+                // drop the final NUL and shrink the data size to match
+                h := buildHeader([]entry{{tagName, typeString, "demo"}, {tagSummary, typeString, "x"}})
+                h = h[:len(h)-1]
+                binary.BigEndian.PutUint32(h[12:], binary.BigEndian.Uint32(h[12:])-1)
+                return h
+            }(),
+            check: func(t *testing.T, info RPMInfo) {
+                if info.Name != "demo" || info.Summary != "" {
+                    t.Errorf("info = %+v", info)
+                }
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            info, err := ReadRPM(bytes.NewReader(buildRPM(tt.header)))
+            if tt.err != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.err) {
+                    t.Fatalf("err = %v, want %q", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            tt.check(t, info)
+        })
+    }
+}
@@ -0,0 +1,91 @@
+package recipe
+
+import (
+    "fmt"
+    "path"
+    "sort"
+    "strings"
+)
+
+// checkArchives compares extract steps against the contents of the archives
+// they unpack: a creates path the archive does not produce is reported, and
+// steps without creates get a fix naming what the archive does produce.
+// issues are the findings so far; their missing-creates entries gain fixes.
+func checkArchives(r Recipe, opts Options, issues []Issue) []Issue {
+    var found []Issue
+    for i, s := range r.Steps {
+        cfg, _ := DecodeConfig(CurrentSchemaVersion, s)
+        var a ArchiveConfig
+        switch c := cfg.(type) {
+        case *ExtractTarGzConfig:
+            a = c.ArchiveConfig
+        case *ExtractZipConfig:
+            a = c.ArchiveConfig
+        default:
+            continue
+        }
+        name, ok := AssetName(a.Src)
+        if !ok || strings.ContainsAny(name, "*?[") || a.Dest == "" {
+            continue
+        }
+        paths, ok := opts.Archive(name)
+        if !ok || len(paths) == 0 {
+            continue
+        }
+        createsPath := pointer("steps", i, "config", "creates")
+        suggested := strings.TrimSuffix(a.Dest, "/") + "/" + archiveTop(paths)
+        fix := Fix{
+            Title: fmt.Sprintf("set creates to %s", suggested),
+            Patch: []PatchOp{{Op: "add", Path: createsPath, Value: suggested}},
+        }
+
+        if a.Creates == "" {
+            for j := range issues {
+                if issues[j].Rule == "missing-creates" && issues[j].Path == createsPath {
+                    issues[j].Suggestion = fmt.Sprintf("%s contains %s", name, archiveTop(paths))
+                    issues[j].Fixes = []Fix{fix}
+                }
+            }
+            continue
+        }
+        dest := path.Clean(expandVars(r.Vars, a.Dest))
+        creates := path.Clean(expandVars(r.Vars, a.Creates))
+        rel := strings.TrimPrefix(creates, strings.TrimSuffix(dest, "/")+"/")
+        if rel == creates || archiveHas(paths, rel) {
+            // creates outside dest may be made by a later step
+            continue
+        }
+        is := newIssue("creates-not-in-archive", s.ID, createsPath, fmt.Sprintf("%s does not contain %s, so creates %s never exists and the step re-runs", name, rel, a.Creates))
+        is.Suggestion = fmt.Sprintf("set creates to %s", suggested)
+        is.Fixes = []Fix{fix}
+        found = append(found, is)
+    }
+    return found
+}
+
+// archiveHas reports whether an archive listing contains rel as an entry or
+// as a directory some entry lies under.
+func archiveHas(paths []string, rel string) bool {
+    for _, p := range paths {
+        if p == rel || strings.HasPrefix(p, rel+"/") {
+            return true
+        }
+    }
+    return false
+}
+
+// archiveTop picks the path an archive is known by: its single top-level
+// directory, or else its first top-level entry in name order.
+func archiveTop(paths []string) string {
+    tops := map[string]bool{}
+    for _, p := range paths {
+        top, _, _ := strings.Cut(p, "/")
+        tops[top] = true
+    }
+    names := make([]string, 0, len(tops))
+    for t := range tops {
+        names = append(names, t)
+    }
+    sort.Strings(names)
+    return names[0]
+}
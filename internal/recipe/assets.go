@@ -0,0 +1,191 @@
+package recipe
+
+import (
+    "fmt"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// assetPrefixes are the forms a step may use to point into the bundle's assets directory.
+var assetPrefixes = []string{"${ASSET_DIR}/", "$ASSET_DIR/", "./assets/", "assets/"}
+
+// AssetName resolves a bundle-relative reference such as "$ASSET_DIR/app.tar.gz"
+// to the asset file name. It reports false for paths on the target host.
+func AssetName(ref string) (string, bool) {
+    for _, p := range assetPrefixes {
+        if strings.HasPrefix(ref, p) {
+            name := strings.TrimPrefix(ref, p)
+            if name == "" || strings.Contains(name, "/") {
+                return "", false
+            }
+            return name, true
+        }
+    }
+    return "", false
+}
+
+// AssetRef is a step config value that points into the bundle's assets.
+// Value is the reference as written, Name the asset it resolves to.
+type AssetRef struct {
+    StepID string
+    Path   string
+    Value  string
+    Name   string
+}
+
+// assetMention finds asset references inside free-form commands.
+var assetMention = regexp.MustCompile(`(?:\$\{ASSET_DIR\}|\$ASSET_DIR|\bassets)/([^\s"';|&<>()]+)`)
+
+// AssetRefs lists the asset references in the recipe's file fields
+// (copy, extract, rpm and service sources). Names may be globs such as "*.rpm".
+func AssetRefs(r Recipe) []AssetRef {
+    var refs []AssetRef
+    for i, s := range r.Steps {
+        cfg, _ := DecodeConfig(CurrentSchemaVersion, s)
+        add := func(key, value string, elem ...interface{}) {
+            if name, ok := AssetName(value); ok {
+                parts := append([]interface{}{"steps", i, "config", key}, elem...)
+                refs = append(refs, AssetRef{StepID: s.ID, Path: pointer(parts...), Value: value, Name: name})
+            }
+        }
+        switch c := cfg.(type) {
+        case *CopyConfig:
+            add("src", c.Src)
+        case *ExtractTarGzConfig:
+            add("src", c.Src)
+        case *ExtractZipConfig:
+            add("src", c.Src)
+        case *RpmInstallConfig:
+            for j, rpm := range c.Rpms {
+                add("rpms", rpm, j)
+            }
+        case *ServiceSysvConfig:
+            add("src", c.Src)
+        case *ServiceSystemdConfig:
+            add("src", c.Src)
+        case *AutoServiceConfig:
+            add("sysv_src", c.SysvSrc)
+            add("systemd_src", c.SystemdSrc)
+        }
+    }
+    return refs
+}
+
+// commandAssetRefs lists assets mentioned in run_cmd commands; they count as
+// used but are not required to exist, since a command may create them.
+func commandAssetRefs(r Recipe) []AssetRef {
+    var refs []AssetRef
+    for i, s := range r.Steps {
+        if c, ok := s.Config["cmd"].(string); ok && s.Type == "run_cmd" {
+            for _, m := range assetMention.FindAllStringSubmatch(c, -1) {
+                refs = append(refs, AssetRef{StepID: s.ID, Path: pointer("steps", i, "config", "cmd"), Value: m[0], Name: m[1]})
+            }
+        }
+    }
+    return refs
+}
+
+// AssetUsers lists the IDs of the steps that reference the asset, in step order.
+func AssetUsers(r Recipe, name string) []string {
+    var ids []string
+    for _, ref := range append(AssetRefs(r), commandAssetRefs(r)...) {
+        if matchAsset(ref.Name, name) && !contains(ids, ref.StepID) {
+            ids = append(ids, ref.StepID)
+        }
+    }
+    order := map[string]int{}
+    for i, s := range r.Steps {
+        order[s.ID] = i
+    }
+    sort.Slice(ids, func(i, j int) bool { return order[ids[i]] < order[ids[j]] })
+    return ids
+}
+
+// RenameAssetRefs rewrites references to asset oldName so they point at
+// newName, keeping each reference's prefix. Glob references are left alone.
+// It returns the updated recipe and the IDs of the steps it changed.
+func RenameAssetRefs(r Recipe, oldName, newName string) (Recipe, []string, error) {
+    ops, ids := renameAssetOps(r, oldName, newName)
+    if len(ops) == 0 {
+        return r, nil, nil
+    }
+    out, err := ApplyPatch(r, ops)
+    return out, ids, err
+}
+
+// renameAssetOps builds the patch RenameAssetRefs applies and the IDs of the
+// steps it touches.
+func renameAssetOps(r Recipe, oldName, newName string) ([]PatchOp, []string) {
+    var ops []PatchOp
+    var ids []string
+    for _, ref := range AssetRefs(r) {
+        if ref.Name != oldName {
+            continue
+        }
+        value := strings.TrimSuffix(ref.Value, oldName) + newName
+        ops = append(ops, PatchOp{Op: "replace", Path: ref.Path, Value: value})
+        if !contains(ids, ref.StepID) {
+            ids = append(ids, ref.StepID)
+        }
+    }
+    for i, s := range r.Steps {
+        cmd, ok := s.Config["cmd"].(string)
+        if !ok || s.Type != "run_cmd" {
+            continue
+        }
+        renamed := assetMention.ReplaceAllStringFunc(cmd, func(m string) string {
+            if strings.HasSuffix(m, "/"+oldName) {
+                return strings.TrimSuffix(m, oldName) + newName
+            }
+            return m
+        })
+        if renamed != cmd {
+            ops = append(ops, PatchOp{Op: "replace", Path: pointer("steps", i, "config", "cmd"), Value: renamed})
+            if !contains(ids, s.ID) {
+                ids = append(ids, s.ID)
+            }
+        }
+    }
+    return ops, ids
+}
+
+// matchAsset reports whether an asset reference, possibly a glob, matches name.
+func matchAsset(ref, name string) bool {
+    if ok, err := path.Match(ref, name); err == nil && ok {
+        return true
+    }
+    return ref == name
+}
+
+// validateAssets checks asset references against the uploaded asset names.
+func validateAssets(r Recipe, assets []string) []Issue {
+    var issues []Issue
+    used := map[string]bool{}
+    for _, ref := range AssetRefs(r) {
+        found := false
+        for _, a := range assets {
+            if matchAsset(ref.Name, a) {
+                used[a] = true
+                found = true
+            }
+        }
+        if !found {
+            issues = append(issues, newIssue("missing-asset", ref.StepID, ref.Path, fmt.Sprintf("asset %s has not been uploaded", ref.Name)))
+        }
+    }
+    for _, ref := range commandAssetRefs(r) {
+        for _, a := range assets {
+            if matchAsset(ref.Name, a) {
+                used[a] = true
+            }
+        }
+    }
+    for _, a := range assets {
+        if !used[a] {
+            issues = append(issues, newIssue("unused-asset", "", "", fmt.Sprintf("asset %s is not referenced by any step", a)))
+        }
+    }
+    return issues
+}
@@ -0,0 +1,326 @@
+package recipe
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+// StepConfig is the typed form of Step.Config. The wire format stays a plain
+// JSON object; DecodeConfig and EncodeConfig convert between the two.
+type StepConfig interface {
+    StepType() string
+}
+
+// FileMode is a chmod-style mode. It accepts a JSON string or, for older
+// recipes, a bare number such as 755.
+type FileMode string
+
+// UnmarshalJSON accepts both "0755" and 755.
+func (m *FileMode) UnmarshalJSON(data []byte) error {
+    var s string
+    if err := json.Unmarshal(data, &s); err == nil {
+        *m = FileMode(s)
+        return nil
+    }
+    var n json.Number
+    if err := json.Unmarshal(data, &n); err != nil {
+        return err
+    }
+    if _, err := n.Int64(); err != nil {
+        return fmt.Errorf("mode %s is not an integer", n)
+    }
+    *m = FileMode(n.String())
+    return nil
+}
+
+// MkdirConfig configures a mkdir step.
+type MkdirConfig struct {
+    Path string `json:"path"`
+}
+
+// CopyConfig configures a copy step.
+type CopyConfig struct {
+    Src       string   `json:"src"`
+    Dest      string   `json:"dest"`
+    Mode      FileMode `json:"mode,omitempty"`
+    Overwrite bool     `json:"overwrite,omitempty"`
+}
+
+// ChmodConfig configures a chmod step.
+type ChmodConfig struct {
+    Path string   `json:"path"`
+    Mode FileMode `json:"mode"`
+}
+
+// ChownConfig configures a chown step.
+type ChownConfig struct {
+    Path  string `json:"path"`
+    Owner string `json:"owner"`
+    Group string `json:"group,omitempty"`
+}
+
+// ArchiveConfig holds the fields shared by the extract steps.
+type ArchiveConfig struct {
+    Src     string `json:"src"`
+    Dest    string `json:"dest"`
+    Creates string `json:"creates,omitempty"`
+}
+
+// ExtractTarGzConfig configures an extract_tar_gz step.
+type ExtractTarGzConfig struct {
+    ArchiveConfig
+}
+
+// ExtractZipConfig configures an extract_zip step.
+type ExtractZipConfig struct {
+    ArchiveConfig
+}
+
+// RpmInstallConfig configures an rpm_install step.
+type RpmInstallConfig struct {
+    Rpms   []string `json:"rpms"`
+    Mode   string   `json:"mode"`
+    Nodeps bool     `json:"nodeps,omitempty"`
+}
+
+// BackupConfig holds the backup switch shared by the config edit steps.
+type BackupConfig struct {
+    Backup *bool `json:"backup,omitempty"`
+}
+
+// BackupEnabled reports whether the file is backed up before editing; unset means yes.
+func (b BackupConfig) BackupEnabled() bool {
+    return b.Backup == nil || *b.Backup
+}
+
+// AppendLinesConfig configures an append_lines step.
+type AppendLinesConfig struct {
+    File   string   `json:"file"`
+    Lines  []string `json:"lines"`
+    Unique bool     `json:"unique,omitempty"`
+    BackupConfig
+}
+
+// DeleteLinesConfig configures a delete_lines step.
+type DeleteLinesConfig struct {
+    File  string `json:"file"`
+    Match string `json:"match"`
+    Mode  string `json:"mode"`
+    BackupConfig
+}
+
+// ReplaceConfig configures a replace step.
+type ReplaceConfig struct {
+    File        string `json:"file"`
+    Pattern     string `json:"pattern"`
+    Replacement string `json:"replacement"`
+    Mode        string `json:"mode"`
+    BackupConfig
+}
+
+// RunCmdConfig configures a run_cmd step.
+type RunCmdConfig struct {
+    Cmd string `json:"cmd"`
+    Cwd string `json:"cwd,omitempty"`
+}
+
+// ServiceSysvConfig configures a service_sysv step.
+type ServiceSysvConfig struct {
+    Src   string `json:"src"`
+    Name  string `json:"name"`
+    Start bool   `json:"start,omitempty"`
+}
+
+// ServiceSystemdConfig configures a service_systemd step.
+type ServiceSystemdConfig struct {
+    Src   string `json:"src"`
+    Name  string `json:"name"`
+    Start bool   `json:"start,omitempty"`
+}
+
+// AutoServiceConfig configures an auto_service step.
+type AutoServiceConfig struct {
+    Name       string `json:"name"`
+    SysvSrc    string `json:"sysv_src"`
+    SystemdSrc string `json:"systemd_src"`
+    Start      bool   `json:"start,omitempty"`
+}
+
+func (MkdirConfig) StepType() string          { return "mkdir" }
+func (CopyConfig) StepType() string           { return "copy" }
+func (ChmodConfig) StepType() string          { return "chmod" }
+func (ChownConfig) StepType() string          { return "chown" }
+func (ExtractTarGzConfig) StepType() string   { return "extract_tar_gz" }
+func (ExtractZipConfig) StepType() string     { return "extract_zip" }
+func (RpmInstallConfig) StepType() string     { return "rpm_install" }
+func (AppendLinesConfig) StepType() string    { return "append_lines" }
+func (DeleteLinesConfig) StepType() string    { return "delete_lines" }
+func (ReplaceConfig) StepType() string        { return "replace" }
+func (RunCmdConfig) StepType() string         { return "run_cmd" }
+func (ServiceSysvConfig) StepType() string    { return "service_sysv" }
+func (ServiceSystemdConfig) StepType() string { return "service_systemd" }
+func (AutoServiceConfig) StepType() string    { return "auto_service" }
+
+// stepConfigs maps each schema version to the typed config of every step type.
+// A schema change that reshapes a config registers a new version here next to
+// its migration, so older documents keep decoding with the types they were written for.
+var stepConfigs = map[string]map[string]func() StepConfig{
+    "1.0": {
+        "mkdir":           func() StepConfig { return &MkdirConfig{} },
+        "copy":            func() StepConfig { return &CopyConfig{} },
+        "chmod":           func() StepConfig { return &ChmodConfig{} },
+        "chown":           func() StepConfig { return &ChownConfig{} },
+        "extract_tar_gz":  func() StepConfig { return &ExtractTarGzConfig{} },
+        "extract_zip":     func() StepConfig { return &ExtractZipConfig{} },
+        "rpm_install":     func() StepConfig { return &RpmInstallConfig{} },
+        "append_lines":    func() StepConfig { return &AppendLinesConfig{} },
+        "delete_lines":    func() StepConfig { return &DeleteLinesConfig{} },
+        "replace":         func() StepConfig { return &ReplaceConfig{} },
+        "run_cmd":         func() StepConfig { return &RunCmdConfig{} },
+        "service_sysv":    func() StepConfig { return &ServiceSysvConfig{} },
+        "service_systemd": func() StepConfig { return &ServiceSystemdConfig{} },
+        "auto_service":    func() StepConfig { return &AutoServiceConfig{} },
+    },
+}
+
+// KnownStepType reports whether the current schema defines stepType.
+func KnownStepType(stepType string) bool {
+    _, ok := stepConfigs[CurrentSchemaVersion][stepType]
+    return ok
+}
+
+// DecodeConfig decodes a step's config into the typed config that schema
+// version defines for its type. It returns a pointer to one of the *Config
+// types, or nil for unknown step types. Unknown keys and values of the wrong
+// type are reported as issues whose Path is relative to the step
+// ("/config/<key>"); such keys are left at their zero value.
+func DecodeConfig(version string, s Step) (StepConfig, []Issue) {
+    ctor, ok := stepConfigs[version][s.Type]
+    if !ok {
+        return nil, nil
+    }
+    cfg := ctor()
+    v := reflect.ValueOf(cfg).Elem()
+    fields := configFields(v.Type())
+
+    keys := make([]string, 0, len(s.Config))
+    for k := range s.Config {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+
+    var issues []Issue
+    for _, key := range keys {
+        idx, ok := fields[key]
+        if !ok {
+            msg := fmt.Sprintf("unknown config key %s", key)
+            if guess := closestKey(key, fields); guess != "" {
+                msg += fmt.Sprintf(" (did you mean %s?)", guess)
+            }
+            issues = append(issues, newIssue("unknown-config-key", s.ID, pointer("config", key), msg))
+            continue
+        }
+        raw, err := json.Marshal(s.Config[key])
+        if err == nil {
+            f := v.FieldByIndex(idx)
+            err = json.Unmarshal(raw, f.Addr().Interface())
+        }
+        if err != nil {
+            issues = append(issues, newIssue("config-type", s.ID, pointer("config", key), fmt.Sprintf("%s must be %s", key, describeType(v.Type().FieldByIndex(idx).Type))))
+        }
+    }
+    return cfg, issues
+}
+
+// EncodeConfig converts a typed config back to the wire form of Step.Config.
+func EncodeConfig(cfg StepConfig) (map[string]interface{}, error) {
+    buf, err := json.Marshal(cfg)
+    if err != nil {
+        return nil, err
+    }
+    var m map[string]interface{}
+    if err := json.Unmarshal(buf, &m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+// configFields maps JSON keys to struct field indexes, following embedded structs.
+func configFields(t reflect.Type) map[string][]int {
+    res := map[string][]int{}
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if f.Anonymous && f.Type.Kind() == reflect.Struct {
+            for k, idx := range configFields(f.Type) {
+                res[k] = append([]int{i}, idx...)
+            }
+            continue
+        }
+        name := strings.Split(f.Tag.Get("json"), ",")[0]
+        if name != "" && name != "-" {
+            res[name] = []int{i}
+        }
+    }
+    return res
+}
+
+func describeType(t reflect.Type) string {
+    if t == reflect.TypeOf(FileMode("")) {
+        return "a mode string such as \"0755\""
+    }
+    switch t.Kind() {
+    case reflect.Ptr:
+        return describeType(t.Elem())
+    case reflect.String:
+        return "a string"
+    case reflect.Bool:
+        return "a boolean"
+    case reflect.Slice:
+        return "a list of " + strings.TrimPrefix(describeType(t.Elem()), "a ") + "s"
+    }
+    return t.String()
+}
+
+// closestKey suggests the known key within two edits of key, if any.
+func closestKey(key string, fields map[string][]int) string {
+    best, bestDist := "", 3
+    for k := range fields {
+        if d := editDistance(key, k); d < bestDist || (d == bestDist && k < best) {
+            best, bestDist = k, d
+        }
+    }
+    return best
+}
+
+func editDistance(a, b string) int {
+    prev := make([]int, len(b)+1)
+    cur := make([]int, len(b)+1)
+    for j := range prev {
+        prev[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] {
+                cost = 0
+            }
+            cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(b)]
+}
+
+func minInt(vals ...int) int {
+    m := vals[0]
+    for _, v := range vals[1:] {
+        if v < m {
+            m = v
+        }
+    }
+    return m
+}
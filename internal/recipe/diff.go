@@ -0,0 +1,193 @@
+package recipe
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+)
+
+// StepChange describes how one step differs between two recipes.
+// Kind is one of added, removed, moved or changed; a step that was both
+// moved and edited yields one change of each kind.
+type StepChange struct {
+    Kind       string   `json:"kind"`
+    StepID     string   `json:"stepId"`
+    Name       string   `json:"name"`
+    OldIndex   int      `json:"oldIndex"`
+    NewIndex   int      `json:"newIndex"`
+    Fields     []string `json:"fields,omitempty"`
+    ConfigKeys []string `json:"configKeys,omitempty"`
+}
+
+// RecipeDiff is a semantic diff between two recipes.
+type RecipeDiff struct {
+    Project []string     `json:"project,omitempty"`
+    Vars    []string     `json:"vars,omitempty"`
+    Steps   []StepChange `json:"steps"`
+}
+
+// Diff compares two recipes, matching steps by ID. Indexes are -1 when the
+// step does not exist on that side.
+func Diff(a, b Recipe) RecipeDiff {
+    d := RecipeDiff{Steps: []StepChange{}}
+    d.Project = diffProject(a.Project, b.Project)
+    d.Vars = changedKeys(stringMap(a.Vars), stringMap(b.Vars))
+
+    oldKeys, newKeys := stepKeys(a.Steps), stepKeys(b.Steps)
+    oldIdx := map[string]int{}
+    for i, k := range oldKeys {
+        oldIdx[k] = i
+    }
+    newIdx := map[string]int{}
+    for i, k := range newKeys {
+        newIdx[k] = i
+    }
+
+    var oldCommon, newCommon []string
+    for _, k := range oldKeys {
+        if _, ok := newIdx[k]; ok {
+            oldCommon = append(oldCommon, k)
+        }
+    }
+    for _, k := range newKeys {
+        if _, ok := oldIdx[k]; ok {
+            newCommon = append(newCommon, k)
+        }
+    }
+    stable := lcs(oldCommon, newCommon)
+
+    for i, k := range oldKeys {
+        if _, ok := newIdx[k]; !ok {
+            s := a.Steps[i]
+            d.Steps = append(d.Steps, StepChange{Kind: "removed", StepID: s.ID, Name: s.Name, OldIndex: i, NewIndex: -1})
+        }
+    }
+    for j, k := range newKeys {
+        s := b.Steps[j]
+        i, ok := oldIdx[k]
+        if !ok {
+            d.Steps = append(d.Steps, StepChange{Kind: "added", StepID: s.ID, Name: s.Name, OldIndex: -1, NewIndex: j})
+            continue
+        }
+        if !stable[k] {
+            d.Steps = append(d.Steps, StepChange{Kind: "moved", StepID: s.ID, Name: s.Name, OldIndex: i, NewIndex: j})
+        }
+        old := a.Steps[i]
+        var fields []string
+        if old.Name != s.Name {
+            fields = append(fields, "name")
+        }
+        if old.Type != s.Type {
+            fields = append(fields, "type")
+        }
+        if !reflect.DeepEqual(old.Suppress, s.Suppress) {
+            fields = append(fields, "suppress")
+        }
+        keys := changedKeys(old.Config, s.Config)
+        if len(fields) > 0 || len(keys) > 0 {
+            d.Steps = append(d.Steps, StepChange{Kind: "changed", StepID: s.ID, Name: s.Name, OldIndex: i, NewIndex: j, Fields: fields, ConfigKeys: keys})
+        }
+    }
+    return d
+}
+
+// Empty reports whether the diff found no differences.
+func (d RecipeDiff) Empty() bool {
+    return len(d.Project) == 0 && len(d.Vars) == 0 && len(d.Steps) == 0
+}
+
+func diffProject(a, b ProjectMeta) []string {
+    var fields []string
+    if a.Name != b.Name {
+        fields = append(fields, "name")
+    }
+    if a.Description != b.Description {
+        fields = append(fields, "description")
+    }
+    if !reflect.DeepEqual(a.Target, b.Target) {
+        fields = append(fields, "target")
+    }
+    if a.Version != b.Version {
+        fields = append(fields, "version")
+    }
+    if a.License != b.License {
+        fields = append(fields, "license")
+    }
+    if a.URL != b.URL {
+        fields = append(fields, "url")
+    }
+    if a.Archived != b.Archived {
+        fields = append(fields, "archived")
+    }
+    return fields
+}
+
+// stepKeys returns a matching key per step: its ID, suffixed when IDs repeat.
+func stepKeys(steps []Step) []string {
+    seen := map[string]int{}
+    keys := make([]string, len(steps))
+    for i, s := range steps {
+        keys[i] = fmt.Sprintf("%s#%d", s.ID, seen[s.ID])
+        seen[s.ID]++
+    }
+    return keys
+}
+
+// lcs returns the keys on a longest common subsequence of a and b; steps
+// outside it are the ones that moved.
+func lcs(a, b []string) map[string]bool {
+    n, m := len(a), len(b)
+    dp := make([][]int, n+1)
+    for i := range dp {
+        dp[i] = make([]int, m+1)
+    }
+    for i := n - 1; i >= 0; i-- {
+        for j := m - 1; j >= 0; j-- {
+            if a[i] == b[j] {
+                dp[i][j] = dp[i+1][j+1] + 1
+            } else if dp[i+1][j] >= dp[i][j+1] {
+                dp[i][j] = dp[i+1][j]
+            } else {
+                dp[i][j] = dp[i][j+1]
+            }
+        }
+    }
+    res := map[string]bool{}
+    for i, j := 0, 0; i < n && j < m; {
+        switch {
+        case a[i] == b[j]:
+            res[a[i]] = true
+            i++
+            j++
+        case dp[i+1][j] >= dp[i][j+1]:
+            i++
+        default:
+            j++
+        }
+    }
+    return res
+}
+
+func changedKeys(a, b map[string]interface{}) []string {
+    var keys []string
+    for k, v := range a {
+        if w, ok := b[k]; !ok || !reflect.DeepEqual(v, w) {
+            keys = append(keys, k)
+        }
+    }
+    for k := range b {
+        if _, ok := a[k]; !ok {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+    res := make(map[string]interface{}, len(m))
+    for k, v := range m {
+        res[k] = v
+    }
+    return res
+}
@@ -0,0 +1,122 @@
+package recipe
+
+import (
+    "reflect"
+    "testing"
+)
+
+func step(id, name string, config map[string]interface{}) Step {
+    return Step{ID: id, Name: name, Type: "run_command", Config: config}
+}
+
+func TestDiff(t *testing.T) {
+    a := Recipe{
+        Project: ProjectMeta{Name: "demo", Target: []string{"oracle_linux_6_9"}},
+        Vars:    map[string]string{"A": "1", "B": "2"},
+        Steps: []Step{
+            step("s1", "one", map[string]interface{}{"cmd": "a"}),
+            step("s2", "two", map[string]interface{}{"cmd": "b"}),
+            step("s3", "three", map[string]interface{}{"cmd": "c"}),
+            step("s4", "four", nil),
+        },
+    }
+    tests := []struct {
+        name    string
+        edit    func(r *Recipe)
+        project []string
+        vars    []string
+        steps   []StepChange
+    }{
+        {
+            name:  "unchanged",
+            edit:  func(r *Recipe) {},
+            steps: []StepChange{},
+        },
+        {
+            name: "project and vars",
+            edit: func(r *Recipe) {
+                r.Project.Name = "renamed"
+                r.Project.Target = []string{"kylinsec_3_4"}
+                r.Vars = map[string]string{"A": "1", "B": "3", "C": "4"}
+            },
+            project: []string{"name", "target"},
+            vars:    []string{"B", "C"},
+            steps:   []StepChange{},
+        },
+        {
+            name: "added and removed",
+            edit: func(r *Recipe) {
+                r.Steps = []Step{r.Steps[0], r.Steps[2], r.Steps[3], step("s5", "five", nil)}
+            },
+            steps: []StepChange{
+                {Kind: "removed", StepID: "s2", Name: "two", OldIndex: 1, NewIndex: -1},
+                {Kind: "added", StepID: "s5", Name: "five", OldIndex: -1, NewIndex: 3},
+            },
+        },
+        {
+            name: "moved",
+            edit: func(r *Recipe) {
+                r.Steps = []Step{r.Steps[1], r.Steps[2], r.Steps[0], r.Steps[3]}
+            },
+            steps: []StepChange{
+                {Kind: "moved", StepID: "s1", Name: "one", OldIndex: 0, NewIndex: 2},
+            },
+        },
+        {
+            name: "changed",
+            edit: func(r *Recipe) {
+                r.Steps[1] = step("s2", "TWO", map[string]interface{}{"cmd": "b", "timeout": 5})
+                r.Steps[2].Type = "shell"
+                r.Steps[2].Config = map[string]interface{}{}
+            },
+            steps: []StepChange{
+                {Kind: "changed", StepID: "s2", Name: "TWO", OldIndex: 1, NewIndex: 1, Fields: []string{"name"}, ConfigKeys: []string{"timeout"}},
+                {Kind: "changed", StepID: "s3", Name: "three", OldIndex: 2, NewIndex: 2, Fields: []string{"type"}, ConfigKeys: []string{"cmd"}},
+            },
+        },
+        {
+            name: "moved and changed",
+            edit: func(r *Recipe) {
+                s := r.Steps[3]
+                s.Suppress = []Suppression{{Rule: "sec-curl-insecure"}}
+                r.Steps = []Step{s, r.Steps[0], r.Steps[1], r.Steps[2]}
+            },
+            steps: []StepChange{
+                {Kind: "moved", StepID: "s4", Name: "four", OldIndex: 3, NewIndex: 0},
+                {Kind: "changed", StepID: "s4", Name: "four", OldIndex: 3, NewIndex: 0, Fields: []string{"suppress"}},
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := a
+            b.Project.Target = append([]string(nil), a.Project.Target...)
+            b.Steps = append([]Step(nil), a.Steps...)
+            tt.edit(&b)
+            d := Diff(a, b)
+            if !reflect.DeepEqual(d.Project, tt.project) {
+                t.Errorf("project = %v, want %v", d.Project, tt.project)
+            }
+            if !reflect.DeepEqual(d.Vars, tt.vars) {
+                t.Errorf("vars = %v, want %v", d.Vars, tt.vars)
+            }
+            if !reflect.DeepEqual(d.Steps, tt.steps) {
+                t.Errorf("steps = %+v, want %+v", d.Steps, tt.steps)
+            }
+            if d.Empty() != (tt.project == nil && tt.vars == nil && len(tt.steps) == 0) {
+                t.Errorf("Empty() = %v", d.Empty())
+            }
+        })
+    }
+}
+
+func TestDiffDuplicateIDs(t *testing.T) {
+    // repeated IDs match by occurrence, so dropping the second copy removes
+    // only that one
+    a := Recipe{Steps: []Step{step("x", "first", nil), step("x", "second", nil)}}
+    b := Recipe{Steps: []Step{step("x", "first", nil)}}
+    want := []StepChange{{Kind: "removed", StepID: "x", Name: "second", OldIndex: 1, NewIndex: -1}}
+    if got := Diff(a, b).Steps; !reflect.DeepEqual(got, want) {
+        t.Errorf("steps = %+v, want %+v", got, want)
+    }
+}
@@ -0,0 +1,146 @@
+package recipe
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "path"
+    "strconv"
+    "strings"
+)
+
+// Fix is a machine-applicable change that resolves an issue.
+type Fix struct {
+    Title string    `json:"title"`
+    Patch []PatchOp `json:"patch"`
+}
+
+// issueID derives a stable ID from what the issue is about, so the same
+// finding keeps its ID across validation runs.
+func issueID(is Issue) string {
+    sum := sha256.Sum256([]byte(is.Rule + "\x00" + is.Path + "\x00" + is.Message))
+    return hex.EncodeToString(sum[:6])
+}
+
+// attachFixes adds an ID to every issue and fixes to those rules know how to
+// resolve mechanically. Checks that need project context attach their own.
+func attachFixes(r Recipe, issues []Issue) {
+    for i := range issues {
+        issues[i].ID = issueID(issues[i])
+        if issues[i].Fixes == nil {
+            issues[i].Fixes = fixesFor(r, issues[i])
+        }
+    }
+}
+
+func fixesFor(r Recipe, is Issue) []Fix {
+    idx, ok := stepIndex(is.Path)
+    if !ok && is.Rule != "unknown-target" {
+        return nil
+    }
+    var s Step
+    if ok {
+        s = r.Steps[idx]
+    }
+    stepPath := pointer("steps", idx)
+    switch is.Rule {
+    case "missing-creates":
+        dest, _ := s.Config["dest"].(string)
+        src, _ := s.Config["src"].(string)
+        if dest == "" || src == "" {
+            return nil
+        }
+        creates := strings.TrimSuffix(dest, "/") + "/" + archiveTopDir(src)
+        return []Fix{{Title: fmt.Sprintf("set creates to %s", creates), Patch: []PatchOp{{Op: "add", Path: is.Path, Value: creates}}}}
+    case "missing-cwd":
+        if _, ok := r.Vars["INSTALL_ROOT"]; !ok {
+            return nil
+        }
+        return []Fix{{Title: "add cwd: ${INSTALL_ROOT}", Patch: []PatchOp{{Op: "add", Path: is.Path, Value: "${INSTALL_ROOT}"}}}}
+    case "duplicate-step-id", "empty-step-id":
+        id := uniqueStepID(r, s.ID, idx)
+        return []Fix{{Title: fmt.Sprintf("rename step id to %s", id), Patch: []PatchOp{{Op: "replace", Path: stepPath + "/id", Value: id}}}}
+    case "unknown-config-key":
+        key := lastToken(is.Path)
+        guess := didYouMean(is.Message)
+        if guess == "" {
+            return []Fix{{Title: fmt.Sprintf("remove %s", key), Patch: []PatchOp{{Op: "remove", Path: is.Path}}}}
+        }
+        if _, taken := s.Config[guess]; taken {
+            return nil
+        }
+        return []Fix{{Title: fmt.Sprintf("rename %s to %s", key, guess), Patch: []PatchOp{
+            {Op: "remove", Path: is.Path},
+            {Op: "add", Path: stepPath + pointer("config", guess), Value: s.Config[key]},
+        }}}
+    case "invalid-enum":
+        mode := strings.ToLower(fmt.Sprintf("%v", s.Config["mode"]))
+        if contains(enumModes[s.Type], mode) {
+            return []Fix{{Title: fmt.Sprintf("set mode to %s", mode), Patch: []PatchOp{{Op: "replace", Path: is.Path, Value: mode}}}}
+        }
+    case "backup-disabled", "sec-system-file-no-backup":
+        return []Fix{{Title: "enable backup", Patch: []PatchOp{{Op: "remove", Path: stepPath + "/config/backup"}}}}
+    case "unknown-target":
+        return []Fix{{Title: "remove target", Patch: []PatchOp{{Op: "remove", Path: is.Path}}}}
+    }
+    return nil
+}
+
+// stepIndex extracts the step index from a /steps/<n>/... pointer.
+func stepIndex(p string) (int, bool) {
+    tokens, err := parsePointer(p)
+    if err != nil || len(tokens) < 2 || tokens[0] != "steps" {
+        return 0, false
+    }
+    i, err := strconv.Atoi(tokens[1])
+    return i, err == nil
+}
+
+func lastToken(p string) string {
+    tokens, _ := parsePointer(p)
+    if len(tokens) == 0 {
+        return ""
+    }
+    return tokens[len(tokens)-1]
+}
+
+// didYouMean extracts the suggestion DecodeConfig appends to unknown key messages.
+func didYouMean(msg string) string {
+    _, rest, ok := strings.Cut(msg, "(did you mean ")
+    if !ok {
+        return ""
+    }
+    return strings.TrimSuffix(rest, "?)")
+}
+
+// archiveTopDir guesses the directory an archive unpacks to from its file name.
+func archiveTopDir(src string) string {
+    name := path.Base(src)
+    for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+        if strings.HasSuffix(name, ext) {
+            return strings.TrimSuffix(name, ext)
+        }
+    }
+    return name
+}
+
+// uniqueStepID proposes an ID for step idx that no other step uses.
+func uniqueStepID(r Recipe, base string, idx int) string {
+    if base == "" {
+        base = fmt.Sprintf("step-%d", idx+1)
+    }
+    taken := map[string]bool{}
+    for i, s := range r.Steps {
+        if i != idx {
+            taken[s.ID] = true
+        }
+    }
+    if !taken[base] {
+        return base
+    }
+    for n := 2; ; n++ {
+        if id := fmt.Sprintf("%s-%d", base, n); !taken[id] {
+            return id
+        }
+    }
+}
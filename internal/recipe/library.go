@@ -0,0 +1,155 @@
+package recipe
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// LibraryRef pins a package of the shared library into the project. Its file
+// is available to steps as an asset, like an uploaded one.
+type LibraryRef struct {
+    Name    string `json:"name"`
+    Version string `json:"version"`
+}
+
+// LibraryItem is what validation needs to know about a library package.
+type LibraryItem struct {
+    Name            string `json:"name"`
+    Version         string `json:"version"`
+    Filename        string `json:"filename"`
+    Deprecated      bool   `json:"deprecated,omitempty"`
+    DeprecationNote string `json:"deprecationNote,omitempty"`
+}
+
+// FindLibraryItem returns the package with the given name and version.
+func FindLibraryItem(items []LibraryItem, name, version string) (LibraryItem, bool) {
+    for _, it := range items {
+        if it.Name == name && it.Version == version {
+            return it, true
+        }
+    }
+    return LibraryItem{}, false
+}
+
+// LatestLibraryItem returns the highest version of a package that is not deprecated.
+func LatestLibraryItem(items []LibraryItem, name string) (LibraryItem, bool) {
+    var best LibraryItem
+    found := false
+    for _, it := range items {
+        if it.Name != name || it.Deprecated {
+            continue
+        }
+        if !found || CompareVersions(it.Version, best.Version) > 0 {
+            best, found = it, true
+        }
+    }
+    return best, found
+}
+
+// LibraryFiles returns the file names the recipe's library pins resolve to.
+func LibraryFiles(r Recipe, items []LibraryItem) []string {
+    var names []string
+    for _, ref := range r.Library {
+        if it, ok := FindLibraryItem(items, ref.Name, ref.Version); ok {
+            names = append(names, it.Filename)
+        }
+    }
+    return names
+}
+
+// CompareVersions orders versions such as 1.8.0_202, 8u412 or 2.10 by
+// comparing their digit runs numerically and everything else as text.
+func CompareVersions(a, b string) int {
+    for a != "" && b != "" {
+        ca, ra := versionChunk(a)
+        cb, rb := versionChunk(b)
+        if c := compareChunk(ca, cb); c != 0 {
+            return c
+        }
+        a, b = ra, rb
+    }
+    switch {
+    case a != "":
+        return 1
+    case b != "":
+        return -1
+    }
+    return 0
+}
+
+// versionChunk splits off the leading run of digits or of non-digits.
+func versionChunk(s string) (string, string) {
+    digit := unicode.IsDigit(rune(s[0]))
+    i := 1
+    for i < len(s) && unicode.IsDigit(rune(s[i])) == digit {
+        i++
+    }
+    return s[:i], s[i:]
+}
+
+func compareChunk(a, b string) int {
+    na, errA := strconv.ParseUint(a, 10, 64)
+    nb, errB := strconv.ParseUint(b, 10, 64)
+    switch {
+    case errA == nil && errB == nil:
+        if na != nb {
+            if na < nb {
+                return -1
+            }
+            return 1
+        }
+        return 0
+    case errA == nil:
+        // a number sorts after text, so 1.0.1 > 1.0-rc1
+        return 1
+    case errB == nil:
+        return -1
+    }
+    return strings.Compare(a, b)
+}
+
+// checkLibrary checks the recipe's library pins against the library and
+// the project's own assets.
+func checkLibrary(r Recipe, opts Options) []Issue {
+    var issues []Issue
+    files := map[string]string{}
+    for _, a := range opts.Assets {
+        files[a] = "an uploaded asset"
+    }
+    for i, ref := range r.Library {
+        path := pointer("library", i)
+        if strings.TrimSpace(ref.Name) == "" || strings.TrimSpace(ref.Version) == "" {
+            issues = append(issues, newIssue("library-ref", "", path, "library entry needs a name and a version"))
+            continue
+        }
+        it, ok := FindLibraryItem(opts.Library, ref.Name, ref.Version)
+        if !ok {
+            issues = append(issues, newIssue("library-ref", "", path, fmt.Sprintf("library package %s %s does not exist", ref.Name, ref.Version)))
+            continue
+        }
+        if owner, taken := files[it.Filename]; taken {
+            issues = append(issues, newIssue("library-conflict", "", path, fmt.Sprintf("library file %s of %s %s clashes with %s", it.Filename, ref.Name, ref.Version, owner)))
+        } else {
+            files[it.Filename] = fmt.Sprintf("library package %s %s", ref.Name, ref.Version)
+        }
+        if !it.Deprecated {
+            continue
+        }
+        is := newIssue("library-deprecated", "", path, fmt.Sprintf("library package %s %s is deprecated", ref.Name, ref.Version))
+        is.Detail = it.DeprecationNote
+        if latest, ok := LatestLibraryItem(opts.Library, ref.Name); ok {
+            is.Suggestion = fmt.Sprintf("pin %s %s instead", latest.Name, latest.Version)
+            patch := []PatchOp{{Op: "replace", Path: path + "/version", Value: latest.Version}}
+            if latest.Filename != it.Filename {
+                // steps follow the new version's file name
+                ops, _ := renameAssetOps(r, it.Filename, latest.Filename)
+                patch = append(patch, ops...)
+            }
+            is.Fixes = []Fix{{Title: fmt.Sprintf("pin version %s", latest.Version), Patch: patch}}
+        }
+        issues = append(issues, is)
+    }
+    return issues
+}
@@ -0,0 +1,178 @@
+package recipe
+
+import (
+    "fmt"
+    "sort"
+)
+
+// Rule describes a validation check. Level is its default severity.
+type Rule struct {
+    ID      string `json:"id"`
+    Level   string `json:"level"`
+    Summary string `json:"summary"`
+}
+
+// Rules lists every check Validate runs. IDs are stable: recipes refer to
+// them in lint config and step suppressions.
+var Rules = []Rule{
+    {"unknown-target", "warn", "project target is not a known platform"},
+    {"empty-step-id", "error", "step id is empty"},
+    {"duplicate-step-id", "error", "step id is used by an earlier step"},
+    {"unknown-step-type", "warn", "step type is not defined by the schema"},
+    {"required-key", "error", "a required config key is missing or empty"},
+    {"invalid-enum", "error", "config value is not one of the allowed values"},
+    {"unknown-config-key", "error", "config key is not defined for the step type"},
+    {"config-type", "error", "config value has the wrong type"},
+    {"backup-disabled", "warn", "file edit runs without a backup"},
+    {"missing-creates", "warn", "extract step has no creates path to skip re-runs"},
+    {"missing-cwd", "warn", "run_cmd runs from the script directory"},
+    {"creates-not-in-archive", "warn", "creates path is not among the archive's contents"},
+    {"relative-path", "error", "target host path is not absolute"},
+    {"file-mode", "error", "mode is neither octal nor symbolic"},
+    {"account-name", "error", "user or group name is invalid"},
+    {"service-name", "error", "service name has characters init systems reject"},
+    {"invalid-regex", "error", "pattern is not a POSIX extended regex"},
+    {"unquoted-value", "error", "value breaks out of its shell quoting"},
+    {"sed-expression", "error", "value breaks the generated sed expression"},
+    {"heredoc-terminator", "error", "line ends the generated here-document"},
+    {"rpm-extension", "warn", "rpms entry does not name an .rpm file"},
+    {"rpm-unresolved", "warn", "RPM requirement is provided by neither the bundle nor the target's base packages"},
+    {"missing-asset", "error", "referenced asset has not been uploaded"},
+    {"unused-asset", "warn", "uploaded asset is not referenced by any step"},
+    {"lint-config", "warn", "lint config or suppression is invalid"},
+    {"library-ref", "error", "pinned library package does not exist"},
+    {"library-deprecated", "warn", "pinned library package version is deprecated"},
+    {"library-conflict", "error", "library file name clashes with another asset"},
+}
+
+// LintConfig adjusts rules for a project. Rules maps a rule ID to "error",
+// "warn" or "off".
+type LintConfig struct {
+    Rules map[string]string `json:"rules,omitempty"`
+}
+
+// Suppression silences one rule on a step. The justification is required
+// and is reported with the suppressed finding.
+type Suppression struct {
+    Rule          string `json:"rule"`
+    Justification string `json:"justification"`
+}
+
+// SuppressedIssue is a finding silenced by a step suppression.
+type SuppressedIssue struct {
+    Issue
+    Justification string `json:"justification"`
+}
+
+// LintReport holds the effective issues and the findings steps suppressed.
+type LintReport struct {
+    Issues     []Issue           `json:"issues"`
+    Suppressed []SuppressedIssue `json:"suppressed,omitempty"`
+}
+
+// Lint validates the recipe, then applies the project's severity overrides
+// and the steps' suppressions.
+func Lint(r Recipe, opts Options) LintReport {
+    var overrides map[string]string
+    if r.Lint != nil {
+        overrides = r.Lint.Rules
+    }
+    issues := append(check(r, opts), checkLintConfig(r)...)
+    attachFixes(r, issues)
+
+    suppressed := map[string]map[string]string{}
+    for _, s := range r.Steps {
+        for _, sup := range s.Suppress {
+            if sup.Justification == "" || s.ID == "" {
+                continue
+            }
+            if suppressed[s.ID] == nil {
+                suppressed[s.ID] = map[string]string{}
+            }
+            suppressed[s.ID][sup.Rule] = sup.Justification
+        }
+    }
+
+    var rep LintReport
+    for _, is := range issues {
+        if level, ok := overrides[is.Rule]; ok && validLevel(level) {
+            is.Level = level
+        }
+        if is.Level == "off" {
+            continue
+        }
+        if j, ok := suppressed[is.StepID][is.Rule]; ok && is.StepID != "" {
+            rep.Suppressed = append(rep.Suppressed, SuppressedIssue{Issue: is, Justification: j})
+            continue
+        }
+        rep.Issues = append(rep.Issues, is)
+    }
+    return rep
+}
+
+// checkLintConfig reports unknown rules, unknown levels and suppressions
+// without a justification; the latter are not honoured.
+func checkLintConfig(r Recipe) []Issue {
+    var issues []Issue
+    if r.Lint != nil {
+        ids := make([]string, 0, len(r.Lint.Rules))
+        for id := range r.Lint.Rules {
+            ids = append(ids, id)
+        }
+        sort.Strings(ids)
+        for _, id := range ids {
+            level := r.Lint.Rules[id]
+            path := pointer("lint", "rules", id)
+            if _, ok := ruleByID(id); !ok {
+                issues = append(issues, newIssue("lint-config", "", path, fmt.Sprintf("unknown rule %s", id)))
+            } else if !validLevel(level) {
+                issues = append(issues, newIssue("lint-config", "", path, fmt.Sprintf("level %s must be error, warn or off", level)))
+            }
+        }
+    }
+    for i, s := range r.Steps {
+        for j, sup := range s.Suppress {
+            if _, ok := ruleByID(sup.Rule); !ok {
+                issues = append(issues, newIssue("lint-config", s.ID, pointer("steps", i, "suppress", j, "rule"), fmt.Sprintf("unknown rule %s", sup.Rule)))
+            }
+            if sup.Justification == "" {
+                issues = append(issues, newIssue("lint-config", s.ID, pointer("steps", i, "suppress", j, "justification"), fmt.Sprintf("suppressing %s requires a justification", sup.Rule)))
+            }
+        }
+    }
+    return issues
+}
+
+// FindIssue returns the issue or suppressed finding with the given ID.
+func (rep LintReport) FindIssue(id string) (Issue, bool) {
+    for _, is := range rep.Issues {
+        if is.ID == id {
+            return is, true
+        }
+    }
+    for _, is := range rep.Suppressed {
+        if is.ID == id {
+            return is.Issue, true
+        }
+    }
+    return Issue{}, false
+}
+
+func ruleByID(id string) (Rule, bool) {
+    for _, r := range Rules {
+        if r.ID == id {
+            return r, true
+        }
+    }
+    return Rule{}, false
+}
+
+func validLevel(level string) bool {
+    return level == "error" || level == "warn" || level == "off"
+}
+
+// newIssue builds an issue at the rule's default level.
+func newIssue(rule, stepID, path, msg string) Issue {
+    r, _ := ruleByID(rule)
+    return Issue{Level: r.Level, Rule: rule, StepID: stepID, Path: path, Message: msg}
+}
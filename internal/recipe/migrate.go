@@ -0,0 +1,164 @@
+package recipe
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// CurrentSchemaVersion is the schema_version this build reads and writes.
+const CurrentSchemaVersion = "1.0"
+
+// legacySchemaVersion stands for recipes written before schema_version existed.
+const legacySchemaVersion = "0"
+
+// Migration upgrades a raw recipe document from one schema version to the next.
+type Migration struct {
+    From        string
+    To          string
+    Description string
+    Apply       func(doc map[string]interface{}) error
+}
+
+// migrations is the registry, keyed by the version each migration upgrades from.
+var migrations = map[string]Migration{}
+
+func register(m Migration) {
+    if _, dup := migrations[m.From]; dup {
+        panic("recipe: duplicate migration from schema " + m.From)
+    }
+    migrations[m.From] = m
+}
+
+func init() {
+    register(Migration{
+        From:        legacySchemaVersion,
+        To:          "1.0",
+        Description: "stamp schema_version and fill missing vars, steps and step configs",
+        Apply: func(doc map[string]interface{}) error {
+            if _, ok := doc["vars"].(map[string]interface{}); !ok {
+                doc["vars"] = map[string]interface{}{}
+            }
+            steps, ok := doc["steps"].([]interface{})
+            if !ok {
+                steps = []interface{}{}
+            }
+            for _, s := range steps {
+                if step, ok := s.(map[string]interface{}); ok {
+                    if _, ok := step["config"].(map[string]interface{}); !ok {
+                        step["config"] = map[string]interface{}{}
+                    }
+                }
+            }
+            doc["steps"] = steps
+            return nil
+        },
+    })
+}
+
+// MigrationReport lists the migrations applied, or that would be applied, to a recipe.
+type MigrationReport struct {
+    From    string   `json:"from"`
+    To      string   `json:"to"`
+    Applied []string `json:"applied"`
+}
+
+// Changed reports whether any migration ran.
+func (m MigrationReport) Changed() bool {
+    return len(m.Applied) > 0
+}
+
+// SchemaError reports a recipe whose schema_version cannot be migrated.
+type SchemaError struct {
+    Version string
+    Reason  string
+}
+
+func (e *SchemaError) Error() string {
+    return fmt.Sprintf("schema_version %s: %s", e.Version, e.Reason)
+}
+
+// Migrate decodes raw recipe JSON, upgrading it step by step to CurrentSchemaVersion.
+// Recipes from a newer or unknown schema are refused with a *SchemaError.
+func Migrate(data []byte) (Recipe, MigrationReport, error) {
+    var doc map[string]interface{}
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return Recipe{}, MigrationReport{}, err
+    }
+    version, _ := doc["schema_version"].(string)
+    if version == "" {
+        version = legacySchemaVersion
+    }
+    report := MigrationReport{From: version, To: version}
+    if cmp, ok := compareVersions(version, CurrentSchemaVersion); !ok {
+        return Recipe{}, report, &SchemaError{Version: version, Reason: "not a valid version"}
+    } else if cmp > 0 {
+        return Recipe{}, report, &SchemaError{Version: version, Reason: fmt.Sprintf("newer than supported %s; upgrade InstallForge", CurrentSchemaVersion)}
+    }
+    for version != CurrentSchemaVersion {
+        m, ok := migrations[version]
+        if !ok {
+            return Recipe{}, report, &SchemaError{Version: version, Reason: "no migration path to " + CurrentSchemaVersion}
+        }
+        if err := m.Apply(doc); err != nil {
+            return Recipe{}, report, fmt.Errorf("migrate %s to %s: %w", m.From, m.To, err)
+        }
+        doc["schema_version"] = m.To
+        report.Applied = append(report.Applied, fmt.Sprintf("%s -> %s: %s", m.From, m.To, m.Description))
+        version = m.To
+    }
+    report.To = version
+    if !report.Changed() {
+        var r Recipe
+        err := json.Unmarshal(data, &r)
+        return r, report, err
+    }
+    buf, err := json.Marshal(doc)
+    if err != nil {
+        return Recipe{}, report, err
+    }
+    var r Recipe
+    err = json.Unmarshal(buf, &r)
+    return r, report, err
+}
+
+// compareVersions compares dotted numeric versions, reporting false if either is malformed.
+func compareVersions(a, b string) (int, bool) {
+    pa, ok := parseVersion(a)
+    if !ok {
+        return 0, false
+    }
+    pb, ok := parseVersion(b)
+    if !ok {
+        return 0, false
+    }
+    for i := 0; i < len(pa) || i < len(pb); i++ {
+        var x, y int
+        if i < len(pa) {
+            x = pa[i]
+        }
+        if i < len(pb) {
+            y = pb[i]
+        }
+        if x != y {
+            if x < y {
+                return -1, true
+            }
+            return 1, true
+        }
+    }
+    return 0, true
+}
+
+func parseVersion(v string) ([]int, bool) {
+    var parts []int
+    for _, p := range strings.Split(v, ".") {
+        n, err := strconv.Atoi(p)
+        if err != nil || n < 0 {
+            return nil, false
+        }
+        parts = append(parts, n)
+    }
+    return parts, true
+}
@@ -0,0 +1,89 @@
+package recipe
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestMigrate(t *testing.T) {
+    tests := []struct {
+        name    string
+        doc     string
+        from    string
+        applied int
+        schema  bool // fails with a *SchemaError
+    }{
+        {"current", `{"schema_version":"1.0","project":{"id":"p"},"vars":{},"steps":[]}`, "1.0", 0, false},
+        {"legacy", `{"project":{"id":"p"},"steps":[{"id":"s1","type":"run_command"}]}`, "0", 1, false},
+        {"legacy without steps", `{"project":{"id":"p"}}`, "0", 1, false},
+        {"newer", `{"schema_version":"2.0","project":{"id":"p"}}`, "2.0", 0, true},
+        {"newer minor", `{"schema_version":"1.1","project":{"id":"p"}}`, "1.1", 0, true},
+        {"malformed", `{"schema_version":"v1","project":{"id":"p"}}`, "v1", 0, true},
+        {"no migration path", `{"schema_version":"0.5","project":{"id":"p"}}`, "0.5", 0, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r, report, err := Migrate([]byte(tt.doc))
+            var schemaErr *SchemaError
+            if tt.schema {
+                if !errors.As(err, &schemaErr) {
+                    t.Fatalf("err = %v, want a *SchemaError", err)
+                }
+                if schemaErr.Version != tt.from {
+                    t.Errorf("SchemaError.Version = %q, want %q", schemaErr.Version, tt.from)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if report.From != tt.from || report.To != CurrentSchemaVersion || len(report.Applied) != tt.applied {
+                t.Errorf("report = %+v", report)
+            }
+            if report.Changed() != (tt.applied > 0) {
+                t.Errorf("Changed() = %v", report.Changed())
+            }
+            if r.SchemaVersion != CurrentSchemaVersion {
+                t.Errorf("schema_version = %q", r.SchemaVersion)
+            }
+            if r.Vars == nil || r.Steps == nil {
+                t.Errorf("vars or steps left nil: %+v", r)
+            }
+            for _, s := range r.Steps {
+                if s.Config == nil {
+                    t.Errorf("step %s has no config", s.ID)
+                }
+            }
+        })
+    }
+}
+
+func TestMigrateInvalidJSON(t *testing.T) {
+    var schemaErr *SchemaError
+    if _, _, err := Migrate([]byte(`{"schema_version":`)); err == nil || errors.As(err, &schemaErr) {
+        t.Errorf("err = %v, want a JSON error", err)
+    }
+}
+
+func TestCompareVersions(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want int
+        ok   bool
+    }{
+        {"1.0", "1.0", 0, true},
+        {"1", "1.0", 0, true},
+        {"0", "1.0", -1, true},
+        {"1.10", "1.9", 1, true},
+        {"2.0", "10.0", -1, true},
+        {"1.x", "1.0", 0, false},
+        {"", "1.0", 0, false},
+        {"1.-1", "1.0", 0, false},
+    }
+    for _, tt := range tests {
+        got, ok := compareVersions(tt.a, tt.b)
+        if got != tt.want || ok != tt.ok {
+            t.Errorf("compareVersions(%q, %q) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+        }
+    }
+}
@@ -0,0 +1,262 @@
+package recipe
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// PatchOp is one JSON Patch (RFC 6902) operation.
+type PatchOp struct {
+    Op    string      `json:"op"`
+    Path  string      `json:"path"`
+    From  string      `json:"from,omitempty"`
+    Value interface{} `json:"value"`
+}
+
+// MarshalJSON writes value for every op that takes one, even when it is
+// false, 0, "" or null, and leaves it out of remove, move and copy.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+    switch op.Op {
+    case "remove", "move", "copy":
+        return json.Marshal(struct {
+            Op   string `json:"op"`
+            Path string `json:"path"`
+            From string `json:"from,omitempty"`
+        }{op.Op, op.Path, op.From})
+    }
+    type plain PatchOp
+    return json.Marshal(plain(op))
+}
+
+// ErrPatchTest is returned when a test operation does not match.
+var ErrPatchTest = errors.New("test operation failed")
+
+// ApplyPatch applies ops to the recipe's JSON form and decodes the result.
+// Either every op applies or the recipe is returned unchanged with an error.
+func ApplyPatch(r Recipe, ops []PatchOp) (Recipe, error) {
+    buf, err := json.Marshal(r)
+    if err != nil {
+        return r, err
+    }
+    var doc interface{}
+    if err := json.Unmarshal(buf, &doc); err != nil {
+        return r, err
+    }
+    for i, op := range ops {
+        doc, err = applyOp(doc, op)
+        if err != nil {
+            return r, fmt.Errorf("patch op %d (%s %s): %w", i, op.Op, op.Path, err)
+        }
+    }
+    buf, err = json.Marshal(doc)
+    if err != nil {
+        return r, err
+    }
+    var out Recipe
+    if err := json.Unmarshal(buf, &out); err != nil {
+        return r, err
+    }
+    return out, nil
+}
+
+func applyOp(doc interface{}, op PatchOp) (interface{}, error) {
+    switch op.Op {
+    case "test":
+        v, err := getValue(doc, op.Path)
+        if err != nil {
+            return nil, err
+        }
+        if !reflect.DeepEqual(v, normalize(op.Value)) {
+            return nil, ErrPatchTest
+        }
+        return doc, nil
+    case "copy", "move":
+        if op.Op == "move" && strings.HasPrefix(op.Path+"/", op.From+"/") {
+            if op.Path == op.From {
+                return doc, nil
+            }
+            return nil, fmt.Errorf("cannot move %s into itself", op.From)
+        }
+        v, err := getValue(doc, op.From)
+        if err != nil {
+            return nil, err
+        }
+        if op.Op == "move" {
+            if doc, err = applyOp(doc, PatchOp{Op: "remove", Path: op.From}); err != nil {
+                return nil, err
+            }
+        }
+        return applyOp(doc, PatchOp{Op: "add", Path: op.Path, Value: normalize(v)})
+    }
+    op.Value = normalize(op.Value)
+    tokens, err := parsePointer(op.Path)
+    if err != nil {
+        return nil, err
+    }
+    if len(tokens) == 0 {
+        switch op.Op {
+        case "add", "replace":
+            return op.Value, nil
+        case "remove":
+            return nil, fmt.Errorf("cannot remove the document root")
+        }
+        return nil, fmt.Errorf("unsupported op %s", op.Op)
+    }
+    return patchAt(doc, tokens, func(container interface{}, key string) (interface{}, error) {
+        switch op.Op {
+        case "add":
+            return addValue(container, key, op.Value)
+        case "remove":
+            return removeValue(container, key)
+        case "replace":
+            c, err := removeValue(container, key)
+            if err != nil {
+                return nil, err
+            }
+            return addValue(c, key, op.Value)
+        }
+        return nil, fmt.Errorf("unsupported op %s", op.Op)
+    })
+}
+
+// patchAt walks to the container addressed by all but the last token and
+// lets fn modify it. Slices may be reallocated, so every level is reassigned.
+func patchAt(node interface{}, tokens []string, fn func(container interface{}, key string) (interface{}, error)) (interface{}, error) {
+    if len(tokens) == 1 {
+        return fn(node, tokens[0])
+    }
+    switch n := node.(type) {
+    case map[string]interface{}:
+        child, ok := n[tokens[0]]
+        if !ok {
+            return nil, fmt.Errorf("%s does not exist", tokens[0])
+        }
+        c, err := patchAt(child, tokens[1:], fn)
+        if err != nil {
+            return nil, err
+        }
+        n[tokens[0]] = c
+        return n, nil
+    case []interface{}:
+        i, err := arrayIndex(tokens[0], len(n)-1)
+        if err != nil {
+            return nil, err
+        }
+        c, err := patchAt(n[i], tokens[1:], fn)
+        if err != nil {
+            return nil, err
+        }
+        n[i] = c
+        return n, nil
+    }
+    return nil, fmt.Errorf("%s is not an object or array", tokens[0])
+}
+
+func addValue(container interface{}, key string, value interface{}) (interface{}, error) {
+    switch c := container.(type) {
+    case map[string]interface{}:
+        c[key] = value
+        return c, nil
+    case []interface{}:
+        if key == "-" {
+            return append(c, value), nil
+        }
+        i, err := arrayIndex(key, len(c))
+        if err != nil {
+            return nil, err
+        }
+        c = append(c, nil)
+        copy(c[i+1:], c[i:])
+        c[i] = value
+        return c, nil
+    }
+    return nil, fmt.Errorf("cannot add %s to a scalar", key)
+}
+
+func removeValue(container interface{}, key string) (interface{}, error) {
+    switch c := container.(type) {
+    case map[string]interface{}:
+        if _, ok := c[key]; !ok {
+            return nil, fmt.Errorf("%s does not exist", key)
+        }
+        delete(c, key)
+        return c, nil
+    case []interface{}:
+        i, err := arrayIndex(key, len(c)-1)
+        if err != nil {
+            return nil, err
+        }
+        return append(c[:i], c[i+1:]...), nil
+    }
+    return nil, fmt.Errorf("cannot remove %s from a scalar", key)
+}
+
+// getValue returns the value a pointer addresses.
+func getValue(doc interface{}, path string) (interface{}, error) {
+    tokens, err := parsePointer(path)
+    if err != nil {
+        return nil, err
+    }
+    node := doc
+    for _, t := range tokens {
+        switch n := node.(type) {
+        case map[string]interface{}:
+            v, ok := n[t]
+            if !ok {
+                return nil, fmt.Errorf("%s does not exist", path)
+            }
+            node = v
+        case []interface{}:
+            i, err := arrayIndex(t, len(n)-1)
+            if err != nil {
+                return nil, err
+            }
+            node = n[i]
+        default:
+            return nil, fmt.Errorf("%s does not exist", path)
+        }
+    }
+    return node, nil
+}
+
+// normalize gives a value the shape encoding/json decodes into, so values
+// built in Go compare equal to decoded ones and are not shared between paths.
+func normalize(v interface{}) interface{} {
+    buf, err := json.Marshal(v)
+    if err != nil {
+        return v
+    }
+    var out interface{}
+    if err := json.Unmarshal(buf, &out); err != nil {
+        return v
+    }
+    return out
+}
+
+// arrayIndex parses an array index token no larger than max.
+func arrayIndex(token string, max int) (int, error) {
+    i, err := strconv.Atoi(token)
+    if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+        return 0, fmt.Errorf("invalid array index %s", token)
+    }
+    return i, nil
+}
+
+// parsePointer splits a JSON pointer into unescaped reference tokens.
+func parsePointer(path string) ([]string, error) {
+    if path == "" {
+        return nil, nil
+    }
+    if !strings.HasPrefix(path, "/") {
+        return nil, fmt.Errorf("pointer %s must start with /", path)
+    }
+    tokens := strings.Split(path[1:], "/")
+    for i, t := range tokens {
+        tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+    }
+    return tokens, nil
+}
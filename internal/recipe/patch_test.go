@@ -0,0 +1,136 @@
+package recipe
+
+import (
+    "encoding/json"
+    "errors"
+    "reflect"
+    "testing"
+)
+
+// applyDoc applies ops to a JSON document the way ApplyPatch does to a recipe.
+func applyDoc(doc string, ops []PatchOp) (interface{}, error) {
+    var d interface{}
+    if err := json.Unmarshal([]byte(doc), &d); err != nil {
+        return nil, err
+    }
+    for _, op := range ops {
+        var err error
+        if d, err = applyOp(d, op); err != nil {
+            return nil, err
+        }
+    }
+    return d, nil
+}
+
+func TestApplyOpRFC6902(t *testing.T) {
+    // the examples of RFC 6902 appendix A, plus edge cases of the pointer
+    // and array index rules
+    tests := []struct {
+        name string
+        doc  string
+        ops  string
+        want string // "" when the patch must fail
+    }{
+        {"A.1 add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+        {"A.2 add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+        {"A.3 remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+        {"A.4 remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+        {"A.5 replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+        {"A.6 move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+        {"A.7 move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+        {"A.8 test success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+        {"A.9 test error", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ""},
+        {"A.10 add nested member", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+        {"A.12 add to nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ""},
+        {"A.14 escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+        {"A.15 string is not number", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ""},
+        {"A.16 add array value", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+        {"add at array end", `{"foo":[1]}`, `[{"op":"add","path":"/foo/1","value":2}]`, `{"foo":[1,2]}`},
+        {"add past array end", `{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":2}]`, ""},
+        {"leading zero index", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/01"}]`, ""},
+        {"negative index", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/-1"}]`, ""},
+        {"remove missing member", `{"foo":1}`, `[{"op":"remove","path":"/bar"}]`, ""},
+        {"replace missing member", `{"foo":1}`, `[{"op":"replace","path":"/bar","value":2}]`, ""},
+        {"replace root", `{"foo":1}`, `[{"op":"replace","path":"","value":{"bar":2}}]`, `{"bar":2}`},
+        {"remove root", `{"foo":1}`, `[{"op":"remove","path":""}]`, ""},
+        {"pointer without slash", `{"foo":1}`, `[{"op":"add","path":"foo","value":2}]`, ""},
+        {"move into own child", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ""},
+        {"move onto itself", `{"a":1}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":1}`},
+        {"copy value", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+        {"copy is not shared", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+        {"add false value", `{"a":true}`, `[{"op":"add","path":"/a","value":false}]`, `{"a":false}`},
+        {"test null value", `{"a":null}`, `[{"op":"test","path":"/a","value":null}]`, `{"a":null}`},
+        {"unknown op", `{"a":1}`, `[{"op":"frobnicate","path":"/a"}]`, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var ops []PatchOp
+            if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+                t.Fatal(err)
+            }
+            got, err := applyDoc(tt.doc, ops)
+            if tt.want == "" {
+                if err == nil {
+                    t.Fatalf("patch applied, want error; got %v", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("patch failed: %v", err)
+            }
+            var want interface{}
+            json.Unmarshal([]byte(tt.want), &want)
+            if !reflect.DeepEqual(got, want) {
+                t.Errorf("got %v, want %v", got, want)
+            }
+        })
+    }
+}
+
+func TestApplyPatchAtomic(t *testing.T) {
+    r := NewEmptyRecipe("p1", "demo")
+    r.Vars["A"] = "1"
+    ops := []PatchOp{
+        {Op: "replace", Path: "/vars/A", Value: "2"},
+        {Op: "test", Path: "/vars/A", Value: "1"},
+    }
+    got, err := ApplyPatch(r, ops)
+    if !errors.Is(err, ErrPatchTest) {
+        t.Fatalf("err = %v, want ErrPatchTest", err)
+    }
+    if got.Vars["A"] != "1" || r.Vars["A"] != "1" {
+        t.Errorf("failed patch changed the recipe: %v", got.Vars)
+    }
+
+    got, err = ApplyPatch(r, ops[:1])
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Vars["A"] != "2" {
+        t.Errorf("vars = %v, want A=2", got.Vars)
+    }
+}
+
+func TestPatchOpMarshalJSON(t *testing.T) {
+    tests := []struct {
+        op   PatchOp
+        want string
+    }{
+        {PatchOp{Op: "add", Path: "/a", Value: false}, `{"op":"add","path":"/a","value":false}`},
+        {PatchOp{Op: "replace", Path: "/a", Value: 0}, `{"op":"replace","path":"/a","value":0}`},
+        {PatchOp{Op: "test", Path: "/a", Value: ""}, `{"op":"test","path":"/a","value":""}`},
+        {PatchOp{Op: "add", Path: "/a"}, `{"op":"add","path":"/a","value":null}`},
+        {PatchOp{Op: "remove", Path: "/a"}, `{"op":"remove","path":"/a"}`},
+        {PatchOp{Op: "move", From: "/a", Path: "/b"}, `{"op":"move","path":"/b","from":"/a"}`},
+        {PatchOp{Op: "copy", From: "/a", Path: "/b", Value: 1}, `{"op":"copy","path":"/b","from":"/a"}`},
+    }
+    for _, tt := range tests {
+        data, err := json.Marshal(tt.op)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if string(data) != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.op.Op, data, tt.want)
+        }
+    }
+}
@@ -8,6 +8,9 @@ type Recipe struct {
     Project       ProjectMeta   `json:"project"`
     Vars          map[string]string `json:"vars"`
     Steps         []Step        `json:"steps"`
+    Library       []LibraryRef  `json:"library,omitempty"`
+    Lint          *LintConfig   `json:"lint,omitempty"`
+    Revision      int           `json:"revision,omitempty"`
     UpdatedAt     time.Time     `json:"updatedAt"`
 }
 
@@ -17,6 +20,11 @@ type ProjectMeta struct {
     Name        string   `json:"name"`
     Description string   `json:"description"`
     Target      []string `json:"target"`
+    Version     string   `json:"version,omitempty"`
+    License     string   `json:"license,omitempty"`
+    URL         string   `json:"url,omitempty"`
+    // Archived projects are kept but left out of the project list.
+    Archived bool `json:"archived,omitempty"`
 }
 
 // Step defines a single action.
@@ -25,24 +33,38 @@ type Step struct {
     Name   string                 `json:"name"`
     Type   string                 `json:"type"`
     Config map[string]interface{} `json:"config"`
+    Suppress []Suppression        `json:"suppress,omitempty"`
 }
 
-// Issue represents validation issue.
+// Issue represents validation issue. Rule is the ID of the check that raised
+// it and Path is a JSON pointer into the recipe. Detail explains the risk and
+// Suggestion a safer alternative where the rule provides them. ID is stable
+// across runs and addresses the issue when applying one of its Fixes.
 type Issue struct {
+    ID string `json:"id"`
     Level string `json:"level"`
+    Rule string `json:"rule"`
     StepID string `json:"stepId"`
+    Path string `json:"path"`
     Message string `json:"message"`
+    Detail string `json:"detail,omitempty"`
+    Suggestion string `json:"suggestion,omitempty"`
+    Fixes []Fix `json:"fixes,omitempty"`
 }
 
+// KnownTargets lists the target platforms InstallForge generates scripts for.
+var KnownTargets = []string{"oracle_linux_6_9", "kylinsec_3_4"}
+
 // NewEmptyRecipe returns starter recipe.
 func NewEmptyRecipe(projectID, name string) Recipe {
     return Recipe{
-        SchemaVersion: "1.0",
+        SchemaVersion: CurrentSchemaVersion,
         Project: ProjectMeta{
             ID: projectID,
             Name: name,
             Description: "",
-            Target: []string{"oracle_linux_6_9", "kylinsec_3_4"},
+            Version: "1.0.0",
+            Target: append([]string(nil), KnownTargets...),
         },
         Vars: map[string]string{
             "INSTALL_ROOT": "/opt/demo",
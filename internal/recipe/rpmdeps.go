@@ -0,0 +1,466 @@
+package recipe
+
+import (
+    "embed"
+    "fmt"
+    "sort"
+    "strings"
+    "unicode"
+
+    "installforge/internal/inspect"
+)
+
+//go:embed baseos/*.txt
+var baseOS embed.FS
+
+// BasePackages returns the capabilities a minimal install of target provides,
+// one per entry, optionally versioned as "name = [epoch:]version-release".
+func BasePackages(target string) []string {
+    data, err := baseOS.ReadFile("baseos/" + target + ".txt")
+    if err != nil {
+        return nil
+    }
+    return ParseCapabilities(string(data))
+}
+
+// ParseCapabilities reads a base package list: one capability per line,
+// blank lines and # comments ignored.
+func ParseCapabilities(text string) []string {
+    var caps []string
+    for _, line := range strings.Split(text, "\n") {
+        line = strings.TrimSpace(line)
+        if line != "" && !strings.HasPrefix(line, "#") {
+            caps = append(caps, line)
+        }
+    }
+    return caps
+}
+
+// capability is a parsed provide or require such as "glibc >= 2.12".
+type capability struct {
+    name, op, evr string
+}
+
+func parseCapability(s string) capability {
+    f := strings.Fields(s)
+    if len(f) == 3 {
+        return capability{name: f[0], op: f[1], evr: f[2]}
+    }
+    return capability{name: strings.TrimSpace(s)}
+}
+
+// satisfies reports whether provide p fulfils requirement req.
+func (p capability) satisfies(req capability) bool {
+    if p.name != req.name {
+        return false
+    }
+    if req.op == "" || p.op == "" {
+        return true
+    }
+    // providers are versioned with "="; compare against the requirement
+    c := compareEVR(p.evr, req.evr)
+    switch req.op {
+    case "=":
+        return c == 0
+    case "<":
+        return c < 0
+    case "<=":
+        return c <= 0
+    case ">":
+        return c > 0
+    case ">=":
+        return c >= 0
+    }
+    return false
+}
+
+// compareEVR compares [epoch:]version[-release] strings the way rpm does.
+// A release missing from either side is not compared.
+func compareEVR(a, b string) int {
+    ea, va, ra := splitEVR(a)
+    eb, vb, rb := splitEVR(b)
+    if c := rpmvercmp(ea, eb); c != 0 {
+        return c
+    }
+    if c := rpmvercmp(va, vb); c != 0 {
+        return c
+    }
+    if ra == "" || rb == "" {
+        return 0
+    }
+    return rpmvercmp(ra, rb)
+}
+
+func splitEVR(s string) (epoch, version, release string) {
+    epoch = "0"
+    if i := strings.Index(s, ":"); i >= 0 {
+        epoch, s = s[:i], s[i+1:]
+    }
+    version = s
+    if i := strings.LastIndex(s, "-"); i >= 0 {
+        version, release = s[:i], s[i+1:]
+    }
+    return epoch, version, release
+}
+
+// rpmvercmp is rpm's version segment comparison: alphanumeric runs are
+// compared pairwise, numbers numerically and newer than letters, and "~"
+// sorts before anything.
+func rpmvercmp(a, b string) int {
+    if a == b {
+        return 0
+    }
+    isSep := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '~' }
+    for {
+        a = strings.TrimLeftFunc(a, isSep)
+        b = strings.TrimLeftFunc(b, isSep)
+        if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+            if !strings.HasPrefix(a, "~") {
+                return 1
+            }
+            if !strings.HasPrefix(b, "~") {
+                return -1
+            }
+            a, b = a[1:], b[1:]
+            continue
+        }
+        if a == "" || b == "" {
+            break
+        }
+        numeric := unicode.IsDigit(rune(a[0]))
+        var sa, sb string
+        sa, a = versionRun(a, numeric)
+        sb, b = versionRun(b, numeric)
+        if sb == "" {
+            // a number is newer than letters
+            if numeric {
+                return 1
+            }
+            return -1
+        }
+        if numeric {
+            sa = strings.TrimLeft(sa, "0")
+            sb = strings.TrimLeft(sb, "0")
+            if len(sa) != len(sb) {
+                if len(sa) > len(sb) {
+                    return 1
+                }
+                return -1
+            }
+        }
+        if c := strings.Compare(sa, sb); c != 0 {
+            return c
+        }
+    }
+    switch {
+    case a == "" && b == "":
+        return 0
+    case a == "":
+        return -1
+    }
+    return 1
+}
+
+// versionRun splits off the leading run of digits, or of letters.
+func versionRun(s string, digits bool) (string, string) {
+    i := 0
+    for i < len(s) {
+        r := rune(s[i])
+        if digits && !unicode.IsDigit(r) || !digits && !unicode.IsLetter(r) {
+            break
+        }
+        i++
+    }
+    return s[:i], s[i:]
+}
+
+// rpmPackage is an RPM of an rpm_install step with its parsed header.
+type rpmPackage struct {
+    ref   string // the rpms entry, with its asset prefix
+    index int    // position of the entry in rpms
+    info  inspect.RPMInfo
+}
+
+// provides lists what installing the package makes available, including
+// the package name itself and the files it installs.
+func (p rpmPackage) provides() []capability {
+    evr := p.info.Version + "-" + p.info.Release
+    if p.info.Epoch != "" {
+        evr = p.info.Epoch + ":" + evr
+    }
+    caps := []capability{{name: p.info.Name, op: "=", evr: evr}}
+    for _, s := range p.info.Provides {
+        caps = append(caps, parseCapability(s))
+    }
+    for _, f := range p.info.Files {
+        caps = append(caps, capability{name: f})
+    }
+    return caps
+}
+
+// requires lists the package's requirements; rpmlib() ones describe rpm
+// itself and are left out.
+func (p rpmPackage) requires() []capability {
+    var caps []capability
+    for _, s := range p.info.Requires {
+        if !strings.HasPrefix(s, "rpmlib(") {
+            caps = append(caps, parseCapability(s))
+        }
+    }
+    return caps
+}
+
+// stepRPMs resolves an rpm_install step's entries to packages, expanding
+// globs against assets. Entries that are not RPM assets with a readable
+// header, or globs matching any such file, are skipped.
+func stepRPMs(rpms []string, assets []string, lookup func(string) (inspect.RPMInfo, bool)) []rpmPackage {
+    var pkgs []rpmPackage
+    for i, ref := range rpms {
+        name, ok := AssetName(ref)
+        if !ok {
+            continue
+        }
+        prefix := strings.TrimSuffix(ref, name)
+        files := []string{name}
+        if strings.ContainsAny(name, "*?[") {
+            files = nil
+            for _, a := range assets {
+                if matchAsset(name, a) {
+                    files = append(files, a)
+                }
+            }
+            sort.Strings(files)
+        }
+        var entry []rpmPackage
+        for _, f := range files {
+            info, ok := lookup(f)
+            if !ok {
+                entry = nil
+                break
+            }
+            entry = append(entry, rpmPackage{ref: prefix + f, index: i, info: info})
+        }
+        pkgs = append(pkgs, entry...)
+    }
+    return pkgs
+}
+
+func satisfied(req capability, provided []capability) bool {
+    for _, p := range provided {
+        if p.satisfies(req) {
+            return true
+        }
+    }
+    return false
+}
+
+// checkRPMDeps resolves the requirements of the bundled RPMs against what
+// earlier and same-step packages provide and against each target's base
+// packages. Steps whose requirements all resolve get a fix to drop nodeps.
+func checkRPMDeps(r Recipe, opts Options, issues []Issue) []Issue {
+    var found []Issue
+    assets := append(append([]string(nil), opts.Assets...), LibraryFiles(r, opts.Library)...)
+    base := map[string][]capability{}
+    for _, t := range r.Project.Target {
+        for _, s := range opts.Base[t] {
+            base[t] = append(base[t], parseCapability(s))
+        }
+    }
+    var installed []capability
+    for i, s := range r.Steps {
+        cfg, _ := DecodeConfig(CurrentSchemaVersion, s)
+        c, ok := cfg.(*RpmInstallConfig)
+        if !ok || s.Type != "rpm_install" {
+            continue
+        }
+        pkgs := stepRPMs(c.Rpms, assets, opts.RPM)
+        provided := append([]capability(nil), installed...)
+        for _, p := range pkgs {
+            provided = append(provided, p.provides()...)
+        }
+        unresolved := 0
+        for _, p := range pkgs {
+            for _, req := range p.requires() {
+                if satisfied(req, provided) {
+                    continue
+                }
+                var missing []string
+                for _, t := range r.Project.Target {
+                    if _, known := opts.Base[t]; known && !satisfied(req, base[t]) {
+                        missing = append(missing, t)
+                    }
+                }
+                if len(missing) == 0 {
+                    continue
+                }
+                unresolved++
+                reqText := req.name
+                if req.op != "" {
+                    reqText += " " + req.op + " " + req.evr
+                }
+                is := newIssue("rpm-unresolved", s.ID, pointer("steps", i, "config", "rpms", p.index),
+                    fmt.Sprintf("%s requires %s, which neither the bundle nor the %s base packages provide", p.info.Name, reqText, strings.Join(missing, ", ")))
+                is.Suggestion = "upload an RPM that provides it, or add it to data/targets/<target>.txt if the target image has it"
+                found = append(found, is)
+            }
+        }
+        installed = provided
+        if unresolved > 0 || len(pkgs) == 0 || !c.Nodeps {
+            continue
+        }
+        for j := range issues {
+            if issues[j].Rule == "sec-rpm-nodeps" && issues[j].StepID == s.ID && issues[j].Fixes == nil {
+                issues[j].Suggestion = "every requirement resolves within the bundle and the base packages"
+                issues[j].Fixes = []Fix{{Title: "remove nodeps", Patch: []PatchOp{{Op: "remove", Path: pointer("steps", i, "config", "nodeps")}}}}
+            }
+        }
+    }
+    return found
+}
+
+// OrderRPMs returns a copy of the recipe whose rpm_install steps list their
+// packages in dependency order, providers first, with globs expanded. Steps
+// are left as written when RPM headers are unavailable; dependency cycles
+// keep their listed order.
+func OrderRPMs(r Recipe, opts Options) Recipe {
+    if opts.RPM == nil {
+        return r
+    }
+    assets := append(append([]string(nil), opts.Assets...), LibraryFiles(r, opts.Library)...)
+    steps := make([]Step, len(r.Steps))
+    copy(steps, r.Steps)
+    for i, s := range steps {
+        if s.Type != "rpm_install" {
+            continue
+        }
+        cfg, _ := DecodeConfig(CurrentSchemaVersion, s)
+        c, ok := cfg.(*RpmInstallConfig)
+        if !ok {
+            continue
+        }
+        pkgs := stepRPMs(c.Rpms, assets, opts.RPM)
+        if len(pkgs) == 0 {
+            continue
+        }
+        // the packages take the place of the first entry that resolved;
+        // entries that did not resolve stay where they were
+        resolved := map[int]bool{}
+        for _, p := range pkgs {
+            resolved[p.index] = true
+        }
+        var out []interface{}
+        for j, ref := range c.Rpms {
+            if !resolved[j] {
+                out = append(out, ref)
+                continue
+            }
+            if pkgs != nil {
+                for _, p := range orderPackages(pkgs) {
+                    out = append(out, p.ref)
+                }
+                pkgs = nil
+            }
+        }
+        cfgMap := make(map[string]interface{}, len(s.Config))
+        for k, v := range s.Config {
+            cfgMap[k] = v
+        }
+        cfgMap["rpms"] = out
+        steps[i].Config = cfgMap
+    }
+    r.Steps = steps
+    return r
+}
+
+// BundledRPMs lists the RPM asset files the recipe's rpm_install steps
+// install, in step order, with globs expanded against the project's assets
+// and pinned library files. Entries pointing at host paths are left out.
+func BundledRPMs(r Recipe, opts Options) []string {
+    assets := append(append([]string(nil), opts.Assets...), LibraryFiles(r, opts.Library)...)
+    seen := map[string]bool{}
+    var files []string
+    for _, s := range r.Steps {
+        cfg, _ := DecodeConfig(CurrentSchemaVersion, s)
+        c, ok := cfg.(*RpmInstallConfig)
+        if !ok || s.Type != "rpm_install" {
+            continue
+        }
+        for _, ref := range c.Rpms {
+            name, ok := AssetName(ref)
+            if !ok {
+                continue
+            }
+            matches := []string{name}
+            if strings.ContainsAny(name, "*?[") {
+                matches = nil
+                for _, a := range assets {
+                    if matchAsset(name, a) {
+                        matches = append(matches, a)
+                    }
+                }
+                sort.Strings(matches)
+            }
+            for _, f := range matches {
+                if !seen[f] {
+                    seen[f] = true
+                    files = append(files, f)
+                }
+            }
+        }
+    }
+    return files
+}
+
+// orderPackages sorts packages so each comes after the ones providing its
+// requirements, keeping the listed order where dependencies allow.
+func orderPackages(pkgs []rpmPackage) []rpmPackage {
+    n := len(pkgs)
+    provides := make([][]capability, n)
+    for k, q := range pkgs {
+        provides[k] = q.provides()
+    }
+    after := make([][]int, n) // after[j] lists packages j must follow
+    for j, p := range pkgs {
+        for _, req := range p.requires() {
+            for k := range pkgs {
+                if k != j && satisfied(req, provides[k]) {
+                    after[j] = append(after[j], k)
+                }
+            }
+        }
+    }
+    done := make([]bool, n)
+    var res []rpmPackage
+    for len(res) < n {
+        progressed := false
+        for j := 0; j < n; j++ {
+            if done[j] {
+                continue
+            }
+            ready := true
+            for _, k := range after[j] {
+                if !done[k] {
+                    ready = false
+                    break
+                }
+            }
+            if ready {
+                done[j] = true
+                res = append(res, pkgs[j])
+                progressed = true
+                break
+            }
+        }
+        if !progressed {
+            // a cycle: take the first remaining package as listed
+            for j := 0; j < n; j++ {
+                if !done[j] {
+                    done[j] = true
+                    res = append(res, pkgs[j])
+                    break
+                }
+            }
+        }
+    }
+    return res
+}
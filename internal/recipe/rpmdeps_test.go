@@ -0,0 +1,205 @@
+package recipe
+
+import (
+    "reflect"
+    "testing"
+
+    "installforge/internal/inspect"
+)
+
+func TestRpmvercmp(t *testing.T) {
+    // the vectors of rpm's tests/rpmvercmp.at; the caret cases are left out,
+    // since "^" came with rpm 4.15 and the targets' rpm does not know it
+    tests := []struct {
+        a, b string
+        want int
+    }{
+        {"1.0", "1.0", 0},
+        {"1.0", "2.0", -1},
+        {"2.0", "1.0", 1},
+        {"2.0.1", "2.0.1", 0},
+        {"2.0", "2.0.1", -1},
+        {"2.0.1", "2.0", 1},
+        {"2.0.1a", "2.0.1a", 0},
+        {"2.0.1a", "2.0.1", 1},
+        {"2.0.1", "2.0.1a", -1},
+        {"5.5p1", "5.5p1", 0},
+        {"5.5p1", "5.5p2", -1},
+        {"5.5p2", "5.5p1", 1},
+        {"5.5p10", "5.5p10", 0},
+        {"5.5p1", "5.5p10", -1},
+        {"5.5p10", "5.5p1", 1},
+        {"10xyz", "10.1xyz", -1},
+        {"10.1xyz", "10xyz", 1},
+        {"xyz10", "xyz10", 0},
+        {"xyz10", "xyz10.1", -1},
+        {"xyz10.1", "xyz10", 1},
+        {"xyz.4", "xyz.4", 0},
+        {"xyz.4", "8", -1},
+        {"8", "xyz.4", 1},
+        {"xyz.4", "2", -1},
+        {"2", "xyz.4", 1},
+        {"5.5p2", "5.6p1", -1},
+        {"5.6p1", "5.5p2", 1},
+        {"5.6p1", "6.5p1", -1},
+        {"6.5p1", "5.6p1", 1},
+        {"6.0.rc1", "6.0", 1},
+        {"6.0", "6.0.rc1", -1},
+        {"10b2", "10a1", 1},
+        {"10a2", "10b2", -1},
+        {"1.0aa", "1.0aa", 0},
+        {"1.0a", "1.0aa", -1},
+        {"1.0aa", "1.0a", 1},
+        {"10.0001", "10.0001", 0},
+        {"10.0001", "10.1", 0},
+        {"10.1", "10.0001", 0},
+        {"10.0001", "10.0039", -1},
+        {"10.0039", "10.0001", 1},
+        {"4.999.9", "5.0", -1},
+        {"5.0", "4.999.9", 1},
+        {"20101121", "20101121", 0},
+        {"20101121", "20101122", -1},
+        {"20101122", "20101121", 1},
+        {"2_0", "2_0", 0},
+        {"2.0", "2_0", 0},
+        {"2_0", "2.0", 0},
+        {"a", "a", 0},
+        {"a+", "a+", 0},
+        {"a+", "a_", 0},
+        {"a_", "a+", 0},
+        {"+a", "+a", 0},
+        {"+a", "_a", 0},
+        {"_a", "+a", 0},
+        {"+_", "+_", 0},
+        {"_+", "+_", 0},
+        {"_+", "_+", 0},
+        {"+", "_", 0},
+        {"_", "+", 0},
+        {"1.0~rc1", "1.0~rc1", 0},
+        {"1.0~rc1", "1.0", -1},
+        {"1.0", "1.0~rc1", 1},
+        {"1.0~rc1", "1.0~rc2", -1},
+        {"1.0~rc2", "1.0~rc1", 1},
+        {"1.0~rc1~git123", "1.0~rc1~git123", 0},
+        {"1.0~rc1~git123", "1.0~rc1", -1},
+        {"1.0~rc1", "1.0~rc1~git123", 1},
+        {"1b.fc17", "1b.fc17", 0},
+        {"1b.fc17", "1.fc17", -1},
+        {"1.fc17", "1b.fc17", 1},
+        {"1g.fc17", "1g.fc17", 0},
+        {"1g.fc17", "1.fc17", 1},
+        {"1.fc17", "1g.fc17", -1},
+    }
+    for _, tt := range tests {
+        if got := rpmvercmp(tt.a, tt.b); got != tt.want {
+            t.Errorf("rpmvercmp(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
+
+func TestCompareEVR(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want int
+    }{
+        {"1.0-1", "1.0-1", 0},
+        {"1:1.0-1", "2.0-1", 1},
+        {"0:1.0-1", "1.0-1", 0},
+        {"1.0-2", "1.0-10", -1},
+        {"2.12-1.107.el6", "2.12", 0},
+        {"2.12", "2.12-1.107.el6", 0},
+        {"2.11-9", "2.12", -1},
+        {"1.0-1.el6", "1.0-1.el6_9", -1},
+    }
+    for _, tt := range tests {
+        if got := compareEVR(tt.a, tt.b); got != tt.want {
+            t.Errorf("compareEVR(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
+
+func TestCapabilitySatisfies(t *testing.T) {
+    tests := []struct {
+        provide, require string
+        want             bool
+    }{
+        {"glibc = 2.12-1.107.el6", "glibc >= 2.12", true},
+        {"glibc = 2.12-1.107.el6", "glibc > 2.12", false},
+        {"glibc = 2.12-1.107.el6", "glibc < 2.13", true},
+        {"glibc = 2.12-1.107.el6", "glibc = 2.12-1.107.el6", true},
+        {"glibc = 2.12-1.107.el6", "glibc <= 2.11", false},
+        {"glibc", "glibc >= 2.12", true},
+        {"glibc = 2.12-1", "glibc", true},
+        {"glibc = 2.12-1", "glibc-common", false},
+        {"libfoo.so.1()(64bit)", "libfoo.so.1()(64bit)", true},
+    }
+    for _, tt := range tests {
+        if got := parseCapability(tt.provide).satisfies(parseCapability(tt.require)); got != tt.want {
+            t.Errorf("%q satisfies %q = %v, want %v", tt.provide, tt.require, got, tt.want)
+        }
+    }
+}
+
+func pkg(name string, provides, requires, files []string) rpmPackage {
+    return rpmPackage{ref: "assets/" + name + ".rpm", info: inspect.RPMInfo{Name: name, Version: "1.0", Release: "1", Provides: provides, Requires: requires, Files: files}}
+}
+
+func TestOrderPackages(t *testing.T) {
+    tests := []struct {
+        name string
+        pkgs []rpmPackage
+        want []string
+    }{
+        {
+            name: "listed order kept",
+            pkgs: []rpmPackage{pkg("a", nil, nil, nil), pkg("b", nil, nil, nil)},
+            want: []string{"a", "b"},
+        },
+        {
+            name: "package name",
+            pkgs: []rpmPackage{pkg("app", nil, []string{"lib >= 1.0"}, nil), pkg("lib", nil, nil, nil)},
+            want: []string{"lib", "app"},
+        },
+        {
+            name: "provide",
+            pkgs: []rpmPackage{pkg("app", nil, []string{"libfoo.so.1()(64bit)"}, nil), pkg("foo", []string{"libfoo.so.1()(64bit)"}, nil, nil)},
+            want: []string{"foo", "app"},
+        },
+        {
+            name: "file",
+            pkgs: []rpmPackage{pkg("app", nil, []string{"/usr/bin/tool"}, nil), pkg("tools", nil, nil, []string{"/usr/bin/tool"})},
+            want: []string{"tools", "app"},
+        },
+        {
+            name: "chain",
+            pkgs: []rpmPackage{pkg("c", nil, []string{"b"}, nil), pkg("b", nil, []string{"a"}, nil), pkg("a", nil, nil, nil)},
+            want: []string{"a", "b", "c"},
+        },
+        {
+            name: "version not met",
+            pkgs: []rpmPackage{pkg("app", nil, []string{"lib >= 2.0"}, nil), pkg("lib", nil, nil, nil)},
+            want: []string{"app", "lib"},
+        },
+        {
+            name: "cycle",
+            pkgs: []rpmPackage{pkg("x", nil, []string{"y"}, nil), pkg("y", nil, []string{"x"}, nil), pkg("z", nil, nil, nil)},
+            want: []string{"z", "x", "y"},
+        },
+        {
+            name: "rpmlib ignored",
+            pkgs: []rpmPackage{pkg("a", nil, []string{"rpmlib(CompressedFileNames) <= 3.0.4-1"}, nil), pkg("rpmlib", nil, nil, nil)},
+            want: []string{"a", "rpmlib"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got []string
+            for _, p := range orderPackages(tt.pkgs) {
+                got = append(got, p.info.Name)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("order = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,195 @@
+package recipe
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// securityRules flag recipe patterns that should never reach a customer
+// unreviewed. Their issues carry a Detail and a safer Suggestion.
+var securityRules = []Rule{
+    {"sec-world-writable", "warn", "mode makes a file world-writable"},
+    {"sec-rpm-nodeps", "warn", "rpm_install skips dependency checks"},
+    {"sec-pipe-to-shell", "warn", "run_cmd pipes a download into a shell"},
+    {"sec-rm-rf-variable", "warn", "run_cmd runs rm -rf on a variable path"},
+    {"sec-selinux-disabled", "warn", "step disables SELinux"},
+    {"sec-auth-file", "warn", "step edits sudoers or account databases"},
+    {"sec-system-file-no-backup", "warn", "system file is edited without a backup"},
+}
+
+func init() {
+    Rules = append(Rules, securityRules...)
+}
+
+var (
+    pipeToShellRe = regexp.MustCompile(`\b(curl|wget)\b[^;&|\n]*\|\s*(sudo\s+)?(ba|z|k|da)?sh\b`)
+    rmRe          = regexp.MustCompile(`\brm\s+((?:-{1,2}[A-Za-z-]+\s+)+)([^;&|\n]*)`)
+    setenforceRe  = regexp.MustCompile(`\bsetenforce\s+(0|[Pp]ermissive)\b`)
+    selinuxOffRe  = regexp.MustCompile(`SELINUX=(disabled|permissive)`)
+)
+
+const (
+    selinuxDetail     = "Disabling SELinux removes mandatory access control for every service on the host."
+    selinuxSuggestion = "Keep SELinux enforcing and ship the policy the application needs (semanage fcontext, setsebool or a policy module)."
+)
+
+// authFiles are files whose edits change who can log in or become root.
+var authFiles = []string{"/etc/sudoers", "/etc/passwd", "/etc/shadow", "/etc/group", "/etc/gshadow"}
+
+// systemDirs hold files owned by the OS rather than the installed application.
+var systemDirs = []string{"/etc/", "/usr/", "/boot/", "/lib/", "/lib64/", "/bin/", "/sbin/", "/var/lib/"}
+
+// checkSecurity runs the security rule pack.
+func checkSecurity(r Recipe) []Issue {
+    var issues []Issue
+    c := configChecker{vars: r.Vars}
+    for i, s := range r.Steps {
+        cfg, _ := DecodeConfig(CurrentSchemaVersion, s)
+        add := func(rule, key, msg, detail, suggestion string) {
+            is := newIssue(rule, s.ID, pointer("steps", i, "config", key), msg)
+            is.Detail = detail
+            is.Suggestion = suggestion
+            issues = append(issues, is)
+        }
+        worldWritable := func(mode FileMode) {
+            if isWorldWritable(string(mode)) {
+                add("sec-world-writable", "mode", fmt.Sprintf("mode %s is world-writable", mode),
+                    "Any local user can modify the file, for example to plant code that root later runs.",
+                    "Grant write to the owner or group only, e.g. 0755 for directories and executables or 0644 for files.")
+            }
+        }
+        authFile := func(key, file string) {
+            if isAuthFile(c.expand(file)) {
+                add("sec-auth-file", key, fmt.Sprintf("%s edits %s", s.Type, file),
+                    "A malformed line in this file can lock out every user or grant root to the wrong account.",
+                    "Drop a file into /etc/sudoers.d validated with visudo -cf, or manage accounts with useradd/usermod in a run_cmd step.")
+            }
+        }
+        noBackup := func(file string, b BackupConfig) {
+            if !b.BackupEnabled() && isSystemFile(c.expand(file)) {
+                add("sec-system-file-no-backup", "backup", fmt.Sprintf("%s is a system file and is edited without a backup", file),
+                    "Without a backup a bad edit cannot be undone by uninstall.sh or by hand.",
+                    "Remove backup: false so the step keeps a timestamped .bak copy.")
+            }
+        }
+        selinux := func(key string, values ...string) {
+            for _, v := range values {
+                if selinuxOffRe.MatchString(v) {
+                    add("sec-selinux-disabled", key, "step disables SELinux in /etc/selinux/config",
+                        selinuxDetail, selinuxSuggestion)
+                    return
+                }
+            }
+        }
+
+        switch cfg := cfg.(type) {
+        case *ChmodConfig:
+            worldWritable(cfg.Mode)
+        case *CopyConfig:
+            worldWritable(cfg.Mode)
+            authFile("dest", cfg.Dest)
+        case *RpmInstallConfig:
+            if cfg.Nodeps {
+                add("sec-rpm-nodeps", "nodeps", "rpm_install runs with --nodeps",
+                    "Packages installed without their dependencies fail at run time and leave the rpm database inconsistent.",
+                    "Ship the missing dependencies as assets in the same rpm_install step instead of using nodeps.")
+            }
+        case *AppendLinesConfig:
+            authFile("file", cfg.File)
+            noBackup(cfg.File, cfg.BackupConfig)
+            selinux("lines", cfg.Lines...)
+        case *DeleteLinesConfig:
+            authFile("file", cfg.File)
+            noBackup(cfg.File, cfg.BackupConfig)
+        case *ReplaceConfig:
+            authFile("file", cfg.File)
+            noBackup(cfg.File, cfg.BackupConfig)
+            selinux("replacement", cfg.Replacement)
+        case *RunCmdConfig:
+            if pipeToShellRe.MatchString(cfg.Cmd) {
+                add("sec-pipe-to-shell", "cmd", "cmd pipes a download into a shell",
+                    "The script runs whatever the server returns, unverified and as root; offline targets also cannot reach it.",
+                    "Upload the script as an asset, check its checksum and run it from $ASSET_DIR.")
+            }
+            if rmVariablePath(cfg.Cmd) {
+                add("sec-rm-rf-variable", "cmd", "cmd runs rm -rf on a path built from a variable",
+                    "If the variable is empty or unset the command removes a parent directory, possibly /.",
+                    "Use ${VAR:?} so an empty variable aborts the command, or remove a fixed absolute path.")
+            }
+            if setenforceRe.MatchString(cfg.Cmd) {
+                add("sec-selinux-disabled", "cmd", "cmd switches SELinux to permissive",
+                    selinuxDetail, selinuxSuggestion)
+            }
+        }
+    }
+    return issues
+}
+
+// isWorldWritable reports whether an octal or symbolic mode grants write to others.
+func isWorldWritable(mode string) bool {
+    if octalModeRe.MatchString(mode) {
+        return (mode[len(mode)-1]-'0')&2 != 0
+    }
+    if !symbolicModeRe.MatchString(mode) {
+        return false
+    }
+    for _, clause := range strings.Split(mode, ",") {
+        ops := strings.TrimLeft(clause, "ugoa")
+        who := clause[:len(clause)-len(ops)]
+        if who != "" && !strings.ContainsAny(who, "oa") {
+            continue
+        }
+        var op rune
+        for _, ch := range ops {
+            switch ch {
+            case '+', '-', '=':
+                op = ch
+            case 'w':
+                if op != '-' {
+                    return true
+                }
+            }
+        }
+    }
+    return false
+}
+
+// rmVariablePath reports whether cmd runs a recursive forced rm whose
+// operands expand a variable.
+func rmVariablePath(cmd string) bool {
+    for _, m := range rmRe.FindAllStringSubmatch(cmd, -1) {
+        recursive, force := false, false
+        for _, flag := range strings.Fields(m[1]) {
+            switch {
+            case flag == "--recursive":
+                recursive = true
+            case flag == "--force":
+                force = true
+            case !strings.HasPrefix(flag, "--"):
+                recursive = recursive || strings.ContainsAny(flag, "rR")
+                force = force || strings.Contains(flag, "f")
+            }
+        }
+        if recursive && force && strings.Contains(m[2], "$") && !strings.Contains(m[2], ":?") {
+            return true
+        }
+    }
+    return false
+}
+
+func isAuthFile(path string) bool {
+    if strings.HasPrefix(path, "/etc/sudoers.d/") {
+        return true
+    }
+    return contains(authFiles, path)
+}
+
+func isSystemFile(path string) bool {
+    for _, d := range systemDirs {
+        if strings.HasPrefix(path, d) {
+            return true
+        }
+    }
+    return false
+}
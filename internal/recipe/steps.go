@@ -0,0 +1,127 @@
+package recipe
+
+import (
+    "errors"
+    "fmt"
+)
+
+// ErrStepNotFound is returned when a step ID does not exist in the recipe.
+var ErrStepNotFound = errors.New("step not found")
+
+// StepIndex returns the index of the step with the given ID, or -1.
+func (r Recipe) StepIndex(id string) int {
+    for i, s := range r.Steps {
+        if s.ID == id {
+            return i
+        }
+    }
+    return -1
+}
+
+// NewStepID returns base, or base with a numeric suffix, unused by any step.
+func (r Recipe) NewStepID(base string) string {
+    return uniqueStepID(r, base, -1)
+}
+
+// InsertStep inserts s at pos; a negative pos or one past the end appends.
+// A step without an ID gets a fresh one.
+func (r *Recipe) InsertStep(s Step, pos int) (Step, error) {
+    if pos < 0 || pos > len(r.Steps) {
+        pos = len(r.Steps)
+    }
+    if s.ID == "" {
+        s.ID = r.NewStepID(fmt.Sprintf("step-%d", len(r.Steps)+1))
+    } else if r.StepIndex(s.ID) >= 0 {
+        return Step{}, fmt.Errorf("step id %s is already used", s.ID)
+    }
+    if s.Config == nil {
+        s.Config = map[string]interface{}{}
+    }
+    r.Steps = append(r.Steps, Step{})
+    copy(r.Steps[pos+1:], r.Steps[pos:])
+    r.Steps[pos] = s
+    return s, nil
+}
+
+// ReplaceStep replaces the step with the given ID. s keeps that ID unless it
+// names a new one no other step uses.
+func (r *Recipe) ReplaceStep(id string, s Step) (Step, error) {
+    i := r.StepIndex(id)
+    if i < 0 {
+        return Step{}, ErrStepNotFound
+    }
+    if s.ID == "" {
+        s.ID = id
+    } else if j := r.StepIndex(s.ID); j >= 0 && j != i {
+        return Step{}, fmt.Errorf("step id %s is already used", s.ID)
+    }
+    if s.Config == nil {
+        s.Config = map[string]interface{}{}
+    }
+    r.Steps[i] = s
+    return s, nil
+}
+
+// DeleteStep removes the step with the given ID.
+func (r *Recipe) DeleteStep(id string) error {
+    i := r.StepIndex(id)
+    if i < 0 {
+        return ErrStepNotFound
+    }
+    r.Steps = append(r.Steps[:i], r.Steps[i+1:]...)
+    return nil
+}
+
+// MoveStep moves the step with the given ID to index pos of the result.
+func (r *Recipe) MoveStep(id string, pos int) error {
+    i := r.StepIndex(id)
+    if i < 0 {
+        return ErrStepNotFound
+    }
+    if pos < 0 || pos >= len(r.Steps) {
+        return fmt.Errorf("position %d is out of range 0-%d", pos, len(r.Steps)-1)
+    }
+    s := r.Steps[i]
+    r.Steps = append(r.Steps[:i], r.Steps[i+1:]...)
+    r.Steps = append(r.Steps, Step{})
+    copy(r.Steps[pos+1:], r.Steps[pos:])
+    r.Steps[pos] = s
+    return nil
+}
+
+// ReorderSteps puts the steps in the order of ids, which must name every step once.
+func (r *Recipe) ReorderSteps(ids []string) error {
+    if len(ids) != len(r.Steps) {
+        return fmt.Errorf("order lists %d steps, recipe has %d", len(ids), len(r.Steps))
+    }
+    steps := make([]Step, 0, len(ids))
+    seen := map[string]bool{}
+    for _, id := range ids {
+        i := r.StepIndex(id)
+        if i < 0 {
+            return fmt.Errorf("step %s: %w", id, ErrStepNotFound)
+        }
+        if seen[id] {
+            return fmt.Errorf("step %s is listed twice", id)
+        }
+        seen[id] = true
+        steps = append(steps, r.Steps[i])
+    }
+    r.Steps = steps
+    return nil
+}
+
+// DuplicateStep inserts a deep copy of the step with the given ID right after
+// it, under a fresh ID, and returns the copy.
+func (r *Recipe) DuplicateStep(id string) (Step, error) {
+    i := r.StepIndex(id)
+    if i < 0 {
+        return Step{}, ErrStepNotFound
+    }
+    dup := r.Steps[i]
+    dup.ID = r.NewStepID(id + "-copy")
+    cfg, _ := normalize(dup.Config).(map[string]interface{})
+    dup.Config = cfg
+    dup.Suppress = append([]Suppression(nil), dup.Suppress...)
+    return r.InsertStep(dup, i+1)
+}
@@ -2,30 +2,109 @@ package recipe
 
 import (
     "fmt"
+    "os"
+    "regexp"
+    "strconv"
     "strings"
+
+    "installforge/internal/inspect"
+)
+
+var (
+    octalModeRe    = regexp.MustCompile(`^[0-7]{3,4}$`)
+    symbolicModeRe = regexp.MustCompile(`^[ugoa]*([-+=]([rwxXst]*|[ugo]))+(,[ugoa]*([-+=]([rwxXst]*|[ugo]))+)*$`)
+    accountNameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,31}\$?$`)
+    serviceNameRe  = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
 )
 
+// enumModes lists the allowed mode values of the step types that have them.
+var enumModes = map[string][]string{
+    "replace":      {"fixed", "regex"},
+    "delete_lines": {"fixed", "regex"},
+    "rpm_install":  {"upgrade", "install"},
+}
+
+// Options supplies project context that Validate cannot see in the recipe itself.
+type Options struct {
+    // Assets lists the uploaded asset names; nil skips the asset cross-check.
+    Assets []string
+    // Library lists the shared library's packages; nil skips the library checks.
+    Library []LibraryItem
+    // Archive returns the entry paths of an archive asset, if it is one and
+    // could be read; nil skips the archive content checks.
+    Archive func(name string) ([]string, bool)
+    // RPM returns the header of an RPM asset; nil skips the dependency check.
+    RPM func(name string) (inspect.RPMInfo, bool)
+    // Base maps a target to the capabilities its base install provides.
+    // Targets without an entry are not checked.
+    Base map[string][]string
+}
+
 // Validate inspects recipe and returns issues.
 func Validate(r Recipe) []Issue {
+    return ValidateWith(r, Options{})
+}
+
+// ValidateWith inspects recipe against project context and returns the
+// issues left after the recipe's lint config and suppressions apply.
+func ValidateWith(r Recipe, opts Options) []Issue {
+    return Lint(r, opts).Issues
+}
+
+// check runs every rule and reports issues at their default levels.
+func check(r Recipe, opts Options) []Issue {
     var issues []Issue
-    requiredModes := map[string][]string{
-        "replace": {"fixed", "regex"},
-        "delete_lines": {"fixed", "regex"},
-        "rpm_install": {"upgrade", "install"},
+
+    version := r.SchemaVersion
+    if _, ok := stepConfigs[version]; !ok {
+        version = CurrentSchemaVersion
+    }
+
+    for i, t := range r.Project.Target {
+        if !contains(KnownTargets, t) {
+            issues = append(issues, newIssue("unknown-target", "", pointer("project", "target", i), fmt.Sprintf("unknown target %s", t)))
+        }
     }
 
-    for _, step := range r.Steps {
+    seenIDs := map[string]int{}
+    for i, step := range r.Steps {
         stepType := step.Type
         cfg := step.Config
+        stepPath := pointer("steps", i)
+
+        add := func(rule, field, msg string) {
+            issues = append(issues, newIssue(rule, step.ID, stepPath+field, msg))
+        }
+        addKey := func(rule, key, msg string) {
+            add(rule, pointer("config", key), msg)
+        }
 
-        add := func(level, msg string) {
-            issues = append(issues, Issue{Level: level, StepID: step.ID, Message: msg})
+        // step identity
+        if strings.TrimSpace(step.ID) == "" {
+            add("empty-step-id", "/id", "step id is empty")
+        } else if first, dup := seenIDs[step.ID]; dup {
+            add("duplicate-step-id", "/id", fmt.Sprintf("duplicate step id %s (first used by step %d)", step.ID, first+1))
+        } else {
+            seenIDs[step.ID] = i
         }
 
         // generic required config presence
         require := func(key string) {
             if value, ok := cfg[key]; !ok || fmt.Sprintf("%v", value) == "" {
-                add("error", fmt.Sprintf("%s is required", key))
+                addKey("required-key", key, fmt.Sprintf("%s is required", key))
+            }
+        }
+        // enum values are matched exactly, as the rendered script compares them
+        requireMode := func(allowed []string, msg string) {
+            if modeVal, ok := cfg["mode"]; ok && !contains(allowed, fmt.Sprintf("%v", modeVal)) {
+                addKey("invalid-enum", "mode", msg)
+            }
+        }
+        warnBackup := func() {
+            if backup, ok := cfg["backup"]; ok {
+                if b, _ := backup.(bool); !b {
+                    addKey("backup-disabled", "backup", "backup is disabled; risk of data loss")
+                }
             }
         }
 
@@ -45,54 +124,33 @@ func Validate(r Recipe) []Issue {
             require("src")
             require("dest")
             if _, ok := cfg["creates"]; !ok {
-                add("warn", "creates is not set; idempotency may be improved")
+                addKey("missing-creates", "creates", "creates is not set; idempotency may be improved")
             }
         case "rpm_install":
             require("rpms")
             require("mode")
-            modeVal := fmt.Sprintf("%v", cfg["mode"])
-            if !contains(requiredModes["rpm_install"], strings.ToLower(modeVal)) {
-                add("error", "mode must be upgrade or install")
-            }
+            requireMode(enumModes["rpm_install"], "mode must be upgrade or install")
         case "append_lines":
             require("file")
             require("lines")
-            if backup, ok := cfg["backup"]; ok {
-                if b, _ := backup.(bool); !b {
-                    add("warn", "backup is disabled; risk of data loss")
-                }
-            }
+            warnBackup()
         case "delete_lines":
             require("file")
             require("match")
             require("mode")
-            modeVal := fmt.Sprintf("%v", cfg["mode"])
-            if !contains(requiredModes["delete_lines"], strings.ToLower(modeVal)) {
-                add("error", "mode must be fixed or regex")
-            }
-            if backup, ok := cfg["backup"]; ok {
-                if b, _ := backup.(bool); !b {
-                    add("warn", "backup is disabled; risk of data loss")
-                }
-            }
+            requireMode(enumModes["delete_lines"], "mode must be fixed or regex")
+            warnBackup()
         case "replace":
             require("file")
             require("pattern")
             require("replacement")
             require("mode")
-            modeVal := fmt.Sprintf("%v", cfg["mode"])
-            if !contains(requiredModes["replace"], strings.ToLower(modeVal)) {
-                add("error", "mode must be fixed or regex")
-            }
-            if backup, ok := cfg["backup"]; ok {
-                if b, _ := backup.(bool); !b {
-                    add("warn", "backup is disabled; risk of data loss")
-                }
-            }
+            requireMode(enumModes["replace"], "mode must be fixed or regex")
+            warnBackup()
         case "run_cmd":
             require("cmd")
             if _, ok := cfg["cwd"]; !ok {
-                add("warn", "cwd is not set; command will run from script directory")
+                addKey("missing-cwd", "cwd", "cwd is not set; command will run from script directory")
             }
         case "service_sysv":
             require("src")
@@ -105,12 +163,268 @@ func Validate(r Recipe) []Issue {
             require("sysv_src")
             require("systemd_src")
         default:
-            add("warn", fmt.Sprintf("unknown step type %s", stepType))
+            add("unknown-step-type", "/type", fmt.Sprintf("unknown step type %s", stepType))
+        }
+
+        // unknown keys and wrongly typed values
+        typed, cfgIssues := DecodeConfig(version, step)
+        for _, is := range cfgIssues {
+            is.Path = stepPath + is.Path
+            issues = append(issues, is)
         }
+
+        // semantic checks on the decoded values
+        c := configChecker{vars: r.Vars, add: add}
+        c.check(typed)
+    }
+
+    issues = append(issues, checkSecurity(r)...)
+    if opts.Library != nil {
+        issues = append(issues, checkLibrary(r, opts)...)
+    }
+    if opts.Assets != nil {
+        // pinned library files count as uploaded
+        assets := append(append([]string(nil), opts.Assets...), LibraryFiles(r, opts.Library)...)
+        issues = append(issues, validateAssets(r, assets)...)
+    }
+    if opts.Archive != nil {
+        issues = append(issues, checkArchives(r, opts, issues)...)
+    }
+    if opts.RPM != nil {
+        issues = append(issues, checkRPMDeps(r, opts, issues)...)
     }
     return issues
 }
 
+// configChecker runs semantic checks on a decoded step config. Empty values
+// are skipped; Validate already reports missing required keys.
+type configChecker struct {
+    vars map[string]string
+    add  func(rule, path, msg string)
+}
+
+// issue reports a rule violation on a config key.
+func (c configChecker) issue(rule, key, msg string) {
+    c.add(rule, pointer("config", key), msg)
+}
+
+// elem returns a checker whose issues point at element i of the reported key.
+func (c configChecker) elem(i int) configChecker {
+    parent := c.add
+    c.add = func(rule, path, msg string) {
+        parent(rule, path+pointer(i), msg)
+    }
+    return c
+}
+
+func (c configChecker) check(cfg StepConfig) {
+    switch cfg := cfg.(type) {
+    case *MkdirConfig:
+        c.absPath("path", cfg.Path)
+    case *CopyConfig:
+        c.source("src", cfg.Src)
+        c.absPath("dest", cfg.Dest)
+        c.mode("mode", cfg.Mode)
+    case *ChmodConfig:
+        c.absPath("path", cfg.Path)
+        c.mode("mode", cfg.Mode)
+    case *ChownConfig:
+        c.absPath("path", cfg.Path)
+        owner := cfg.Owner
+        if user, group, ok := strings.Cut(owner, ":"); ok {
+            owner = user
+            c.account("owner", group, "group")
+        }
+        c.account("owner", owner, "user")
+        c.account("group", cfg.Group, "group")
+    case *ExtractTarGzConfig:
+        c.archive(cfg.ArchiveConfig)
+    case *ExtractZipConfig:
+        c.archive(cfg.ArchiveConfig)
+    case *RpmInstallConfig:
+        for i, rpm := range cfg.Rpms {
+            ec := c.elem(i)
+            ec.source("rpms", rpm)
+            if rpm != "" && !strings.HasSuffix(rpm, ".rpm") && !strings.ContainsAny(rpm, "*?") {
+                ec.issue("rpm-extension", "rpms", fmt.Sprintf("%s does not look like an .rpm file", rpm))
+            }
+        }
+    case *AppendLinesConfig:
+        c.absPath("file", cfg.File)
+        for i, line := range cfg.Lines {
+            if line == "LINES" {
+                c.elem(i).issue("heredoc-terminator", "lines", "a line consisting of LINES ends the here-document in the generated script")
+            }
+        }
+    case *DeleteLinesConfig:
+        c.absPath("file", cfg.File)
+        if cfg.Mode == "regex" {
+            c.regex("match", cfg.Match)
+        }
+        c.quotable("match", cfg.Match)
+    case *ReplaceConfig:
+        c.absPath("file", cfg.File)
+        if cfg.Mode == "regex" {
+            c.regex("pattern", cfg.Pattern)
+        }
+        c.sedExpr("pattern", cfg.Pattern)
+        c.sedExpr("replacement", cfg.Replacement)
+    case *RunCmdConfig:
+        c.absPath("cwd", cfg.Cwd)
+    case *ServiceSysvConfig:
+        c.source("src", cfg.Src)
+        c.serviceName(cfg.Name)
+    case *ServiceSystemdConfig:
+        c.source("src", cfg.Src)
+        c.serviceName(cfg.Name)
+    case *AutoServiceConfig:
+        c.source("sysv_src", cfg.SysvSrc)
+        c.source("systemd_src", cfg.SystemdSrc)
+        c.serviceName(cfg.Name)
+    }
+}
+
+func (c configChecker) archive(cfg ArchiveConfig) {
+    c.source("src", cfg.Src)
+    c.absPath("dest", cfg.Dest)
+    c.absPath("creates", cfg.Creates)
+}
+
+// expand substitutes recipe vars; unknown variables are left in place.
+func (c configChecker) expand(value string) string {
+    return expandVars(c.vars, value)
+}
+
+func expandVars(vars map[string]string, value string) string {
+    return os.Expand(value, func(name string) string {
+        if v, ok := vars[name]; ok {
+            return v
+        }
+        return "${" + name + "}"
+    })
+}
+
+// absPath requires a path on the target host to be absolute once vars are expanded.
+func (c configChecker) absPath(key, value string) {
+    if value == "" {
+        return
+    }
+    expanded := c.expand(value)
+    if strings.HasPrefix(expanded, "/") || strings.HasPrefix(expanded, "$") {
+        return
+    }
+    c.issue("relative-path", key, fmt.Sprintf("%s must be an absolute path, got %s", key, value))
+}
+
+// source accepts a bundle asset reference or an absolute path.
+func (c configChecker) source(key, value string) {
+    if _, ok := AssetName(value); ok {
+        return
+    }
+    c.absPath(key, value)
+}
+
+func (c configChecker) mode(key string, mode FileMode) {
+    m := string(mode)
+    if m == "" || octalModeRe.MatchString(m) || symbolicModeRe.MatchString(m) {
+        return
+    }
+    c.issue("file-mode", key, fmt.Sprintf("%s %s is neither an octal nor a symbolic mode", key, m))
+}
+
+func (c configChecker) account(key, name, kind string) {
+    if name == "" {
+        return
+    }
+    if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+        return
+    }
+    if !accountNameRe.MatchString(name) {
+        c.issue("account-name", key, fmt.Sprintf("%s is not a valid %s name", name, kind))
+    }
+}
+
+func (c configChecker) serviceName(name string) {
+    if name != "" && !serviceNameRe.MatchString(name) {
+        c.issue("service-name", "name", fmt.Sprintf("service name %s may only contain letters, digits and _.@-", name))
+    }
+}
+
+// regex checks that pattern compiles as a POSIX extended regular expression,
+// the dialect of grep -E and sed -r. Go's parser does not know the GNU
+// extensions both tools accept, so those are swapped for stand-ins of the
+// same shape first and only the syntax they share is checked.
+func (c configChecker) regex(key, pattern string) {
+    if pattern == "" {
+        return
+    }
+    if _, err := regexp.CompilePOSIX(gnuRegexStandIns(pattern)); err != nil {
+        c.issue("invalid-regex", key, fmt.Sprintf("%s is not a valid POSIX extended regex: %v", key, err))
+    }
+}
+
+// gnuRegexStandIns replaces GNU regex escapes that RE2 rejects: back
+// references and anchors such as \1, \< and \b become an empty group,
+// character classes such as \w and \s any character.
+func gnuRegexStandIns(pattern string) string {
+    var b strings.Builder
+    for i := 0; i < len(pattern); i++ {
+        if pattern[i] != '\\' || i+1 == len(pattern) {
+            b.WriteByte(pattern[i])
+            continue
+        }
+        i++
+        switch next := pattern[i]; {
+        case next >= '1' && next <= '9', strings.IndexByte("<>bB`'", next) >= 0:
+            b.WriteString("()")
+        case strings.IndexByte("wWsS", next) >= 0:
+            b.WriteString(".")
+        default:
+            b.WriteByte('\\')
+            b.WriteByte(next)
+        }
+    }
+    return b.String()
+}
+
+// quotable rejects values that would end the double-quoted string they are rendered into.
+func (c configChecker) quotable(key, value string) {
+    if strings.Contains(value, `"`) && !strings.Contains(value, `\"`) {
+        c.issue("unquoted-value", key, fmt.Sprintf("%s contains an unescaped double quote", key))
+    }
+}
+
+// sedExpr rejects values that would break the s/pattern/replacement/g expression.
+func (c configChecker) sedExpr(key, value string) {
+    if strings.Contains(value, "\n") {
+        c.issue("sed-expression", key, fmt.Sprintf("%s must be a single line", key))
+    }
+    for i := 0; i < len(value); i++ {
+        switch value[i] {
+        case '\\':
+            i++
+        case '/':
+            c.issue("sed-expression", key, fmt.Sprintf("%s contains an unescaped / which ends the sed expression; write \\/", key))
+            return
+        case '\'':
+            c.issue("sed-expression", key, fmt.Sprintf("%s contains a single quote which ends the quoted sed expression", key))
+            return
+        }
+    }
+}
+
+// pointer builds a JSON pointer (RFC 6901) from path segments.
+func pointer(parts ...interface{}) string {
+    var b strings.Builder
+    for _, p := range parts {
+        seg := fmt.Sprintf("%v", p)
+        seg = strings.ReplaceAll(seg, "~", "~0")
+        seg = strings.ReplaceAll(seg, "/", "~1")
+        b.WriteString("/" + seg)
+    }
+    return b.String()
+}
+
 func contains(slice []string, value string) bool {
     for _, v := range slice {
         if v == value {
@@ -0,0 +1,230 @@
+package render
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// DefaultBaseImages maps recipe targets to the image a Dockerfile export starts from.
+// KylinSec 3.4 has no public image; CentOS 7 is the closest userland.
+var DefaultBaseImages = map[string]string{
+    "oracle_linux_6_9": "oraclelinux:6.9",
+    "kylinsec_3_4":     "centos:7",
+}
+
+// containerRoot is where the build context lands inside the image.
+const containerRoot = "/opt/installforge"
+
+// DockerOptions controls base image selection for Dockerfile exports.
+type DockerOptions struct {
+    // Target picks the entry of Project.Target to build for; empty means the first one with a known image.
+    Target string `json:"target"`
+    // BaseImages overrides or extends DefaultBaseImages.
+    BaseImages map[string]string `json:"baseImages"`
+}
+
+// DockerResult holds a Dockerfile and the extra build context files it needs.
+type DockerResult struct {
+    BaseImage  string            `json:"baseImage"`
+    Dockerfile string            `json:"dockerfile"`
+    Files      map[string]string `json:"files"`
+    Warnings   []string          `json:"warnings"`
+}
+
+// Dockerfile translates a recipe into a Dockerfile. Bundle assets are expected
+// under assets/ in the build context.
+func Dockerfile(r recipe.Recipe, opts DockerOptions) (DockerResult, error) {
+    base, err := pickBaseImage(r, opts)
+    if err != nil {
+        return DockerResult{}, err
+    }
+    res := DockerResult{BaseImage: base, Files: map[string]string{}}
+    var body []string
+    var services []string
+    dirs := map[string]bool{}
+    needAssets := false
+    runScript := func(s recipe.Step) error {
+        script, err := renderStep(s)
+        if err != nil {
+            return err
+        }
+        if strings.Contains(script, "ASSET_DIR") || strings.Contains(script, "assets/") {
+            needAssets = true
+        }
+        if !strings.Contains(script, "\n") {
+            body = append(body, "RUN "+script)
+            return nil
+        }
+        name := fmt.Sprintf("steps/%03d.sh", len(res.Files)+1)
+        res.Files[name] = "#!/bin/bash\nset -eu\n" + script + "\n"
+        body = append(body, dockerExec("/bin/bash", containerRoot+"/"+name))
+        return nil
+    }
+
+    for _, s := range r.Steps {
+        cfg := decodeStep(s).Config
+        switch c := cfg.(type) {
+        case nil:
+            res.Warnings = append(res.Warnings, fmt.Sprintf("step %s: unknown step type %s; step dropped", s.ID, s.Type))
+            continue
+        case *recipe.ServiceSystemdConfig:
+            res.Warnings = append(res.Warnings, fmt.Sprintf("step %s: systemd unit %s is not run inside containers; step dropped", s.ID, c.Name))
+            continue
+        }
+        body = append(body, fmt.Sprintf("# %s: %s (%s)", s.ID, s.Name, s.Type))
+        addService := func(name, src string) {
+            initScript := "/etc/init.d/" + name
+            if asset, ok := recipe.AssetName(src); ok {
+                body = append(body, dockerCopy("assets/"+asset, initScript))
+            } else {
+                body = append(body, fmt.Sprintf("RUN cp \"%s\" \"%s\"", src, initScript))
+            }
+            body = append(body, fmt.Sprintf("RUN chmod +x \"%s\"", initScript))
+            services = append(services, name)
+        }
+        switch c := cfg.(type) {
+        case *recipe.MkdirConfig:
+            dirs[strings.TrimSuffix(c.Path, "/")] = true
+            if err := runScript(s); err != nil {
+                return DockerResult{}, err
+            }
+        case *recipe.CopyConfig:
+            // COPY always overwrites; without overwrite the step runs as in
+            // install.sh, which leaves an existing dest alone
+            name, ok := recipe.AssetName(c.Src)
+            if !ok || !c.Overwrite {
+                if err := runScript(s); err != nil {
+                    return DockerResult{}, err
+                }
+                continue
+            }
+            dest := c.Dest
+            if dirs[strings.TrimSuffix(dest, "/")] && !strings.HasSuffix(dest, "/") {
+                dest += "/"
+            }
+            body = append(body, dockerCopy("assets/"+name, dest))
+            if c.Mode != "" {
+                body = append(body, fmt.Sprintf("RUN chmod %s \"%s\"", c.Mode, c.Dest))
+            }
+        case *recipe.ServiceSysvConfig:
+            addService(c.Name, c.Src)
+        case *recipe.AutoServiceConfig:
+            addService(c.Name, c.SysvSrc)
+        default:
+            if err := runScript(s); err != nil {
+                return DockerResult{}, err
+            }
+        }
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "# Generated by InstallForge at %s\n", time.Now().Format(time.RFC3339))
+    fmt.Fprintf(&b, "FROM %s\n", base)
+    fmt.Fprintf(&b, "LABEL org.opencontainers.image.title=\"%s\"\n", dockerEscape(r.Project.Name))
+    b.WriteString("SHELL [\"/bin/bash\", \"-euc\"]\n")
+    keys := make([]string, 0, len(r.Vars))
+    for k := range r.Vars {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for _, k := range keys {
+        fmt.Fprintf(&b, "ENV %s=\"%s\"\n", k, dockerEscape(r.Vars[k]))
+    }
+    fmt.Fprintf(&b, "ENV SCRIPT_DIR=%s ASSET_DIR=%s/assets\n", containerRoot, containerRoot)
+    fmt.Fprintf(&b, "WORKDIR %s\n", containerRoot)
+    if needAssets {
+        b.WriteString(dockerCopy("assets/", containerRoot+"/assets/") + "\n")
+    }
+    if len(res.Files) > 0 {
+        b.WriteString(dockerCopy("steps/", containerRoot+"/steps/") + "\n")
+    }
+    b.WriteString("\n")
+    for _, line := range body {
+        b.WriteString(line + "\n")
+    }
+    if len(services) > 0 {
+        res.Files["entrypoint.sh"] = renderEntrypoint(services)
+        b.WriteString("\n" + dockerCopy("entrypoint.sh", containerRoot+"/entrypoint.sh") + "\n")
+        fmt.Fprintf(&b, "RUN chmod +x %s/entrypoint.sh\n", containerRoot)
+        b.WriteString(dockerExecLine("ENTRYPOINT", containerRoot+"/entrypoint.sh") + "\n")
+    }
+    res.Dockerfile = b.String()
+    return res, nil
+}
+
+func pickBaseImage(r recipe.Recipe, opts DockerOptions) (string, error) {
+    images := map[string]string{}
+    for k, v := range DefaultBaseImages {
+        images[k] = v
+    }
+    for k, v := range opts.BaseImages {
+        images[k] = v
+    }
+    if opts.Target != "" {
+        if img, ok := images[opts.Target]; ok && img != "" {
+            return img, nil
+        }
+        return "", fmt.Errorf("no base image configured for target %s", opts.Target)
+    }
+    for _, t := range r.Project.Target {
+        if img, ok := images[t]; ok && img != "" {
+            return img, nil
+        }
+    }
+    return "", fmt.Errorf("no base image configured for targets %v", r.Project.Target)
+}
+
+func renderEntrypoint(services []string) string {
+    var b strings.Builder
+    b.WriteString("#!/bin/bash\nset -eu\n\n")
+    fmt.Fprintf(&b, "services=(%s)\n\n", strings.Join(services, " "))
+    b.WriteString(`stop_all() {
+  for s in "${services[@]}"; do
+    /etc/init.d/$s stop || true
+  done
+  exit 0
+}
+trap stop_all TERM INT
+
+for s in "${services[@]}"; do
+  /etc/init.d/$s start
+done
+
+if [ $# -gt 0 ]; then
+  exec "$@"
+fi
+while true; do
+  sleep 1 &
+  wait $!
+done
+`)
+    return b.String()
+}
+
+func dockerCopy(src, dest string) string {
+    return dockerExecLine("COPY", src, dest)
+}
+
+func dockerExec(args ...string) string {
+    return dockerExecLine("RUN", args...)
+}
+
+// dockerExecLine writes an instruction in JSON array form so paths with spaces survive.
+func dockerExecLine(instr string, args ...string) string {
+    quoted := make([]string, len(args))
+    for i, a := range args {
+        buf, _ := json.Marshal(a)
+        quoted[i] = string(buf)
+    }
+    return instr + " [" + strings.Join(quoted, ", ") + "]"
+}
+
+func dockerEscape(s string) string {
+    s = strings.ReplaceAll(s, `\`, `\\`)
+    return strings.ReplaceAll(s, `"`, `\"`)
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+    "strings"
+    "testing"
+
+    "installforge/internal/recipe"
+)
+
+func TestDockerfileCopy(t *testing.T) {
+    tests := []struct {
+        name      string
+        config    map[string]interface{}
+        contains  []string
+        forbidden []string
+    }{
+        {
+            name:      "overwrite",
+            config:    map[string]interface{}{"src": "$ASSET_DIR/app.conf", "dest": "/etc/app.conf", "overwrite": true},
+            contains:  []string{`COPY ["assets/app.conf", "/etc/app.conf"]`},
+            forbidden: []string{"cp -n"},
+        },
+        {
+            name:      "keep existing",
+            config:    map[string]interface{}{"src": "$ASSET_DIR/app.conf", "dest": "/etc/app.conf"},
+            contains:  []string{`COPY ["assets/", "/opt/installforge/assets/"]`, `RUN cp -n "$ASSET_DIR/app.conf" "/etc/app.conf"`},
+            forbidden: []string{`COPY ["assets/app.conf"`},
+        },
+        {
+            name:     "host path",
+            config:   map[string]interface{}{"src": "/srv/app.conf", "dest": "/etc/app.conf", "overwrite": true},
+            contains: []string{`RUN cp -f "/srv/app.conf" "/etc/app.conf"`},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := recipe.NewEmptyRecipe("p1", "demo")
+            r.Project.Target = []string{"oracle_linux_6_9"}
+            r.Steps = []recipe.Step{{ID: "s1", Name: "config", Type: "copy", Config: tt.config}}
+            res, err := Dockerfile(r, DockerOptions{})
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, want := range tt.contains {
+                if !strings.Contains(res.Dockerfile, want) {
+                    t.Errorf("missing %s in\n%s", want, res.Dockerfile)
+                }
+            }
+            for _, bad := range tt.forbidden {
+                if strings.Contains(res.Dockerfile, bad) {
+                    t.Errorf("unexpected %s in\n%s", bad, res.Dockerfile)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,168 @@
+package render
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "strings"
+
+    "installforge/internal/recipe"
+)
+
+// InlineLimit is the largest asset embedded into kickstart or cloud-init documents.
+const InlineLimit = 64 << 10
+
+// UserDataLimit is the user-data size several clouds (EC2 among them) refuse to exceed.
+const UserDataLimit = 16 << 10
+
+// InlineAsset is a bundle asset offered to a provisioning export. Data is nil
+// when the asset is larger than InlineLimit and was not read.
+type InlineAsset struct {
+    Name string
+    Size int64
+    Data []byte
+}
+
+// ProvisionResult holds a generated provisioning document.
+type ProvisionResult struct {
+    Document string   `json:"document"`
+    Warnings []string `json:"warnings"`
+}
+
+// Kickstart wraps install.sh and the inlinable assets in a kickstart %post section.
+func Kickstart(r recipe.Recipe, assets []InlineAsset) (ProvisionResult, error) {
+    name, err := payloadName(r)
+    if err != nil {
+        return ProvisionResult{}, err
+    }
+    install, err := renderInstall(r)
+    if err != nil {
+        return ProvisionResult{}, err
+    }
+    dir := payloadRoot + "/" + name
+    res := ProvisionResult{Warnings: inlineWarnings(assets)}
+    for _, s := range r.Steps {
+        if startsService(s) {
+            res.Warnings = append(res.Warnings, fmt.Sprintf("step %s: services cannot be started inside the installer chroot; set start to false and let the service start on first boot", s.ID))
+        }
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "# InstallForge %s: paste or %%include into the kickstart file\n", r.Project.Name)
+    fmt.Fprintf(&b, "%%post --log=/root/installforge-%s.log\n", name)
+    b.WriteString("set -eu\n")
+    fmt.Fprintf(&b, "mkdir -p \"%s/assets\"\n", dir)
+    for _, a := range assets {
+        if a.Data == nil {
+            continue
+        }
+        fmt.Fprintf(&b, "base64 -d > \"%s/assets/%s\" <<'INSTALLFORGE_ASSET'\n", dir, a.Name)
+        b.WriteString(wrapBase64(a.Data, 76))
+        b.WriteString("INSTALLFORGE_ASSET\n")
+    }
+    fmt.Fprintf(&b, "cat > \"%s/install.sh\" <<'INSTALLFORGE_SCRIPT'\n", dir)
+    b.WriteString(install)
+    if !strings.HasSuffix(install, "\n") {
+        b.WriteString("\n")
+    }
+    b.WriteString("INSTALLFORGE_SCRIPT\n")
+    fmt.Fprintf(&b, "chmod +x \"%s/install.sh\"\n", dir)
+    fmt.Fprintf(&b, "\"%s/install.sh\"\n", dir)
+    b.WriteString("%end\n")
+    res.Document = b.String()
+    return res, nil
+}
+
+// CloudInit renders a #cloud-config document that writes install.sh and the
+// inlinable assets with write_files and runs the script from runcmd. Assets
+// are inlined only while the document stays within UserDataLimit; the rest
+// are left for the host to provide under ASSET_DIR.
+func CloudInit(r recipe.Recipe, assets []InlineAsset) (ProvisionResult, error) {
+    name, err := payloadName(r)
+    if err != nil {
+        return ProvisionResult{}, err
+    }
+    install, err := renderInstall(r)
+    if err != nil {
+        return ProvisionResult{}, err
+    }
+    dir := payloadRoot + "/" + name
+    res := ProvisionResult{Warnings: inlineWarnings(assets)}
+
+    writeFile := func(path, perm string, data []byte) string {
+        var e strings.Builder
+        fmt.Fprintf(&e, "  - path: %s\n", yamlString(path))
+        fmt.Fprintf(&e, "    permissions: '%s'\n", perm)
+        e.WriteString("    encoding: b64\n")
+        fmt.Fprintf(&e, "    content: %s\n", base64.StdEncoding.EncodeToString(data))
+        return e.String()
+    }
+    var b strings.Builder
+    b.WriteString("#cloud-config\n")
+    fmt.Fprintf(&b, "# InstallForge %s\n", r.Project.Name)
+    b.WriteString("write_files:\n")
+    script := writeFile(dir+"/install.sh", "0755", []byte(install))
+    runcmd := fmt.Sprintf("runcmd:\n  - [ %s ]\n", yamlString(dir+"/install.sh"))
+    budget := UserDataLimit - b.Len() - len(script) - len(runcmd)
+    for _, a := range assets {
+        if a.Data == nil {
+            continue
+        }
+        entry := writeFile(dir+"/assets/"+a.Name, "0644", a.Data)
+        if len(entry) > budget {
+            res.Warnings = append(res.Warnings, fmt.Sprintf("asset %s would take user-data over %d bytes; provide it under ASSET_DIR by other means", a.Name, UserDataLimit))
+            continue
+        }
+        budget -= len(entry)
+        b.WriteString(entry)
+    }
+    b.WriteString(script)
+    b.WriteString(runcmd)
+    res.Document = b.String()
+    if len(res.Document) > UserDataLimit {
+        res.Warnings = append(res.Warnings, fmt.Sprintf("user-data is %d bytes; some clouds reject user-data over %d bytes", len(res.Document), UserDataLimit))
+    }
+    return res, nil
+}
+
+func inlineWarnings(assets []InlineAsset) []string {
+    var warnings []string
+    for _, a := range assets {
+        if a.Data == nil {
+            warnings = append(warnings, fmt.Sprintf("asset %s is %d bytes, over the %d byte inline limit; provide it under ASSET_DIR by other means", a.Name, a.Size, InlineLimit))
+        }
+    }
+    return warnings
+}
+
+// startsService reports whether a service step starts the service right away.
+func startsService(s recipe.Step) bool {
+    switch c := decodeStep(s).Config.(type) {
+    case *recipe.ServiceSysvConfig:
+        return c.Start
+    case *recipe.ServiceSystemdConfig:
+        return c.Start
+    case *recipe.AutoServiceConfig:
+        return c.Start
+    }
+    return false
+}
+
+func wrapBase64(data []byte, width int) string {
+    enc := base64.StdEncoding.EncodeToString(data)
+    var b strings.Builder
+    for len(enc) > width {
+        b.WriteString(enc[:width] + "\n")
+        enc = enc[width:]
+    }
+    if enc != "" {
+        b.WriteString(enc + "\n")
+    }
+    return b.String()
+}
+
+// yamlString quotes s as a JSON string, which YAML accepts as a double-quoted scalar.
+func yamlString(s string) string {
+    buf, _ := json.Marshal(s)
+    return string(buf)
+}
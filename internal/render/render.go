@@ -4,6 +4,8 @@ import (
     "bytes"
     "encoding/json"
     "fmt"
+    "sort"
+    "strings"
     "text/template"
     "time"
 
@@ -13,15 +15,23 @@ import (
 // RenderResponse holds rendered artifacts.
 type RenderResponse struct {
     InstallSh       string          `json:"installSh"`
+    UninstallSh     string          `json:"uninstallSh"`
     Readme          string          `json:"readme"`
     RecipePretty    string          `json:"recipeJsonPretty"`
     Issues          []recipe.Issue  `json:"issues"`
+    Suppressed      []recipe.SuppressedIssue `json:"suppressed,omitempty"`
 }
 
-// Render generates preview artifacts.
-func Render(r recipe.Recipe) (RenderResponse, error) {
-    issues := recipe.Validate(r)
-    install, err := renderInstall(r)
+// Render generates preview artifacts; opts is passed on to validation.
+func Render(r recipe.Recipe, opts recipe.Options) (RenderResponse, error) {
+    lint := recipe.Lint(r, opts)
+    // Scripts install bundled RPMs in dependency order; the recipe itself is shown as written.
+    ordered := recipe.OrderRPMs(r, opts)
+    install, err := renderInstall(ordered)
+    if err != nil {
+        return RenderResponse{}, err
+    }
+    uninstall, err := renderUninstall(ordered)
     if err != nil {
         return RenderResponse{}, err
     }
@@ -30,7 +40,33 @@ func Render(r recipe.Recipe) (RenderResponse, error) {
     if err != nil {
         return RenderResponse{}, err
     }
-    return RenderResponse{InstallSh: install, Readme: readme, RecipePretty: recipePretty, Issues: issues}, nil
+    return RenderResponse{InstallSh: install, UninstallSh: uninstall, Readme: readme, RecipePretty: recipePretty, Issues: lint.Issues, Suppressed: lint.Suppressed}, nil
+}
+
+// StepSnippet is the install and undo code rendered for one step.
+type StepSnippet struct {
+    ID        string `json:"id"`
+    Name      string `json:"name"`
+    Type      string `json:"type"`
+    Install   string `json:"install"`
+    Uninstall string `json:"uninstall"`
+}
+
+// RenderSnippets renders the snippets of steps, in order.
+func RenderSnippets(steps []recipe.Step) ([]StepSnippet, error) {
+    install, err := renderSteps(steps)
+    if err != nil {
+        return nil, err
+    }
+    res := make([]StepSnippet, len(steps))
+    for i, s := range install {
+        undo, err := renderUninstallSteps([]recipe.Step{s.Step})
+        if err != nil {
+            return nil, err
+        }
+        res[i] = StepSnippet{ID: s.ID, Name: s.Name, Type: s.Type, Install: s.Script, Uninstall: undo[0].Script}
+    }
+    return res, nil
 }
 
 func pretty(r recipe.Recipe) (string, error) {
@@ -41,20 +77,72 @@ func pretty(r recipe.Recipe) (string, error) {
     return string(buf), nil
 }
 
+var (
+    installTmpl = template.Must(template.New("install").Parse(installTemplate))
+    stepTmpl    = template.Must(template.New("step").Parse(stepTemplate))
+)
+
+// typedStep is what the step templates see: the step with its config decoded.
+type typedStep struct {
+    ID     string
+    Name   string
+    Type   string
+    Config recipe.StepConfig
+}
+
+// decodeStep decodes the step config; keys that fail to decode are left at
+// their zero value and reported by recipe.Validate instead.
+func decodeStep(s recipe.Step) typedStep {
+    cfg, _ := recipe.DecodeConfig(recipe.CurrentSchemaVersion, s)
+    return typedStep{ID: s.ID, Name: s.Name, Type: s.Type, Config: cfg}
+}
+
+// stepScript pairs a step with its rendered shell snippet.
+type stepScript struct {
+    recipe.Step
+    Script string
+}
+
 func renderInstall(r recipe.Recipe) (string, error) {
-    tmpl := template.Must(template.New("install").Parse(installTemplate))
+    steps, err := renderSteps(r.Steps)
+    if err != nil {
+        return "", err
+    }
     var buf bytes.Buffer
     data := map[string]interface{}{
         "Recipe":      r,
+        "Steps":       steps,
+        "Vars":        shellVars(r),
         "GeneratedAt": time.Now().Format(time.RFC3339),
         "Preflight":   gatherPreflight(r),
     }
-    if err := tmpl.Execute(&buf, data); err != nil {
+    if err := installTmpl.Execute(&buf, data); err != nil {
         return "", err
     }
     return buf.String(), nil
 }
 
+func renderSteps(steps []recipe.Step) ([]stepScript, error) {
+    res := make([]stepScript, 0, len(steps))
+    for _, s := range steps {
+        script, err := renderStep(s)
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, stepScript{Step: s, Script: script})
+    }
+    return res, nil
+}
+
+// renderStep renders the shell snippet for a single step.
+func renderStep(s recipe.Step) (string, error) {
+    var buf bytes.Buffer
+    if err := stepTmpl.Execute(&buf, decodeStep(s)); err != nil {
+        return "", fmt.Errorf("step %s: %w", s.ID, err)
+    }
+    return strings.TrimSpace(buf.String()), nil
+}
+
 func renderReadme(r recipe.Recipe) string {
     return fmt.Sprintf(`InstallForge bundle\n===================\n\nProject: %s\nTargets: %v\n\nUsage:\n  chmod +x install.sh\n  sudo ./install.sh\n\nLogs are written under {{LOG_DIR}} (default /var/log/asg).\n`, r.Project.Name, r.Project.Target)
 }
@@ -82,14 +170,44 @@ func gatherPreflight(r recipe.Recipe) []string {
     for cmd := range checks {
         deps = append(deps, cmd)
     }
+    sort.Strings(deps)
     return deps
 }
 
+// shellVars turns recipe vars into exported shell assignments that the environment may override.
+// LOG_DIR is left to the caller since each script picks its own fallback.
+func shellVars(r recipe.Recipe) []string {
+    keys := make([]string, 0, len(r.Vars))
+    for k := range r.Vars {
+        if k != "LOG_DIR" {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    lines := make([]string, 0, len(keys))
+    for _, k := range keys {
+        lines = append(lines, fmt.Sprintf("export %s=\"${%s:-%s}\"", k, k, shellEscape(r.Vars[k])))
+    }
+    return lines
+}
+
+// shellEscape escapes a value for a double-quoted shell string while keeping $VAR expansion.
+func shellEscape(s string) string {
+    s = strings.ReplaceAll(s, `\`, `\\`)
+    s = strings.ReplaceAll(s, `"`, `\"`)
+    return strings.ReplaceAll(s, "`", "\\`")
+}
+
 const installTemplate = `#!/bin/bash
 set -eu
 
 SCRIPT_DIR=$(cd "$(dirname "$0")" && pwd)
 ASSET_DIR="$SCRIPT_DIR/assets"
+# relative assets/ references and run_cmd steps without cwd resolve against the bundle
+cd "$SCRIPT_DIR"
+{{- range .Vars}}
+{{.}}
+{{- end}}
 LOG_DIR="${LOG_DIR:-{{ index .Recipe.Vars "LOG_DIR"}}}"
 LOG_FILE="$LOG_DIR/install-$(date +%Y%m%d-%H%M%S).log"
 
@@ -125,119 +243,109 @@ run_step() {
   echo "[$((step_idx+1))/$total] step=$id type=$type name=$desc"
 }
 
-{{range .Recipe.Steps}}
+{{range .Steps}}
 run_step "{{.ID}}" "{{.Type}}" "{{.Name}}"
-case "{{.Type}}" in
-  mkdir)
-    mkdir -p "{{index .Config "path"}}"
-    ;;
-  copy)
-    {{if index .Config "overwrite"}}cp -f{{else}}cp -n{{end}} "{{index .Config "src"}}" "{{index .Config "dest"}}"
-    {{if index .Config "mode"}}chmod {{index .Config "mode"}} "{{index .Config "dest"}}"{{end}}
-    ;;
-  chmod)
-    chmod {{index .Config "mode"}} "{{index .Config "path"}}"
-    ;;
-  chown)
-    chown {{index .Config "owner"}}{{if index .Config "group"}}:{{index .Config "group"}}{{end}} "{{index .Config "path"}}"
-    ;;
-  extract_tar_gz)
-    if [ -n "{{index .Config "creates"}}" ] && [ -e "{{index .Config "creates"}}" ]; then
-      echo "skip extract_tar_gz because creates exists"
-    else
-      mkdir -p "{{index .Config "dest"}}"
-      tar -xzf "{{index .Config "src"}}" -C "{{index .Config "dest"}}"
-    fi
-    ;;
-  extract_zip)
-    if [ -n "{{index .Config "creates"}}" ] && [ -e "{{index .Config "creates"}}" ]; then
-      echo "skip extract_zip because creates exists"
-    else
-      mkdir -p "{{index .Config "dest"}}"
-      unzip -o "{{index .Config "src"}}" -d "{{index .Config "dest"}}"
-    fi
-    ;;
-  rpm_install)
-    for rpm in {{range $i, $v := index .Config "rpms"}} "{{$v}}"{{end}}; do
-      if [ "{{index .Config "mode"}}" = "upgrade" ]; then
-        rpm -Uvh $rpm {{if index .Config "nodeps"}}--nodeps{{end}}
-      else
-        rpm -ivh $rpm {{if index .Config "nodeps"}}--nodeps{{end}}
-      fi
-    done
-    ;;
-  append_lines)
-    target="{{index .Config "file"}}"
-    cp -a "$target" "$target.bak.$(date +%s)"
-    while IFS= read -r line; do
-      if {{if index .Config "unique"}}! grep -Fqx "$line" "$target"; then{{else}}true; then{{end}}
-        echo "$line" >> "$target"
-      fi
-    done <<'LINES'
-{{range index .Config "lines"}}{{.}}
-{{end}}LINES
-    ;;
-  delete_lines)
-    target="{{index .Config "file"}}"
-    cp -a "$target" "$target.bak.$(date +%s)"
-    if [ "{{index .Config "mode"}}" = "regex" ]; then
-      grep -Ev "{{index .Config "match"}}" "$target" > "$target.tmp" && mv "$target.tmp" "$target"
-    else
-      grep -Fv "{{index .Config "match"}}" "$target" > "$target.tmp" && mv "$target.tmp" "$target"
-    fi
-    ;;
-  replace)
-    target="{{index .Config "file"}}"
-    cp -a "$target" "$target.bak.$(date +%s)"
-    if [ "{{index .Config "mode"}}" = "regex" ]; then
-      sed -r 's/{{index .Config "pattern"}}/{{index .Config "replacement"}}/g' "$target" > "$target.tmp" && mv "$target.tmp" "$target"
-    else
-      sed 's/{{index .Config "pattern"}}/{{index .Config "replacement"}}/g' "$target" > "$target.tmp" && mv "$target.tmp" "$target"
-    fi
-    ;;
-  run_cmd)
-    {{if index .Config "cwd"}}(cd "{{index .Config "cwd"}}" && {{index .Config "cmd"}}){{else}}{{index .Config "cmd"}}{{end}}
-    ;;
-  service_sysv)
-    cp "{{index .Config "src"}}" "/etc/init.d/{{index .Config "name"}}"
-    chmod +x "/etc/init.d/{{index .Config "name"}}"
-    if command -v chkconfig >/dev/null 2>&1; then
-      chkconfig --add {{index .Config "name"}}
-      chkconfig {{index .Config "name"}} on
-    else
-      echo "chkconfig not found; ensure service enabled manually" >&2
-    fi
-    {{if or (not (index .Config "start")) (eq (index .Config "start") false)}}true{{else}}service {{index .Config "name"}} start{{end}}
-    ;;
-  service_systemd)
-    cp "{{index .Config "src"}}" "/etc/systemd/system/{{index .Config "name"}}.service"
-    systemctl daemon-reload
-    {{if or (not (index .Config "start")) (eq (index .Config "start") false)}}true{{else}}systemctl enable --now {{index .Config "name"}}{{end}}
-    ;;
-  auto_service)
-    if command -v systemctl >/dev/null 2>&1; then
-      cp "{{index .Config "systemd_src"}}" "/etc/systemd/system/{{index .Config "name"}}.service"
-      systemctl daemon-reload
-      {{if or (not (index .Config "start")) (eq (index .Config "start") false)}}true{{else}}systemctl enable --now {{index .Config "name"}}{{end}}
-    else
-      cp "{{index .Config "sysv_src"}}" "/etc/init.d/{{index .Config "name"}}"
-      chmod +x "/etc/init.d/{{index .Config "name"}}"
-      if command -v chkconfig >/dev/null 2>&1; then
-        chkconfig --add {{index .Config "name"}}
-        chkconfig {{index .Config "name"}} on
-      else
-        echo "chkconfig not found; ensure service enabled manually" >&2
-      fi
-      {{if or (not (index .Config "start")) (eq (index .Config "start") false)}}true{{else}}service {{index .Config "name"}} start{{end}}
-    fi
-    ;;
-  *)
-    echo "Unknown step type {{.Type}}" >&2
-    exit 1
-    ;;
-esac
+{{.Script}}
 step_idx=$((step_idx+1))
-done
+{{end}}
 
 echo "Completed $total steps"
 `
+
+const stepTemplate = `{{- if eq .Type "mkdir"}}
+mkdir -p "{{.Config.Path}}"
+{{- else if eq .Type "copy"}}
+{{if .Config.Overwrite}}cp -f{{else}}cp -n{{end}} "{{.Config.Src}}" "{{.Config.Dest}}"
+{{if .Config.Mode}}chmod {{.Config.Mode}} "{{.Config.Dest}}"{{end}}
+{{- else if eq .Type "chmod"}}
+chmod {{.Config.Mode}} "{{.Config.Path}}"
+{{- else if eq .Type "chown"}}
+chown {{.Config.Owner}}{{if .Config.Group}}:{{.Config.Group}}{{end}} "{{.Config.Path}}"
+{{- else if eq .Type "extract_tar_gz"}}
+if [ -n "{{.Config.Creates}}" ] && [ -e "{{.Config.Creates}}" ]; then
+  echo "skip extract_tar_gz because creates exists"
+else
+  mkdir -p "{{.Config.Dest}}"
+  tar -xzf "{{.Config.Src}}" -C "{{.Config.Dest}}"
+fi
+{{- else if eq .Type "extract_zip"}}
+if [ -n "{{.Config.Creates}}" ] && [ -e "{{.Config.Creates}}" ]; then
+  echo "skip extract_zip because creates exists"
+else
+  mkdir -p "{{.Config.Dest}}"
+  unzip -o "{{.Config.Src}}" -d "{{.Config.Dest}}"
+fi
+{{- else if eq .Type "rpm_install"}}
+for rpm in {{range $i, $v := .Config.Rpms}} "{{$v}}"{{end}}; do
+  if [ "{{.Config.Mode}}" = "upgrade" ]; then
+    rpm -Uvh $rpm {{if .Config.Nodeps}}--nodeps{{end}}
+  else
+    rpm -ivh $rpm {{if .Config.Nodeps}}--nodeps{{end}}
+  fi
+done
+{{- else if eq .Type "append_lines"}}
+target="{{.Config.File}}"
+record="$target.installforge.{{.ID}}"
+cp -a "$target" "$target.bak.$(date +%s)"
+touch "$record"
+while IFS= read -r line; do
+  if {{if .Config.Unique}}! grep -Fqx "$line" "$target"; then{{else}}true; then{{end}}
+    echo "$line" >> "$target"
+    echo "$line" >> "$record"
+  fi
+done <<'LINES'
+{{range .Config.Lines}}{{.}}
+{{end}}LINES
+{{- else if eq .Type "delete_lines"}}
+target="{{.Config.File}}"
+cp -a "$target" "$target.bak.$(date +%s)"
+if [ "{{.Config.Mode}}" = "regex" ]; then
+  grep -Ev "{{.Config.Match}}" "$target" > "$target.tmp" && mv "$target.tmp" "$target"
+else
+  grep -Fv "{{.Config.Match}}" "$target" > "$target.tmp" && mv "$target.tmp" "$target"
+fi
+{{- else if eq .Type "replace"}}
+target="{{.Config.File}}"
+cp -a "$target" "$target.bak.$(date +%s)"
+if [ "{{.Config.Mode}}" = "regex" ]; then
+  sed -r 's/{{.Config.Pattern}}/{{.Config.Replacement}}/g' "$target" > "$target.tmp" && mv "$target.tmp" "$target"
+else
+  sed 's/{{.Config.Pattern}}/{{.Config.Replacement}}/g' "$target" > "$target.tmp" && mv "$target.tmp" "$target"
+fi
+{{- else if eq .Type "run_cmd"}}
+{{if .Config.Cwd}}(cd "{{.Config.Cwd}}" && {{.Config.Cmd}}){{else}}{{.Config.Cmd}}{{end}}
+{{- else if eq .Type "service_sysv"}}
+cp "{{.Config.Src}}" "/etc/init.d/{{.Config.Name}}"
+chmod +x "/etc/init.d/{{.Config.Name}}"
+if command -v chkconfig >/dev/null 2>&1; then
+  chkconfig --add {{.Config.Name}}
+  chkconfig {{.Config.Name}} on
+else
+  echo "chkconfig not found; ensure service enabled manually" >&2
+fi
+{{if .Config.Start}}service {{.Config.Name}} start{{else}}true{{end}}
+{{- else if eq .Type "service_systemd"}}
+cp "{{.Config.Src}}" "/etc/systemd/system/{{.Config.Name}}.service"
+systemctl daemon-reload
+{{if .Config.Start}}systemctl enable --now {{.Config.Name}}{{else}}true{{end}}
+{{- else if eq .Type "auto_service"}}
+if command -v systemctl >/dev/null 2>&1; then
+  cp "{{.Config.SystemdSrc}}" "/etc/systemd/system/{{.Config.Name}}.service"
+  systemctl daemon-reload
+  {{if .Config.Start}}systemctl enable --now {{.Config.Name}}{{else}}true{{end}}
+else
+  cp "{{.Config.SysvSrc}}" "/etc/init.d/{{.Config.Name}}"
+  chmod +x "/etc/init.d/{{.Config.Name}}"
+  if command -v chkconfig >/dev/null 2>&1; then
+    chkconfig --add {{.Config.Name}}
+    chkconfig {{.Config.Name}} on
+  else
+    echo "chkconfig not found; ensure service enabled manually" >&2
+  fi
+  {{if .Config.Start}}service {{.Config.Name}} start{{else}}true{{end}}
+fi
+{{- else}}
+echo "Unknown step type {{.Type}}" >&2
+exit 1
+{{- end}}
+`
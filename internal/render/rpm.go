@@ -0,0 +1,300 @@
+package render
+
+import (
+    "bytes"
+    "fmt"
+    "regexp"
+    "strings"
+    "text/template"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// payloadRoot is where RPM and provisioning exports place the bundle on the target host.
+const payloadRoot = "/opt/installforge"
+
+// rpmRequires maps preflight commands to the packages that provide them.
+var rpmRequires = map[string]string{
+    "unzip":     "unzip",
+    "tar":       "tar",
+    "sed":       "sed",
+    "grep":      "grep",
+    "systemctl": "systemd",
+    "chkconfig": "chkconfig",
+}
+
+var (
+    rpmNameInvalid = regexp.MustCompile(`[^A-Za-z0-9._+-]+`)
+    rpmVersionRe   = regexp.MustCompile(`^[A-Za-z0-9._+~]+$`)
+    specTmpl       = template.Must(template.New("spec").Parse(specTemplate))
+)
+
+// RPMResult holds a generated spec file and the source tarball layout it expects.
+type RPMResult struct {
+    Name       string   `json:"name"`
+    Version    string   `json:"version"`
+    Spec       string   `json:"spec"`
+    SourceDir  string   `json:"sourceDir"`
+    SourceFile string   `json:"sourceFile"`
+    // Packages are the bundled RPM assets the spec Requires; they are
+    // installed in the same transaction as the generated package.
+    Packages []string `json:"packages"`
+    Warnings []string `json:"warnings"`
+}
+
+// RPMSpec renders a spec that installs the bundle under /opt/installforge/<name>,
+// runs the install steps in %post and the uninstall steps in %preun. rpm
+// cannot run inside a scriptlet, so the packages of rpm_install steps become
+// Requires instead; opts supplies their headers and expands globs.
+func RPMSpec(r recipe.Recipe, opts recipe.Options) (RPMResult, error) {
+    name, err := payloadName(r)
+    if err != nil {
+        return RPMResult{}, err
+    }
+    version := r.Project.Version
+    if version == "" {
+        version = "1.0.0"
+    }
+    if !rpmVersionRe.MatchString(version) {
+        return RPMResult{}, fmt.Errorf("project version %q is not a valid rpm version", version)
+    }
+    res := RPMResult{
+        Name:       name,
+        Version:    version,
+        SourceDir:  name + "-" + version,
+        SourceFile: name + "-" + version + ".tar.gz",
+    }
+
+    var post []stepScript
+    for _, s := range r.Steps {
+        if s.Type == "rpm_install" {
+            cfg, _ := recipe.DecodeConfig(recipe.CurrentSchemaVersion, s)
+            if c, ok := cfg.(*recipe.RpmInstallConfig); ok {
+                for _, ref := range c.Rpms {
+                    if _, ok := recipe.AssetName(ref); !ok {
+                        res.Warnings = append(res.Warnings, fmt.Sprintf("step %s: %s is not a bundled asset; install it before this package", s.ID, ref))
+                    }
+                }
+            }
+            continue
+        }
+        script, err := renderStep(s)
+        if err != nil {
+            return RPMResult{}, err
+        }
+        post = append(post, stepScript{Step: s, Script: specEscape(script)})
+    }
+    preun, err := renderUninstallSteps(r.Steps)
+    if err != nil {
+        return RPMResult{}, err
+    }
+    for i := range preun {
+        preun[i].Script = specEscape(preun[i].Script)
+    }
+
+    var requires []string
+    for _, cmd := range gatherPreflight(r) {
+        if pkg, ok := rpmRequires[cmd]; ok {
+            requires = append(requires, pkg)
+        }
+    }
+    var bundled []string
+    seen := map[string]bool{}
+    for _, f := range recipe.BundledRPMs(r, opts) {
+        pkg := rpmPackageName(f, opts)
+        if pkg == "" {
+            res.Warnings = append(res.Warnings, fmt.Sprintf("cannot tell the package name of %s; add a Requires for it by hand", f))
+            continue
+        }
+        res.Packages = append(res.Packages, f)
+        if !seen[pkg] {
+            seen[pkg] = true
+            bundled = append(bundled, pkg)
+        }
+    }
+    vars := shellVars(r)
+    for i := range vars {
+        vars[i] = specEscape(vars[i])
+    }
+    summary := strings.TrimSuffix(firstLine(r.Project.Description), ".")
+    if summary == "" {
+        summary = "InstallForge bundle for " + r.Project.Name
+    }
+    description := r.Project.Description
+    if description == "" {
+        description = summary + "."
+    }
+    license := r.Project.License
+    if license == "" {
+        license = "Proprietary"
+    }
+    logDir := r.Vars["LOG_DIR"]
+    if logDir == "" {
+        logDir = "/var/log/asg"
+    }
+
+    var buf bytes.Buffer
+    data := map[string]interface{}{
+        "Name":        name,
+        "Version":     version,
+        "Summary":     specEscape(summary),
+        "Description": specEscape(wrapText(description, 79)),
+        "License":     specEscape(license),
+        "URL":         specEscape(r.Project.URL),
+        "Source":      res.SourceFile,
+        "PayloadDir":  payloadRoot + "/" + name,
+        "LogDir":      specEscape(logDir),
+        "Requires":    requires,
+        "Bundled":     bundled,
+        "Vars":        vars,
+        "Post":        post,
+        "Preun":       preun,
+        "Changelog":   time.Now().Format("Mon Jan 02 2006"),
+    }
+    if err := specTmpl.Execute(&buf, data); err != nil {
+        return RPMResult{}, err
+    }
+    res.Spec = buf.String()
+    return res, nil
+}
+
+// payloadName derives a package and directory name from the project name.
+func payloadName(r recipe.Recipe) (string, error) {
+    name := strings.Trim(rpmNameInvalid.ReplaceAllString(r.Project.Name, "-"), "-")
+    if name == "" {
+        return "", fmt.Errorf("project name %q cannot be used as a package name", r.Project.Name)
+    }
+    return name, nil
+}
+
+// rpmPackageName is the name of the package in RPM asset file, from its
+// header when readable, else from a name-version-release.arch.rpm file name.
+func rpmPackageName(file string, opts recipe.Options) string {
+    if opts.RPM != nil {
+        if info, ok := opts.RPM(file); ok && info.Name != "" {
+            return info.Name
+        }
+    }
+    base := strings.TrimSuffix(file, ".rpm")
+    if base == file {
+        return ""
+    }
+    if i := strings.LastIndex(base, "."); i >= 0 {
+        base = base[:i]
+    }
+    for n := 0; n < 2; n++ {
+        i := strings.LastIndex(base, "-")
+        if i <= 0 {
+            return ""
+        }
+        base = base[:i]
+    }
+    return base
+}
+
+// specEscape protects literal percent signs from rpm macro expansion.
+func specEscape(s string) string {
+    return strings.ReplaceAll(s, "%", "%%")
+}
+
+func firstLine(s string) string {
+    if i := strings.IndexByte(s, '\n'); i >= 0 {
+        s = s[:i]
+    }
+    return strings.TrimSpace(s)
+}
+
+// wrapText wraps words so no line exceeds width, as rpmlint expects of %description.
+func wrapText(s string, width int) string {
+    var lines []string
+    for _, para := range strings.Split(s, "\n") {
+        line := ""
+        for _, word := range strings.Fields(para) {
+            if line != "" && len(line)+1+len(word) > width {
+                lines = append(lines, line)
+                line = word
+                continue
+            }
+            if line != "" {
+                line += " "
+            }
+            line += word
+        }
+        lines = append(lines, line)
+    }
+    return strings.Join(lines, "\n")
+}
+
+const specTemplate = `%global payload_dir {{.PayloadDir}}
+
+Name:           {{.Name}}
+Version:        {{.Version}}
+Release:        1%{?dist}
+Summary:        {{.Summary}}
+License:        {{.License}}
+{{- if .URL}}
+URL:            {{.URL}}
+{{- end}}
+Source0:        {{.Source}}
+BuildArch:      noarch
+AutoReqProv:    no
+Requires(post): bash
+Requires(preun): bash
+{{- range .Requires}}
+Requires(post): {{.}}
+{{- end}}
+{{- range .Bundled}}
+Requires:       {{.}}
+Requires(post): {{.}}
+{{- end}}
+
+%description
+{{.Description}}
+
+%prep
+%setup -q
+
+%build
+
+%install
+mkdir -p %{buildroot}%{payload_dir}
+cp -a . %{buildroot}%{payload_dir}/
+
+%post -p /bin/bash
+set -eu
+SCRIPT_DIR=%{payload_dir}
+ASSET_DIR="$SCRIPT_DIR/assets"
+cd "$SCRIPT_DIR"
+{{- range .Vars}}
+{{.}}
+{{- end}}
+LOG_DIR="${LOG_DIR:-{{.LogDir}}}"
+mkdir -p "$LOG_DIR"
+{{range .Post}}
+echo "step={{.ID}} type={{.Type}} name={{.Name}}"
+{{.Script}}
+{{end}}
+%preun -p /bin/bash
+# $1 counts the versions left after this one goes: 0 on erase, 1 or more on
+# upgrade, when the new version's files must stay
+if [ "$1" = 0 ]; then
+set -u
+SCRIPT_DIR=%{payload_dir}
+ASSET_DIR="$SCRIPT_DIR/assets"
+{{- range .Vars}}
+{{.}}
+{{- end}}
+{{range .Preun}}
+echo "undo step={{.ID}} type={{.Type}} name={{.Name}}"
+{{.Script}}
+{{end}}
+fi
+
+%files
+%{payload_dir}
+
+%changelog
+* {{.Changelog}} InstallForge <installforge@localhost> - {{.Version}}-1
+- Generated by InstallForge
+`
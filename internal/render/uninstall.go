@@ -0,0 +1,129 @@
+package render
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "text/template"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+var (
+    uninstallTmpl     = template.Must(template.New("uninstall").Parse(uninstallTemplate))
+    uninstallStepTmpl = template.Must(template.New("uninstall_step").Parse(uninstallStepTemplate))
+)
+
+// renderUninstall renders uninstall.sh, which undoes steps in reverse order where that is safe.
+func renderUninstall(r recipe.Recipe) (string, error) {
+    steps, err := renderUninstallSteps(r.Steps)
+    if err != nil {
+        return "", err
+    }
+    var buf bytes.Buffer
+    data := map[string]interface{}{
+        "Recipe":      r,
+        "Steps":       steps,
+        "Vars":        shellVars(r),
+        "GeneratedAt": time.Now().Format(time.RFC3339),
+    }
+    if err := uninstallTmpl.Execute(&buf, data); err != nil {
+        return "", err
+    }
+    return buf.String(), nil
+}
+
+// renderUninstallSteps renders the undo snippets for steps, last step first.
+func renderUninstallSteps(steps []recipe.Step) ([]stepScript, error) {
+    res := make([]stepScript, 0, len(steps))
+    for i := len(steps) - 1; i >= 0; i-- {
+        s := steps[i]
+        var buf bytes.Buffer
+        if err := uninstallStepTmpl.Execute(&buf, decodeStep(s)); err != nil {
+            return nil, fmt.Errorf("step %s: %w", s.ID, err)
+        }
+        res = append(res, stepScript{Step: s, Script: strings.TrimSpace(buf.String())})
+    }
+    return res, nil
+}
+
+const uninstallTemplate = `#!/bin/bash
+set -u
+
+SCRIPT_DIR=$(cd "$(dirname "$0")" && pwd)
+ASSET_DIR="$SCRIPT_DIR/assets"
+{{- range .Vars}}
+{{.}}
+{{- end}}
+
+echo "[InstallForge] Uninstall generated at {{.GeneratedAt}}"
+
+if [ "$EUID" -ne 0 ]; then
+  echo "Please run as root (sudo ./uninstall.sh)" >&2
+  exit 1
+fi
+{{range .Steps}}
+echo "undo step={{.ID}} type={{.Type}} name={{.Name}}"
+{{.Script}}
+{{end}}
+echo "Uninstall finished"
+`
+
+const uninstallStepTemplate = `
+{{- if eq .Type "mkdir"}}
+rmdir "{{.Config.Path}}" 2>/dev/null || true
+{{- else if eq .Type "copy"}}
+if [ -d "{{.Config.Dest}}" ]; then
+  rm -f "{{.Config.Dest}}/$(basename "{{.Config.Src}}")"
+else
+  rm -f "{{.Config.Dest}}"
+fi
+{{- else if or (eq .Type "extract_tar_gz") (eq .Type "extract_zip")}}
+{{if .Config.Creates}}rm -rf "{{.Config.Creates}}"{{else}}echo "no creates path; files extracted to {{.Config.Dest}} are left in place"{{end}}
+{{- else if eq .Type "rpm_install"}}
+echo "packages installed from{{range $i, $v := .Config.Rpms}} {{$v}}{{end}} are left installed"
+{{- else if eq .Type "append_lines"}}
+target="{{.Config.File}}"
+record="$target.installforge.{{.ID}}"
+if [ ! -f "$record" ]; then
+  echo "no record of the lines appended to $target; they are left in place"
+else
+  # install appended the recorded lines at the end, so drop the last
+  # occurrence of each and keep any copies that were there before
+  if [ -s "$record" ] && [ -f "$target" ]; then
+    tac "$target" | awk 'NR == FNR { n[$0]++; next } n[$0] > 0 { n[$0]--; next } { print }' "$record" - | tac > "$target.tmp"
+    mv "$target.tmp" "$target"
+  fi
+  rm -f "$record"
+fi
+{{- else if or (eq .Type "delete_lines") (eq .Type "replace")}}
+echo "{{.Type}} on {{.Config.File}} is not reversible; restore from {{.Config.File}}.bak.* if needed"
+{{- else if eq .Type "run_cmd"}}
+echo "run_cmd is not reversible; review manually"
+{{- else if eq .Type "service_sysv"}}
+service {{.Config.Name}} stop || true
+if command -v chkconfig >/dev/null 2>&1; then
+  chkconfig --del {{.Config.Name}} || true
+fi
+rm -f "/etc/init.d/{{.Config.Name}}"
+{{- else if eq .Type "service_systemd"}}
+systemctl disable --now {{.Config.Name}} || true
+rm -f "/etc/systemd/system/{{.Config.Name}}.service"
+systemctl daemon-reload
+{{- else if eq .Type "auto_service"}}
+if command -v systemctl >/dev/null 2>&1; then
+  systemctl disable --now {{.Config.Name}} || true
+  rm -f "/etc/systemd/system/{{.Config.Name}}.service"
+  systemctl daemon-reload
+else
+  service {{.Config.Name}} stop || true
+  if command -v chkconfig >/dev/null 2>&1; then
+    chkconfig --del {{.Config.Name}} || true
+  fi
+  rm -f "/etc/init.d/{{.Config.Name}}"
+fi
+{{- else}}
+true
+{{- end}}
+`
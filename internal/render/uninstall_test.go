@@ -0,0 +1,66 @@
+package render
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "testing"
+
+    "installforge/internal/recipe"
+)
+
+// runSnippet runs a rendered step snippet with bash, as install.sh would.
+func runSnippet(t *testing.T, script string) {
+    t.Helper()
+    if out, err := exec.Command("bash", "-euc", script).CombinedOutput(); err != nil {
+        t.Fatalf("%v: %s\n%s", err, out, script)
+    }
+}
+
+func TestAppendLinesUndo(t *testing.T) {
+    tests := []struct {
+        name    string
+        before  string
+        lines   []string
+        unique  bool
+        install bool // run the install snippet before the undo
+        after   string
+    }{
+        {"unique keeps existing lines", "a\nkeep\n", []string{"keep", "new"}, true, true, "a\nkeep\n"},
+        {"unique all present", "keep\n", []string{"keep"}, true, true, "keep\n"},
+        {"duplicate keeps the earlier copy", "a\nkeep\n", []string{"keep"}, false, true, "a\nkeep\n"},
+        {"appended lines removed", "a\n", []string{"x", "y"}, false, true, "a\n"},
+        {"no record", "a\nkeep\n", []string{"keep"}, false, false, "a\nkeep\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            target := filepath.Join(t.TempDir(), "app.conf")
+            if err := os.WriteFile(target, []byte(tt.before), 0o644); err != nil {
+                t.Fatal(err)
+            }
+            lines := make([]interface{}, len(tt.lines))
+            for i, l := range tt.lines {
+                lines[i] = l
+            }
+            step := recipe.Step{ID: "s1", Type: "append_lines", Config: map[string]interface{}{"file": target, "lines": lines, "unique": tt.unique}}
+            snippets, err := RenderSnippets([]recipe.Step{step})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if tt.install {
+                runSnippet(t, snippets[0].Install)
+            }
+            runSnippet(t, snippets[0].Uninstall)
+            data, err := os.ReadFile(target)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if string(data) != tt.after {
+                t.Errorf("after undo = %q, want %q", data, tt.after)
+            }
+            if _, err := os.Stat(target + ".installforge.s1"); !os.IsNotExist(err) {
+                t.Errorf("record left behind: %v", err)
+            }
+        })
+    }
+}
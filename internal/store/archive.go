@@ -0,0 +1,66 @@
+package store
+
+import (
+    "archive/tar"
+    "compress/gzip"
+    "io"
+    "os"
+    "path/filepath"
+)
+
+// WriteTarGz packs srcDir into a gzip tarball at dest, placing entries under prefix.
+func WriteTarGz(srcDir, prefix, dest string) error {
+    if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+        return err
+    }
+    out, err := os.Create(dest)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+    gz := gzip.NewWriter(out)
+    tw := tar.NewWriter(gz)
+    err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        rel, err := filepath.Rel(srcDir, path)
+        if err != nil {
+            return err
+        }
+        name := filepath.ToSlash(filepath.Join(prefix, rel))
+        hdr, err := tar.FileInfoHeader(info, "")
+        if err != nil {
+            return err
+        }
+        hdr.Name = name
+        if info.IsDir() {
+            hdr.Name += "/"
+        }
+        hdr.Uname, hdr.Gname = "root", "root"
+        hdr.Uid, hdr.Gid = 0, 0
+        if err := tw.WriteHeader(hdr); err != nil {
+            return err
+        }
+        if !info.Mode().IsRegular() {
+            return nil
+        }
+        f, err := os.Open(path)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        _, err = io.Copy(tw, f)
+        return err
+    })
+    if err != nil {
+        return err
+    }
+    if err := tw.Close(); err != nil {
+        return err
+    }
+    if err := gz.Close(); err != nil {
+        return err
+    }
+    return out.Close()
+}
@@ -0,0 +1,272 @@
+package store
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "mime"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// ErrAssetExists is returned when a rename would overwrite another asset.
+var ErrAssetExists = errors.New("asset already exists")
+
+// AssetMeta describes an uploaded asset. ReferencedBy is computed from the
+// current recipe when listing and is not stored; Library names the library
+// package ("name@version") an asset listed from a recipe's pins comes from.
+type AssetMeta struct {
+    Filename     string    `json:"filename"`
+    Size         int64     `json:"size"`
+    SHA256       string    `json:"sha256"`
+    MIMEType     string    `json:"mimeType"`
+    UploadedAt   time.Time `json:"uploadedAt"`
+    Uploader     string    `json:"uploader"`
+    ReferencedBy []string  `json:"referencedBy,omitempty"`
+    Library      string    `json:"library,omitempty"`
+}
+
+// assetTypes covers the bundle file types mime.TypeByExtension does not know.
+var assetTypes = map[string]string{
+    ".rpm":     "application/x-rpm",
+    ".tgz":     "application/gzip",
+    ".gz":      "application/gzip",
+    ".zip":     "application/zip",
+    ".service": "text/plain; charset=utf-8",
+    ".sh":      "text/x-shellscript",
+}
+
+// assetIndexPath is assets.json, the metadata of every asset keyed by file
+// name. Its sha256 fields are the project's references into the blob store.
+func (s *Store) assetIndexPath(id string) string {
+    return filepath.Join(s.Root, id, "assets.json")
+}
+
+func (s *Store) loadAssetIndex(id string) (map[string]AssetMeta, error) {
+    return readAssetIndex(s.assetIndexPath(id))
+}
+
+// readAssetIndex reads the assets.json at path; a missing file is an empty index.
+func readAssetIndex(path string) (map[string]AssetMeta, error) {
+    idx := map[string]AssetMeta{}
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return idx, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, &idx); err != nil {
+        return nil, fmt.Errorf("assets.json: %w", err)
+    }
+    return idx, nil
+}
+
+func (s *Store) saveAssetIndex(id string, idx map[string]AssetMeta) error {
+    data, err := json.MarshalIndent(idx, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(s.assetIndexPath(id), data, 0o644)
+}
+
+// checkAssetName rejects names that would escape a bundle's assets directory
+// or collide with in-progress writes.
+func checkAssetName(name string) error {
+    if name == "" || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) || isTemp(name) {
+        return fmt.Errorf("invalid filename")
+    }
+    return nil
+}
+
+// assetHasher records the digest, size and leading bytes of an asset as it is written.
+type assetHasher struct {
+    sum  hash.Hash
+    size int64
+    head []byte
+}
+
+func newAssetHasher() *assetHasher {
+    return &assetHasher{sum: sha256.New()}
+}
+
+func (h *assetHasher) Write(p []byte) (int, error) {
+    h.sum.Write(p)
+    h.size += int64(len(p))
+    if n := 512 - len(h.head); n > 0 {
+        if n > len(p) {
+            n = len(p)
+        }
+        h.head = append(h.head, p[:n]...)
+    }
+    return len(p), nil
+}
+
+// meta builds the metadata of the asset written through h.
+func (h *assetHasher) meta(name, uploader string, at time.Time) AssetMeta {
+    return AssetMeta{
+        Filename:   name,
+        Size:       h.size,
+        SHA256:     hex.EncodeToString(h.sum.Sum(nil)),
+        MIMEType:   assetMIMEType(name, h.head),
+        UploadedAt: at,
+        Uploader:   uploader,
+    }
+}
+
+// assetMIMEType guesses a MIME type from the file name, then from its content.
+func assetMIMEType(name string, head []byte) string {
+    if t := mimeTypeByName(name); t != "" {
+        return t
+    }
+    return http.DetectContentType(head)
+}
+
+// mimeTypeByName returns the MIME type of the file extension, or "".
+func mimeTypeByName(name string) string {
+    lower := strings.ToLower(name)
+    for ext, t := range assetTypes {
+        if strings.HasSuffix(lower, ext) {
+            return t
+        }
+    }
+    return mime.TypeByExtension(filepath.Ext(lower))
+}
+
+// scanAsset computes metadata for an asset stored before metadata was kept.
+func scanAsset(path, name string) (AssetMeta, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    defer f.Close()
+    info, err := f.Stat()
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    h := newAssetHasher()
+    if _, err := io.Copy(h, f); err != nil {
+        return AssetMeta{}, err
+    }
+    return h.meta(name, "", info.ModTime()), nil
+}
+
+// AssetMetas lists the project's assets with their metadata, sorted by name.
+func (s *Store) AssetMetas(id string) ([]AssetMeta, error) {
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return nil, err
+    }
+    res := make([]AssetMeta, 0, len(idx))
+    for _, m := range idx {
+        res = append(res, m)
+    }
+    sort.Slice(res, func(i, j int) bool { return res[i].Filename < res[j].Filename })
+
+    if r, err := s.LoadRecipe(id); err == nil {
+        for i := range res {
+            res[i].ReferencedBy = recipe.AssetUsers(r, res[i].Filename)
+        }
+    }
+    return res, nil
+}
+
+// AssetMeta returns the metadata of one asset.
+func (s *Store) AssetMeta(id, name string) (AssetMeta, error) {
+    metas, err := s.AssetMetas(id)
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    for _, m := range metas {
+        if m.Filename == name {
+            return m, nil
+        }
+    }
+    return AssetMeta{}, os.ErrNotExist
+}
+
+// assetBlob returns the blob path of a project's asset.
+func (s *Store) assetBlob(id, name string) (string, error) {
+    if err := checkAssetName(name); err != nil {
+        return "", err
+    }
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return "", err
+    }
+    m, ok := idx[name]
+    if !ok {
+        return "", os.ErrNotExist
+    }
+    return s.blobPath(m.SHA256), nil
+}
+
+// OpenAsset opens an asset for download.
+func (s *Store) OpenAsset(id, name string) (*os.File, error) {
+    path, err := s.assetBlob(id, name)
+    if err != nil {
+        return nil, err
+    }
+    return os.Open(path)
+}
+
+// DeleteAsset removes an asset and its metadata. The blob goes with its last reference.
+func (s *Store) DeleteAsset(id, name string) error {
+    if err := checkAssetName(name); err != nil {
+        return err
+    }
+    defer s.lock(id)()
+    defer s.lockBlobs()()
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return err
+    }
+    m, ok := idx[name]
+    if !ok {
+        return os.ErrNotExist
+    }
+    delete(idx, name)
+    if err := s.saveAssetIndex(id, idx); err != nil {
+        return err
+    }
+    return s.addRefs(map[string]int{m.SHA256: -1})
+}
+
+// RenameAsset renames an asset, keeping its metadata and blob.
+func (s *Store) RenameAsset(id, oldName, newName string) (AssetMeta, error) {
+    if err := checkAssetName(oldName); err != nil {
+        return AssetMeta{}, err
+    }
+    if err := checkAssetName(newName); err != nil {
+        return AssetMeta{}, err
+    }
+    defer s.lock(id)()
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    m, ok := idx[oldName]
+    if !ok {
+        return AssetMeta{}, os.ErrNotExist
+    }
+    if _, ok := idx[newName]; ok {
+        return AssetMeta{}, ErrAssetExists
+    }
+    m.Filename = newName
+    if t := mimeTypeByName(newName); t != "" {
+        m.MIMEType = t
+    }
+    delete(idx, oldName)
+    idx[newName] = m
+    return m, s.saveAssetIndex(id, idx)
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+)
+
+// tempPrefix starts the names of files being written; readers skip them.
+const tempPrefix = ".tmp-"
+
+// projectLocks serializes writers per project and on the blob store. It is
+// keyed by directory so every Store opened on the same root shares the locks.
+var projectLocks sync.Map
+
+// lock takes the write lock of project id and returns its unlock function.
+func (s *Store) lock(id string) func() {
+    return lockPath(filepath.Join(s.Root, id))
+}
+
+// lockPath takes the lock of a directory and returns its unlock function.
+func lockPath(dir string) func() {
+    m, _ := projectLocks.LoadOrStore(dir, &sync.Mutex{})
+    mu := m.(*sync.Mutex)
+    mu.Lock()
+    return mu.Unlock
+}
+
+// writeFileAtomic replaces path with data so readers see either the old or
+// the new content, never a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+    return writeAtomic(path, perm, func(w io.Writer) error {
+        _, err := w.Write(data)
+        return err
+    })
+}
+
+// writeAtomic writes a temp file next to path with fill, syncs it and
+// renames it into place.
+func writeAtomic(path string, perm os.FileMode, fill func(w io.Writer) error) error {
+    tmp, err := createTemp(path, perm, fill)
+    if err != nil {
+        return err
+    }
+    return commitTemp(tmp, path)
+}
+
+// createTemp writes and syncs a hidden temp file in path's directory.
+func createTemp(path string, perm os.FileMode, fill func(w io.Writer) error) (string, error) {
+    dir := filepath.Dir(path)
+    f, err := os.CreateTemp(dir, tempPrefix+filepath.Base(path)+"-*")
+    if err != nil {
+        return "", err
+    }
+    tmp := f.Name()
+    fail := func(err error) (string, error) {
+        f.Close()
+        os.Remove(tmp)
+        return "", err
+    }
+    if err := fill(f); err != nil {
+        return fail(err)
+    }
+    if err := f.Chmod(perm); err != nil {
+        return fail(err)
+    }
+    if err := f.Sync(); err != nil {
+        return fail(err)
+    }
+    if err := f.Close(); err != nil {
+        os.Remove(tmp)
+        return "", err
+    }
+    return tmp, nil
+}
+
+// commitTemp renames a temp file from createTemp into place and syncs the directory.
+func commitTemp(tmp, path string) error {
+    if err := os.Rename(tmp, path); err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    syncDir(filepath.Dir(path))
+    return nil
+}
+
+// syncDir makes a rename durable; filesystems that cannot sync directories are ignored.
+func syncDir(dir string) {
+    if d, err := os.Open(dir); err == nil {
+        d.Sync()
+        d.Close()
+    }
+}
+
+// isTemp reports whether name is an in-progress write.
+func isTemp(name string) bool {
+    return strings.HasPrefix(name, tempPrefix)
+}
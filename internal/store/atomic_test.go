@@ -0,0 +1,121 @@
+package store
+
+import (
+    "errors"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "testing"
+)
+
+// tempFiles lists the in-progress writes left in dir.
+func tempFiles(t *testing.T, dir string) []string {
+    t.Helper()
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var res []string
+    for _, e := range entries {
+        if isTemp(e.Name()) {
+            res = append(res, e.Name())
+        }
+    }
+    return res
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "recipe.json")
+    for _, content := range []string{"first", "second"} {
+        if err := writeFileAtomic(path, []byte(content), 0o640); err != nil {
+            t.Fatal(err)
+        }
+        data, err := os.ReadFile(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if string(data) != content {
+            t.Errorf("content = %q, want %q", data, content)
+        }
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if info.Mode().Perm() != 0o640 {
+        t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+    }
+    if tmp := tempFiles(t, dir); len(tmp) > 0 {
+        t.Errorf("temp files left: %v", tmp)
+    }
+}
+
+func TestWriteAtomicFailure(t *testing.T) {
+    // a write that fails halfway leaves the old content and no temp file
+    dir := t.TempDir()
+    path := filepath.Join(dir, "recipe.json")
+    if err := writeFileAtomic(path, []byte("old"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    errFill := errors.New("disk full")
+    err := writeAtomic(path, 0o644, func(w io.Writer) error {
+        w.Write([]byte("partial"))
+        return errFill
+    })
+    if !errors.Is(err, errFill) {
+        t.Fatalf("err = %v, want %v", err, errFill)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(data) != "old" {
+        t.Errorf("content = %q, want the old content", data)
+    }
+    if tmp := tempFiles(t, dir); len(tmp) > 0 {
+        t.Errorf("temp files left: %v", tmp)
+    }
+}
+
+func TestConcurrentSaves(t *testing.T) {
+    // concurrent saves of one project each get their own revision
+    s := newTestStore(t)
+    r := newTestProject(t, s, nil)
+    const n = 20
+    revs := make([]int, n)
+    errs := make([]error, n)
+    var wg sync.WaitGroup
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            saved, err := s.SaveRecipe(r, Commit{})
+            revs[i], errs[i] = saved.Revision, err
+        }(i)
+    }
+    wg.Wait()
+    for _, err := range errs {
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    sort.Ints(revs)
+    for i, rev := range revs {
+        if rev != r.Revision+1+i {
+            t.Fatalf("revisions = %v, want %d..%d", revs, r.Revision+1, r.Revision+n)
+        }
+    }
+    got, err := s.LoadRecipe(r.Project.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Revision != r.Revision+n {
+        t.Errorf("current revision = %d, want %d", got.Revision, r.Revision+n)
+    }
+    if tmp := tempFiles(t, filepath.Join(s.Root, r.Project.ID)); len(tmp) > 0 {
+        t.Errorf("temp files left: %v", tmp)
+    }
+}
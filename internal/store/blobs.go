@@ -0,0 +1,339 @@
+package store
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+)
+
+// Asset content lives once per sha256 under <Blobs>/sha256/<aa>/<sum>; each
+// project's assets.json maps its file names to those sums, as library.json
+// does for library packages. refcounts.json counts the references so a blob
+// can be removed when its last name goes.
+
+// staleTempAge is how old a leftover temp file in the blob store must be
+// before gc removes it; younger ones may belong to uploads still running.
+const staleTempAge = 24 * time.Hour
+
+// GCReport describes a blob store garbage collection.
+type GCReport struct {
+    Blobs     int      `json:"blobs"`
+    Removed   []string `json:"removed"`
+    Freed     int64    `json:"freed"`
+    Corrected int      `json:"corrected"`
+    // Uploads lists the expired upload sessions as <project>/<upload>.
+    Uploads []string `json:"uploads"`
+}
+
+func (s *Store) blobDir() string {
+    return filepath.Join(s.Blobs, "sha256")
+}
+
+func (s *Store) blobPath(sum string) string {
+    return filepath.Join(s.blobDir(), sum[:2], sum)
+}
+
+func (s *Store) refcountPath() string {
+    return filepath.Join(s.Blobs, "refcounts.json")
+}
+
+// lockBlobs serializes changes to blobs and reference counts. Callers that
+// also hold a project lock must take that one first.
+func (s *Store) lockBlobs() func() {
+    return lockPath(s.Blobs)
+}
+
+func (s *Store) loadRefcounts() (map[string]int, error) {
+    refs := map[string]int{}
+    data, err := os.ReadFile(s.refcountPath())
+    if os.IsNotExist(err) {
+        return refs, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, &refs); err != nil {
+        return nil, fmt.Errorf("refcounts.json: %w", err)
+    }
+    return refs, nil
+}
+
+func (s *Store) saveRefcounts(refs map[string]int) error {
+    data, err := json.MarshalIndent(refs, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.refcountPath()), 0o755); err != nil {
+        return err
+    }
+    return writeFileAtomic(s.refcountPath(), data, 0o644)
+}
+
+// createBlobTemp streams src into a temp file inside the blob store, so it
+// can later be renamed to its content address.
+func (s *Store) createBlobTemp(fill func(w io.Writer) error) (string, error) {
+    if err := os.MkdirAll(s.blobDir(), 0o755); err != nil {
+        return "", err
+    }
+    return createTemp(filepath.Join(s.blobDir(), "blob"), 0o444, fill)
+}
+
+// commitBlob moves src to the blob of sum, or drops it when that content is
+// already stored. Callers hold the blob lock.
+func (s *Store) commitBlob(src, sum string) error {
+    dest := s.blobPath(sum)
+    if _, err := os.Stat(dest); err == nil {
+        return os.Remove(src)
+    }
+    if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+        return err
+    }
+    os.Chmod(src, 0o444)
+    if err := os.Rename(src, dest); err == nil {
+        syncDir(filepath.Dir(dest))
+        return nil
+    }
+    // src is on another filesystem
+    if err := copyFile(src, dest); err != nil {
+        return err
+    }
+    os.Chmod(dest, 0o444)
+    return os.Remove(src)
+}
+
+// addRefs applies reference count changes and removes blobs no longer
+// referenced. Callers hold the blob lock.
+func (s *Store) addRefs(delta map[string]int) error {
+    refs, err := s.loadRefcounts()
+    if err != nil {
+        return err
+    }
+    for sum, d := range delta {
+        if d == 0 {
+            continue
+        }
+        refs[sum] += d
+        if refs[sum] <= 0 {
+            delete(refs, sum)
+            if err := os.Remove(s.blobPath(sum)); err != nil && !os.IsNotExist(err) {
+                return err
+            }
+            os.Remove(s.inspectPath(sum))
+        }
+    }
+    return s.saveRefcounts(refs)
+}
+
+// storeAsset makes meta the project's asset of that name, committing the
+// blob staged at tmp. Callers hold the project lock.
+func (s *Store) storeAsset(id, tmp string, meta AssetMeta) error {
+    defer s.lockBlobs()()
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return err
+    }
+    if err := s.commitBlob(tmp, meta.SHA256); err != nil {
+        return err
+    }
+    delta := map[string]int{meta.SHA256: 1}
+    if old, ok := idx[meta.Filename]; ok {
+        delta[old.SHA256]--
+    }
+    idx[meta.Filename] = meta
+    if err := s.saveAssetIndex(id, idx); err != nil {
+        return err
+    }
+    return s.addRefs(delta)
+}
+
+// MigrateAssets moves the assets every project still keeps in its own
+// directory into the blob store. It runs at startup and from the migrate
+// and gc commands, so reads never have to move files.
+func (s *Store) MigrateAssets() error {
+    projects, err := os.ReadDir(s.Root)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    for _, e := range projects {
+        if e.IsDir() {
+            if err := s.migrateAssets(e.Name()); err != nil {
+                return fmt.Errorf("%s: %w", e.Name(), err)
+            }
+        }
+    }
+    return nil
+}
+
+// migrateAssets moves files a project keeps in its own assets directory,
+// as stored before the shared blob store, into the blob store.
+func (s *Store) migrateAssets(id string) error {
+    dir := filepath.Join(s.Root, id, "assets")
+    entries, err := os.ReadDir(dir)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    defer s.lock(id)()
+    defer s.lockBlobs()()
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return err
+    }
+    delta := map[string]int{}
+    for _, e := range entries {
+        if e.IsDir() || isTemp(e.Name()) {
+            continue
+        }
+        path := filepath.Join(dir, e.Name())
+        m, ok := idx[e.Name()]
+        if !ok || m.SHA256 == "" {
+            if m, err = scanAsset(path, e.Name()); err != nil {
+                return err
+            }
+        }
+        if err := s.commitBlob(path, m.SHA256); err != nil {
+            return err
+        }
+        idx[e.Name()] = m
+        delta[m.SHA256]++
+    }
+    if err := s.saveAssetIndex(id, idx); err != nil {
+        return err
+    }
+    if err := s.addRefs(delta); err != nil {
+        return err
+    }
+    os.Remove(dir)
+    return nil
+}
+
+// GC recounts blob references from every project's assets, trashed projects
+// and the library, and removes the blobs nothing references, along with
+// stale temp files and upload sessions idle for longer than UploadTTL. With
+// dryRun it only reports what would be removed.
+func (s *Store) GC(dryRun bool) (GCReport, error) {
+    projects, err := os.ReadDir(s.Root)
+    if err != nil && !os.IsNotExist(err) {
+        return GCReport{}, err
+    }
+    var uploads []string
+    for _, e := range projects {
+        if !e.IsDir() {
+            continue
+        }
+        expired, err := s.expireUploads(e.Name(), dryRun)
+        if err != nil {
+            return GCReport{}, fmt.Errorf("%s: %w", e.Name(), err)
+        }
+        for _, uid := range expired {
+            uploads = append(uploads, e.Name()+"/"+uid)
+        }
+    }
+    if !dryRun {
+        if err := s.MigrateAssets(); err != nil {
+            return GCReport{}, err
+        }
+    }
+
+    defer s.lockBlobs()()
+    counts := map[string]int{}
+    for _, e := range projects {
+        if !e.IsDir() {
+            continue
+        }
+        idx, err := s.loadAssetIndex(e.Name())
+        if err != nil {
+            return GCReport{}, fmt.Errorf("%s: %w", e.Name(), err)
+        }
+        for _, m := range idx {
+            counts[m.SHA256]++
+        }
+    }
+    trashed, err := os.ReadDir(s.Trash)
+    if err != nil && !os.IsNotExist(err) {
+        return GCReport{}, err
+    }
+    for _, e := range trashed {
+        if !e.IsDir() {
+            continue
+        }
+        idx, err := readAssetIndex(filepath.Join(s.trashDir(e.Name()), "assets.json"))
+        if err != nil {
+            return GCReport{}, fmt.Errorf("trash %s: %w", e.Name(), err)
+        }
+        for _, m := range idx {
+            counts[m.SHA256]++
+        }
+    }
+    library, err := s.loadLibrary()
+    if err != nil {
+        return GCReport{}, err
+    }
+    for _, e := range library {
+        counts[e.SHA256]++
+    }
+    refs, err := s.loadRefcounts()
+    if err != nil {
+        return GCReport{}, err
+    }
+    rep := GCReport{Removed: []string{}, Uploads: append([]string{}, uploads...)}
+    for sum, n := range counts {
+        if refs[sum] != n {
+            rep.Corrected++
+        }
+    }
+    for sum := range refs {
+        if _, ok := counts[sum]; !ok {
+            rep.Corrected++
+        }
+    }
+
+    err = filepath.WalkDir(s.blobDir(), func(path string, d os.DirEntry, err error) error {
+        if err != nil {
+            if os.IsNotExist(err) {
+                return nil
+            }
+            return err
+        }
+        if d.IsDir() {
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        name := d.Name()
+        if isTemp(name) {
+            if time.Since(info.ModTime()) < staleTempAge {
+                return nil
+            }
+        } else if counts[name] > 0 {
+            rep.Blobs++
+            return nil
+        }
+        rep.Removed = append(rep.Removed, name)
+        rep.Freed += info.Size()
+        if dryRun {
+            return nil
+        }
+        os.Remove(s.inspectPath(name))
+        return os.Remove(path)
+    })
+    if err != nil {
+        return rep, err
+    }
+    sort.Strings(rep.Removed)
+    if dryRun {
+        return rep, nil
+    }
+    return rep, s.saveRefcounts(counts)
+}
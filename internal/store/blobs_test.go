@@ -0,0 +1,167 @@
+package store
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+// blobExists reports whether content is stored in the blob store.
+func blobExists(s *Store, content string) bool {
+    _, err := os.Stat(s.blobPath(sum(content)))
+    return err == nil
+}
+
+func TestBlobRefcounts(t *testing.T) {
+    s := newTestStore(t)
+    p1 := newTestProject(t, s, map[string]string{"a.txt": "shared", "b.txt": "only p1"}).Project.ID
+    p2 := newTestProject(t, s, map[string]string{"c.txt": "shared"}).Project.ID
+    steps := []struct {
+        name string
+        do   func() error
+        refs map[string]int // content to refcount; 0 means the blob is gone
+    }{
+        {"deduplicated", func() error { return nil }, map[string]int{"shared": 2, "only p1": 1}},
+        {"same name twice", func() error {
+            _, err := s.SaveAsset(p1, "b.txt", "test", strings.NewReader("only p1"))
+            return err
+        }, map[string]int{"shared": 2, "only p1": 1}},
+        {"replaced", func() error {
+            _, err := s.SaveAsset(p1, "b.txt", "test", strings.NewReader("new p1"))
+            return err
+        }, map[string]int{"shared": 2, "only p1": 0, "new p1": 1}},
+        {"renamed", func() error {
+            _, err := s.RenameAsset(p1, "a.txt", "d.txt")
+            return err
+        }, map[string]int{"shared": 2, "new p1": 1}},
+        {"deleted", func() error { return s.DeleteAsset(p2, "c.txt") }, map[string]int{"shared": 1, "new p1": 1}},
+        {"trashed", func() error {
+            _, err := s.TrashProject(p1)
+            return err
+        }, map[string]int{"shared": 1, "new p1": 1}},
+        {"purged", func() error { return s.PurgeProject(p1) }, map[string]int{"shared": 0, "new p1": 0}},
+    }
+    for _, st := range steps {
+        if err := st.do(); err != nil {
+            t.Fatalf("%s: %v", st.name, err)
+        }
+        for content, want := range st.refs {
+            if n := refcount(t, s, content); n != want {
+                t.Errorf("%s: refcount of %q = %d, want %d", st.name, content, n, want)
+            }
+            if blobExists(s, content) != (want > 0) {
+                t.Errorf("%s: blob of %q exists = %v, want %v", st.name, content, !(want > 0), want > 0)
+            }
+        }
+    }
+}
+
+func TestGC(t *testing.T) {
+    s := newTestStore(t)
+    p1 := newTestProject(t, s, map[string]string{"a.txt": "alpha"}).Project.ID
+    p2 := newTestProject(t, s, map[string]string{"b.txt": "beta"}).Project.ID
+    if _, err := s.TrashProject(p2); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.AddLibraryEntry("tool", "1.0", "tool.rpm", "", "test", strings.NewReader("gamma")); err != nil {
+        t.Fatal(err)
+    }
+    // counts gone wrong, an orphaned blob and temp files of different ages
+    if err := s.saveRefcounts(map[string]int{sum("alpha"): 5, sum("orphan"): 1}); err != nil {
+        t.Fatal(err)
+    }
+    orphan := s.blobPath(sum("orphan"))
+    os.MkdirAll(filepath.Dir(orphan), 0o755)
+    if err := os.WriteFile(orphan, []byte("orphan"), 0o444); err != nil {
+        t.Fatal(err)
+    }
+    stale := filepath.Join(s.blobDir(), tempPrefix+"blob-stale")
+    fresh := filepath.Join(s.blobDir(), tempPrefix+"blob-fresh")
+    for _, p := range []string{stale, fresh} {
+        if err := os.WriteFile(p, []byte("part"), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    old := time.Now().Add(-2 * staleTempAge)
+    os.Chtimes(stale, old, old)
+
+    dry, err := s.GC(true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    wantRemoved := []string{tempPrefix + "blob-stale", sum("orphan")}
+    if !reflect.DeepEqual(dry.Removed, wantRemoved) || dry.Blobs != 3 || dry.Corrected != 4 {
+        t.Errorf("dry run report = %+v", dry)
+    }
+    if _, err := os.Stat(orphan); err != nil {
+        t.Errorf("dry run removed the orphan: %v", err)
+    }
+
+    rep, err := s.GC(false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(rep.Removed, wantRemoved) || rep.Freed != int64(len("orphan")+len("part")) {
+        t.Errorf("report = %+v", rep)
+    }
+    for _, p := range []string{orphan, stale} {
+        if _, err := os.Stat(p); !os.IsNotExist(err) {
+            t.Errorf("%s kept: %v", filepath.Base(p), err)
+        }
+    }
+    if _, err := os.Stat(fresh); err != nil {
+        t.Errorf("fresh temp removed: %v", err)
+    }
+    refs, err := s.loadRefcounts()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]int{sum("alpha"): 1, sum("beta"): 1, sum("gamma"): 1}
+    if !reflect.DeepEqual(refs, want) {
+        t.Errorf("refcounts = %v, want %v", refs, want)
+    }
+    if data, err := s.ReadAsset(p1, "a.txt"); err != nil || string(data) != "alpha" {
+        t.Errorf("asset after gc = %q, %v", data, err)
+    }
+    if again, err := s.GC(false); err != nil || again.Corrected != 0 || len(again.Removed) != 0 {
+        t.Errorf("second gc = %+v, %v", again, err)
+    }
+}
+
+func TestMigrateAssets(t *testing.T) {
+    // assets kept in the project directory before the blob store move into it
+    s := newTestStore(t)
+    id := newTestProject(t, s, map[string]string{"kept.txt": "shared"}).Project.ID
+    legacy := filepath.Join(s.Root, id, "assets")
+    if err := os.MkdirAll(legacy, 0o755); err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range map[string]string{"old.txt": "legacy", "dup.txt": "shared"} {
+        if err := os.WriteFile(filepath.Join(legacy, name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := s.MigrateAssets(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+        t.Errorf("legacy assets directory kept: %v", err)
+    }
+    for name, content := range map[string]string{"old.txt": "legacy", "dup.txt": "shared", "kept.txt": "shared"} {
+        if data, err := s.ReadAsset(id, name); err != nil || string(data) != content {
+            t.Errorf("%s = %q, %v", name, data, err)
+        }
+    }
+    if n := refcount(t, s, "shared"); n != 2 {
+        t.Errorf("refcount = %d, want 2", n)
+    }
+    if err := s.MigrateAssets(); err != nil {
+        t.Fatal(err)
+    }
+    if n := refcount(t, s, "legacy"); n != 1 {
+        t.Errorf("refcount after a second run = %d, want 1", n)
+    }
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// ErrInvalidBundle is returned for bundles that cannot be read or hold no recipe.json.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// ErrImportPath is returned for bundle paths outside the import directory.
+var ErrImportPath = errors.New("bundle path is outside the import directory")
+
+// runHeaderLimit bounds how far into a .run file the archive payload is looked for.
+const runHeaderLimit = 1 << 20
+
+// An imported archive may be at most MaxBundleBytes large, and unpack to at
+// most MaxBundleBytes in at most MaxBundleEntries files and directories.
+const (
+    MaxBundleBytes   = 10 << 30
+    MaxBundleEntries = 100000
+)
+
+// extractBudget counts what an extraction has written against the caps.
+type extractBudget struct {
+    bytes   int64
+    entries int
+}
+
+// entry accounts for one more archive entry.
+func (b *extractBudget) entry() error {
+    b.entries++
+    if b.entries > MaxBundleEntries {
+        return fmt.Errorf("%w: more than %d entries", ErrInvalidBundle, MaxBundleEntries)
+    }
+    return nil
+}
+
+// OpenBundle makes the bundle at path available as a directory. A bundle
+// directory is used in place; a tar.gz, tar, zip or self-extracting .run
+// archive (a shell header followed by a tar.gz payload) is recognized by
+// its content and extracted to a temp directory, which cleanup removes.
+func OpenBundle(path string) (dir string, cleanup func(), err error) {
+    info, err := os.Stat(path)
+    if err != nil {
+        return "", nil, err
+    }
+    if info.IsDir() {
+        dir, err := bundleRoot(path)
+        return dir, func() {}, err
+    }
+    if info.Size() > MaxBundleBytes {
+        return "", nil, fmt.Errorf("%w: archive is larger than %d bytes", ErrInvalidBundle, MaxBundleBytes)
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return "", nil, err
+    }
+    defer f.Close()
+    tmp, err := os.MkdirTemp("", "asg-import-")
+    if err != nil {
+        return "", nil, err
+    }
+    cleanup = func() { os.RemoveAll(tmp) }
+    if err := extractBundle(f, info.Size(), tmp); err != nil {
+        cleanup()
+        return "", nil, err
+    }
+    if dir, err = bundleRoot(tmp); err != nil {
+        cleanup()
+        return "", nil, err
+    }
+    return dir, cleanup, nil
+}
+
+// ImportPath resolves a server-side bundle path against Imports: relative
+// paths are taken inside it, and the path, with symlinks resolved, must
+// lie within it.
+func (s *Store) ImportPath(p string) (string, error) {
+    if s.Imports == "" {
+        return "", fmt.Errorf("%w: importing by path is disabled", ErrImportPath)
+    }
+    base, err := filepath.Abs(s.Imports)
+    if err != nil {
+        return "", err
+    }
+    if !filepath.IsAbs(p) {
+        p = filepath.Join(base, p)
+    }
+    p = filepath.Clean(p)
+    root, err := filepath.EvalSymlinks(base)
+    if err != nil {
+        return "", fmt.Errorf("%w: %v", ErrImportPath, err)
+    }
+    // paths outside are refused before they are looked at
+    if !within(base, p) && !within(root, p) {
+        return "", fmt.Errorf("%w: %s", ErrImportPath, p)
+    }
+    resolved, err := filepath.EvalSymlinks(p)
+    if err != nil {
+        return "", err
+    }
+    if !within(root, resolved) {
+        return "", fmt.Errorf("%w: %s", ErrImportPath, p)
+    }
+    return resolved, nil
+}
+
+// within reports whether path is dir or below it; both are clean.
+func within(dir, path string) bool {
+    rel, err := filepath.Rel(dir, path)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// bundleRoot finds recipe.json in dir or in its only subdirectory, where
+// archives packed with a top-level folder keep it.
+func bundleRoot(dir string) (string, error) {
+    if _, err := os.Stat(filepath.Join(dir, "recipe.json")); err == nil {
+        return dir, nil
+    }
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return "", err
+    }
+    var subdirs []string
+    for _, e := range entries {
+        if e.IsDir() {
+            subdirs = append(subdirs, e.Name())
+        }
+    }
+    if len(subdirs) == 1 {
+        sub := filepath.Join(dir, subdirs[0])
+        if _, err := os.Stat(filepath.Join(sub, "recipe.json")); err == nil {
+            return sub, nil
+        }
+    }
+    return "", fmt.Errorf("%w: no recipe.json found", ErrInvalidBundle)
+}
+
+func extractBundle(f *os.File, size int64, dest string) error {
+    b := &extractBudget{}
+    head := make([]byte, 512)
+    n, _ := io.ReadFull(f, head)
+    head = head[:n]
+    switch {
+    case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+        return extractTarGz(io.NewSectionReader(f, 0, size), dest, b)
+    case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+        return extractZip(f, size, dest, b)
+    case len(head) >= 262 && string(head[257:262]) == "ustar":
+        return extractTar(io.NewSectionReader(f, 0, size), dest, b)
+    case bytes.HasPrefix(head, []byte("#!")):
+        off, err := runPayload(f)
+        if err != nil {
+            return err
+        }
+        return extractTarGz(io.NewSectionReader(f, off, size-off), dest, b)
+    }
+    return fmt.Errorf("%w: not a directory, tar.gz, zip or .run archive", ErrInvalidBundle)
+}
+
+// runPayload returns the offset of the gzip payload of a .run archive: the
+// first gzip header that follows the shell script.
+func runPayload(f *os.File) (int64, error) {
+    br := bufio.NewReader(io.NewSectionReader(f, 0, runHeaderLimit))
+    var off int64
+    for {
+        line, err := br.ReadBytes('\n')
+        if i := bytes.Index(line, []byte{0x1f, 0x8b, 0x08}); i >= 0 {
+            return off + int64(i), nil
+        }
+        off += int64(len(line))
+        if err != nil {
+            return 0, fmt.Errorf("%w: no tar.gz payload in .run archive", ErrInvalidBundle)
+        }
+    }
+}
+
+// entryPath maps an archive entry name into dest, dropping any "..".
+func entryPath(dest, name string) string {
+    name = path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+    if name == "/" {
+        return ""
+    }
+    return filepath.Join(dest, filepath.FromSlash(strings.TrimPrefix(name, "/")))
+}
+
+func extractTarGz(r io.Reader, dest string, b *extractBudget) error {
+    gz, err := gzip.NewReader(r)
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+    }
+    defer gz.Close()
+    return extractTar(gz, dest, b)
+}
+
+// extractTar writes the directories and regular files of a tar stream;
+// links and special files are skipped.
+func extractTar(r io.Reader, dest string, b *extractBudget) error {
+    tr := tar.NewReader(r)
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+        }
+        if err := b.entry(); err != nil {
+            return err
+        }
+        target := entryPath(dest, hdr.Name)
+        if target == "" {
+            continue
+        }
+        switch hdr.Typeflag {
+        case tar.TypeDir:
+            if err := os.MkdirAll(target, 0o755); err != nil {
+                return err
+            }
+        case tar.TypeReg:
+            if err := extractFile(target, tr, b); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+func extractZip(f *os.File, size int64, dest string, b *extractBudget) error {
+    zr, err := zip.NewReader(f, size)
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+    }
+    for _, zf := range zr.File {
+        if err := b.entry(); err != nil {
+            return err
+        }
+        target := entryPath(dest, zf.Name)
+        if target == "" {
+            continue
+        }
+        if zf.FileInfo().IsDir() {
+            if err := os.MkdirAll(target, 0o755); err != nil {
+                return err
+            }
+            continue
+        }
+        if !zf.Mode().IsRegular() {
+            continue
+        }
+        rc, err := zf.Open()
+        if err != nil {
+            return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+        }
+        err = extractFile(target, rc, b)
+        rc.Close()
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// extractFile writes r to target, failing once the extraction as a whole
+// goes over MaxBundleBytes; headers may understate sizes, so the bytes
+// actually written are counted.
+func extractFile(target string, r io.Reader, b *extractBudget) error {
+    if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+        return err
+    }
+    out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+    if err != nil {
+        return err
+    }
+    left := MaxBundleBytes - b.bytes
+    n, err := io.Copy(out, io.LimitReader(r, left+1))
+    b.bytes += n
+    if err == nil && n > left {
+        err = fmt.Errorf("unpacks to more than %d bytes", MaxBundleBytes)
+    }
+    if err != nil {
+        out.Close()
+        return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+    }
+    return out.Close()
+}
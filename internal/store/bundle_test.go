@@ -0,0 +1,238 @@
+package store
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// file is an archive entry; a name ending in "/" is a directory.
+type file struct {
+    name, content string
+}
+
+func tarData(t *testing.T, files []file) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    tw := tar.NewWriter(&buf)
+    for _, f := range files {
+        hdr := &tar.Header{Name: f.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(f.content))}
+        if strings.HasSuffix(f.name, "/") {
+            hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0o755, 0
+        }
+        if err := tw.WriteHeader(hdr); err != nil {
+            t.Fatal(err)
+        }
+        tw.Write([]byte(f.content))
+    }
+    if err := tw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func tarGzData(t *testing.T, files []file) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+    gz.Write(tarData(t, files))
+    if err := gz.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func zipData(t *testing.T, files []file) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    zw := zip.NewWriter(&buf)
+    for _, f := range files {
+        w, err := zw.Create(f.name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        w.Write([]byte(f.content))
+    }
+    if err := zw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+const bundleRecipe = `{"schema_version":"1.0","project":{"id":"old","name":"imported"},"vars":{},"steps":[]}`
+
+func TestOpenBundle(t *testing.T) {
+    files := []file{{"demo/", ""}, {"demo/recipe.json", bundleRecipe}, {"demo/assets/a.txt", "alpha"}}
+    run := append([]byte("#!/bin/sh\nsed '1,/^__ARCHIVE__$/d' \"$0\" | tar xz\nexit 0\n__ARCHIVE__\n"), tarGzData(t, files)...)
+    tests := []struct {
+        name string
+        data []byte
+        err  bool
+    }{
+        {"tar.gz", tarGzData(t, files), false},
+        {"tar", tarData(t, files), false},
+        {"zip", zipData(t, files), false},
+        {"run", run, false},
+        {"flat", tarGzData(t, []file{{"recipe.json", bundleRecipe}, {"assets/a.txt", "alpha"}}), false},
+        {"no recipe", tarGzData(t, []file{{"assets/a.txt", "alpha"}}), true},
+        {"not an archive", []byte("plain text"), true},
+        {"truncated", tarGzData(t, files)[:40], true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), "bundle")
+            if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+                t.Fatal(err)
+            }
+            dir, cleanup, err := OpenBundle(path)
+            if tt.err {
+                if !errors.Is(err, ErrInvalidBundle) {
+                    t.Fatalf("err = %v, want ErrInvalidBundle", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer cleanup()
+            if data, err := os.ReadFile(filepath.Join(dir, "assets", "a.txt")); err != nil || string(data) != "alpha" {
+                t.Errorf("asset = %q, %v", data, err)
+            }
+            cleanup()
+            if _, err := os.Stat(dir); !os.IsNotExist(err) {
+                t.Errorf("cleanup left %s: %v", dir, err)
+            }
+        })
+    }
+}
+
+func TestExtractConfined(t *testing.T) {
+    // entry names cannot climb out of the extraction directory
+    names := []string{"../evil.txt", "../../evil.txt", "/evil.txt", `..\evil.txt`, "a/../../evil.txt"}
+    for _, format := range []string{"tar", "zip"} {
+        t.Run(format, func(t *testing.T) {
+            root := t.TempDir()
+            dest := filepath.Join(root, "dest")
+            os.Mkdir(dest, 0o755)
+            var files []file
+            for _, n := range names {
+                files = append(files, file{n, "x"})
+            }
+            var err error
+            if format == "tar" {
+                err = extractTar(bytes.NewReader(tarData(t, files)), dest, &extractBudget{})
+            } else {
+                data := zipData(t, files)
+                f := filepath.Join(root, "b.zip")
+                os.WriteFile(f, data, 0o644)
+                zf, _ := os.Open(f)
+                defer zf.Close()
+                err = extractZip(zf, int64(len(data)), dest, &extractBudget{})
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if _, err := os.Stat(filepath.Join(root, "evil.txt")); !os.IsNotExist(err) {
+                t.Errorf("entry written outside dest: %v", err)
+            }
+        })
+    }
+}
+
+func TestExtractBudget(t *testing.T) {
+    files := []file{{"recipe.json", "{}"}, {"assets/a.bin", "0123456789"}}
+    tests := []struct {
+        name   string
+        budget extractBudget // what earlier entries already used
+        err    string
+    }{
+        {"within caps", extractBudget{}, ""},
+        {"entries at the cap", extractBudget{entries: MaxBundleEntries - 2}, ""},
+        {"too many entries", extractBudget{entries: MaxBundleEntries - 1}, "entries"},
+        {"bytes at the cap", extractBudget{bytes: MaxBundleBytes - 12}, ""},
+        {"too many bytes", extractBudget{bytes: MaxBundleBytes - 11}, "bytes"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := tt.budget
+            err := extractTar(bytes.NewReader(tarData(t, files)), t.TempDir(), &b)
+            if tt.err == "" {
+                if err != nil {
+                    t.Fatal(err)
+                }
+                return
+            }
+            if !errors.Is(err, ErrInvalidBundle) || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("err = %v, want ErrInvalidBundle about %s", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestImportPath(t *testing.T) {
+    data := t.TempDir()
+    s := New(filepath.Join(data, "projects"))
+    imports := filepath.Join(data, "import")
+    outside := filepath.Join(data, "outside")
+    for _, d := range []string{filepath.Join(imports, "sub"), outside} {
+        if err := os.MkdirAll(d, 0o755); err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, f := range []string{filepath.Join(imports, "b.tar.gz"), filepath.Join(imports, "sub", "c.zip"), filepath.Join(outside, "x.tar.gz")} {
+        os.WriteFile(f, nil, 0o644)
+    }
+    os.Symlink(filepath.Join(outside, "x.tar.gz"), filepath.Join(imports, "escape.tar.gz"))
+    os.Symlink(outside, filepath.Join(imports, "escapedir"))
+    os.Symlink(filepath.Join(imports, "sub", "c.zip"), filepath.Join(imports, "link.zip"))
+    linked := filepath.Join(data, "linked")
+    os.Symlink(imports, linked)
+
+    tests := []struct {
+        name    string
+        imports string
+        path    string
+        want    string // resolved path, "" when refused
+        denied  bool   // refused with ErrImportPath
+    }{
+        {"relative", imports, "b.tar.gz", filepath.Join(imports, "b.tar.gz"), false},
+        {"nested", imports, "sub/c.zip", filepath.Join(imports, "sub", "c.zip"), false},
+        {"absolute inside", imports, filepath.Join(imports, "b.tar.gz"), filepath.Join(imports, "b.tar.gz"), false},
+        {"link inside", imports, "link.zip", filepath.Join(imports, "sub", "c.zip"), false},
+        {"root through a link", linked, "b.tar.gz", filepath.Join(imports, "b.tar.gz"), false},
+        {"dot dot", imports, "../outside/x.tar.gz", "", true},
+        {"dot dot inside a path", imports, "sub/../../outside/x.tar.gz", "", true},
+        {"absolute outside", imports, filepath.Join(outside, "x.tar.gz"), "", true},
+        {"link to a file outside", imports, "escape.tar.gz", "", true},
+        {"link to a directory outside", imports, "escapedir/x.tar.gz", "", true},
+        {"disabled", "", "b.tar.gz", "", true},
+        {"missing", imports, "none.tar.gz", "", false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s.Imports = tt.imports
+            got, err := s.ImportPath(tt.path)
+            if errors.Is(err, ErrImportPath) != tt.denied {
+                t.Fatalf("err = %v, want denied %v", err, tt.denied)
+            }
+            if tt.want == "" {
+                if err == nil {
+                    t.Fatalf("resolved to %s, want an error", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if want, _ := filepath.EvalSymlinks(tt.want); got != want {
+                t.Errorf("got %s, want %s", got, want)
+            }
+        })
+    }
+}
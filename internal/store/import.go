@@ -0,0 +1,320 @@
+package store
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// ErrImportConflict is returned when bundle assets differ from assets of
+// the same name and no conflict policy was given.
+var ErrImportConflict = errors.New("bundle assets conflict with existing assets")
+
+// ImportOptions controls ImportBundle. Project names an existing project to
+// add a revision to; empty creates a new one. With BaseRevision set, the
+// import fails with a *ConflictError unless the project is still at it. OnConflict decides what
+// happens to assets whose content differs from an existing asset of the
+// same name: "" fails, "replace" takes the bundle's file, "keep" the
+// existing one. Files of pinned library packages are never replaced.
+type ImportOptions struct {
+    Project    string
+    OnConflict string
+    DryRun     bool
+    Commit
+}
+
+// AssetConflict is a bundle asset whose content differs from the existing
+// asset of that name, or from the library package the recipe pins.
+type AssetConflict struct {
+    Filename       string `json:"filename"`
+    Library        string `json:"library,omitempty"`
+    ExistingSHA256 string `json:"existingSha256"`
+    ExistingSize   int64  `json:"existingSize"`
+    BundleSHA256   string `json:"bundleSha256"`
+    BundleSize     int64  `json:"bundleSize"`
+}
+
+// ImportResult describes an import, or with DryRun what it would do.
+type ImportResult struct {
+    Recipe    recipe.Recipe          `json:"recipe"`
+    Created   bool                   `json:"created"`
+    Migration recipe.MigrationReport `json:"migration"`
+    Added     []string               `json:"added"`
+    Replaced  []string               `json:"replaced"`
+    Unchanged []string               `json:"unchanged"`
+    Kept      []string               `json:"kept"`
+    Library   []string               `json:"library"`
+    Conflicts []AssetConflict        `json:"conflicts"`
+}
+
+// ImportBundle reads the bundle directory dir (recipe.json and assets/),
+// migrates the recipe to the current schema and stores it as a new
+// project or a new revision of opts.Project, along with its assets.
+// The bundle's files are copied into the blob store first; conflicts, the
+// quota and opts.BaseRevision are then checked and the assets and recipe
+// committed under the project lock, the recipe last, so a failed import
+// leaves the project as it was.
+func (s *Store) ImportBundle(dir string, opts ImportOptions) (ImportResult, error) {
+    switch opts.OnConflict {
+    case "", "replace", "keep":
+    default:
+        return ImportResult{}, fmt.Errorf("onConflict must be replace or keep, not %q", opts.OnConflict)
+    }
+    if opts.Project != "" {
+        if err := projectDirName(opts.Project); err != nil {
+            return ImportResult{}, err
+        }
+    }
+    data, err := os.ReadFile(filepath.Join(dir, "recipe.json"))
+    if err != nil {
+        return ImportResult{}, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+    }
+    rec, report, err := recipe.Migrate(data)
+    if err != nil {
+        return ImportResult{}, fmt.Errorf("%w: recipe.json: %v", ErrInvalidBundle, err)
+    }
+    res := ImportResult{Migration: report, Added: []string{}, Replaced: []string{}, Unchanged: []string{}, Kept: []string{}, Library: []string{}, Conflicts: []AssetConflict{}}
+
+    var files []AssetMeta
+    staged := map[string]string{}
+    if opts.DryRun {
+        files, err = bundleAssets(dir)
+    } else {
+        // committed blobs are moved away, so this only drops the unused ones
+        defer func() {
+            for _, tmp := range staged {
+                os.Remove(tmp)
+            }
+        }()
+        files, err = s.stageBundleAssets(dir, opts.Author, staged)
+    }
+    if err != nil {
+        return ImportResult{}, err
+    }
+
+    id := opts.Project
+    if id == "" {
+        res.Created = true
+        id = randomID()
+    }
+    defer s.lock(id)()
+    existing := map[string]AssetMeta{}
+    if res.Created {
+        rec.Project.Archived = false
+    } else {
+        cur, err := s.LoadRecipe(id)
+        if err != nil {
+            return ImportResult{}, err
+        }
+        if existing, err = s.loadAssetIndex(id); err != nil {
+            return ImportResult{}, err
+        }
+        rec.Project.Archived = cur.Project.Archived
+        if opts.BaseRevision != nil && *opts.BaseRevision != cur.Revision {
+            rec.Project.ID = id
+            res.Recipe = rec
+            return res, &ConflictError{Base: *opts.BaseRevision, Current: cur.Revision}
+        }
+    }
+    rec.Project.ID = id
+
+    pinned, err := s.pinnedFiles(rec)
+    if err != nil {
+        return ImportResult{}, err
+    }
+    var save []AssetMeta
+    var grow int64
+    for _, m := range files {
+        lib, isLib := pinned[m.Filename]
+        old, exists := existing[m.Filename]
+        switch {
+        case isLib && lib.SHA256 == m.SHA256:
+            res.Library = append(res.Library, m.Filename)
+        case isLib:
+            res.Conflicts = append(res.Conflicts, AssetConflict{Filename: m.Filename, Library: lib.Name + "@" + lib.Version, ExistingSHA256: lib.SHA256, ExistingSize: lib.Size, BundleSHA256: m.SHA256, BundleSize: m.Size})
+            res.Kept = append(res.Kept, m.Filename)
+        case !exists:
+            res.Added = append(res.Added, m.Filename)
+            save = append(save, m)
+            grow += m.Size
+        case old.SHA256 == m.SHA256:
+            res.Unchanged = append(res.Unchanged, m.Filename)
+        default:
+            res.Conflicts = append(res.Conflicts, AssetConflict{Filename: m.Filename, ExistingSHA256: old.SHA256, ExistingSize: old.Size, BundleSHA256: m.SHA256, BundleSize: m.Size})
+            if opts.OnConflict == "replace" {
+                res.Replaced = append(res.Replaced, m.Filename)
+                save = append(save, m)
+                grow += m.Size - old.Size
+            } else {
+                res.Kept = append(res.Kept, m.Filename)
+            }
+        }
+    }
+    res.Recipe = rec
+    if len(res.Conflicts) > 0 && opts.OnConflict == "" {
+        return res, ErrImportConflict
+    }
+    if opts.DryRun {
+        return res, nil
+    }
+    if grow > 0 {
+        if err := s.checkQuota(id, grow); err != nil {
+            return res, err
+        }
+    }
+
+    if opts.Message == "" {
+        opts.Message = "import bundle"
+    }
+    if res.Created {
+        if _, err := s.EnsureProjectDir(id); err != nil {
+            return res, err
+        }
+    }
+    if err := s.commitImportAssets(id, existing, save, staged); err != nil {
+        return res, err
+    }
+    if res.Recipe, err = s.writeRecipe(rec, opts.Commit, res.Created); err != nil {
+        if rerr := s.restoreAssetIndex(id, existing); rerr != nil {
+            err = fmt.Errorf("%v; restoring the asset index failed: %v", err, rerr)
+        }
+        if res.Created {
+            os.RemoveAll(filepath.Join(s.Root, id))
+        }
+        return res, err
+    }
+    return res, nil
+}
+
+// stageBundleAssets copies the regular files in the bundle's assets
+// directory into blob temp files, recording each in staged by name, and
+// returns their metadata sorted by name. A bundle without assets has none.
+func (s *Store) stageBundleAssets(dir, uploader string, staged map[string]string) ([]AssetMeta, error) {
+    entries, err := os.ReadDir(filepath.Join(dir, "assets"))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var res []AssetMeta
+    now := time.Now()
+    for _, e := range entries {
+        if !e.Type().IsRegular() || checkAssetName(e.Name()) != nil {
+            continue
+        }
+        f, err := os.Open(filepath.Join(dir, "assets", e.Name()))
+        if err != nil {
+            return nil, err
+        }
+        h := newAssetHasher()
+        tmp, err := s.createBlobTemp(func(w io.Writer) error {
+            _, err := io.Copy(io.MultiWriter(w, h), f)
+            return err
+        })
+        f.Close()
+        if err != nil {
+            return nil, err
+        }
+        staged[e.Name()] = tmp
+        res = append(res, h.meta(e.Name(), uploader, now))
+    }
+    sort.Slice(res, func(i, j int) bool { return res[i].Filename < res[j].Filename })
+    return res, nil
+}
+
+// commitImportAssets moves the staged blobs of save into the blob store and
+// records them over old, the project's asset index. Callers hold the
+// project lock.
+func (s *Store) commitImportAssets(id string, old map[string]AssetMeta, save []AssetMeta, staged map[string]string) error {
+    if len(save) == 0 {
+        return nil
+    }
+    defer s.lockBlobs()()
+    idx := map[string]AssetMeta{}
+    for name, m := range old {
+        idx[name] = m
+    }
+    delta := map[string]int{}
+    for _, m := range save {
+        if err := s.commitBlob(staged[m.Filename], m.SHA256); err != nil {
+            return err
+        }
+        delta[m.SHA256]++
+        if prev, ok := idx[m.Filename]; ok {
+            delta[prev.SHA256]--
+        }
+        idx[m.Filename] = m
+    }
+    if err := s.saveAssetIndex(id, idx); err != nil {
+        return err
+    }
+    return s.addRefs(delta)
+}
+
+// restoreAssetIndex puts back the asset index old after a failed import or clone,
+// releasing the blobs the import referenced. Callers hold the project lock.
+func (s *Store) restoreAssetIndex(id string, old map[string]AssetMeta) error {
+    defer s.lockBlobs()()
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return err
+    }
+    delta := map[string]int{}
+    for _, m := range idx {
+        delta[m.SHA256]--
+    }
+    for _, m := range old {
+        delta[m.SHA256]++
+    }
+    if err := s.saveAssetIndex(id, old); err != nil {
+        return err
+    }
+    return s.addRefs(delta)
+}
+
+// pinnedFiles maps the file names of the library packages r pins to their entries.
+func (s *Store) pinnedFiles(r recipe.Recipe) (map[string]LibraryEntry, error) {
+    entries, err := s.loadLibrary()
+    if err != nil {
+        return nil, err
+    }
+    files := map[string]LibraryEntry{}
+    for _, ref := range r.Library {
+        if i := findLibraryEntry(entries, ref.Name, ref.Version); i >= 0 {
+            files[entries[i].Filename] = entries[i]
+        }
+    }
+    return files, nil
+}
+
+// bundleAssets hashes the regular files in the bundle's assets directory,
+// sorted by name. A bundle without assets has none.
+func bundleAssets(dir string) ([]AssetMeta, error) {
+    entries, err := os.ReadDir(filepath.Join(dir, "assets"))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var res []AssetMeta
+    for _, e := range entries {
+        if !e.Type().IsRegular() || checkAssetName(e.Name()) != nil {
+            continue
+        }
+        m, err := scanAsset(filepath.Join(dir, "assets", e.Name()), e.Name())
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, m)
+    }
+    sort.Slice(res, func(i, j int) bool { return res[i].Filename < res[j].Filename })
+    return res, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// writeBundleDir lays out a bundle directory with recipe.json and assets.
+func writeBundleDir(t *testing.T, assets map[string]string) string {
+    t.Helper()
+    dir := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(dir, "assets"), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "recipe.json"), []byte(bundleRecipe), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range assets {
+        if err := os.WriteFile(filepath.Join(dir, "assets", name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+// blobTemps lists the staged blobs left in the blob store.
+func blobTemps(t *testing.T, s *Store) []string {
+    t.Helper()
+    if _, err := os.Stat(s.blobDir()); os.IsNotExist(err) {
+        return nil
+    }
+    return tempFiles(t, s.blobDir())
+}
+
+func TestImportBundleNew(t *testing.T) {
+    s := newTestStore(t)
+    res, err := s.ImportBundle(writeBundleDir(t, map[string]string{"a.txt": "alpha"}), ImportOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !res.Created || res.Recipe.Project.ID == "old" || res.Recipe.Revision != 1 {
+        t.Errorf("result = %+v", res)
+    }
+    if data, err := s.ReadAsset(res.Recipe.Project.ID, "a.txt"); err != nil || string(data) != "alpha" {
+        t.Errorf("asset = %q, %v", data, err)
+    }
+    if n := refcount(t, s, "alpha"); n != 1 {
+        t.Errorf("refcount = %d, want 1", n)
+    }
+    if tmp := blobTemps(t, s); len(tmp) > 0 {
+        t.Errorf("staged blobs left: %v", tmp)
+    }
+}
+
+func TestImportBundleInto(t *testing.T) {
+    tests := []struct {
+        name       string
+        quota      int64
+        base       int // BaseRevision relative to the current one
+        onConflict string
+        err        error
+        assets     map[string]string // the project's assets afterwards
+    }{
+        {"replace", 0, 0, "replace", nil, map[string]string{"a.txt": "new", "b.txt": "beta"}},
+        {"keep", 0, 0, "keep", nil, map[string]string{"a.txt": "old", "b.txt": "beta"}},
+        {"conflict", 0, 0, "", ErrImportConflict, map[string]string{"a.txt": "old"}},
+        {"stale base", 0, -1, "replace", &ConflictError{}, map[string]string{"a.txt": "old"}},
+        {"over quota", 6, 0, "replace", ErrQuotaExceeded, map[string]string{"a.txt": "old"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestStore(t)
+            r := newTestProject(t, s, map[string]string{"a.txt": "old"})
+            id := r.Project.ID
+            s.Quota = tt.quota
+            base := r.Revision + tt.base
+            res, err := s.ImportBundle(writeBundleDir(t, map[string]string{"a.txt": "new", "b.txt": "beta"}), ImportOptions{Project: id, OnConflict: tt.onConflict, Commit: Commit{BaseRevision: &base}})
+            var conflict *ConflictError
+            switch {
+            case tt.err == nil && err != nil:
+                t.Fatal(err)
+            case errors.As(tt.err, &conflict):
+                if !errors.As(err, &conflict) {
+                    t.Fatalf("err = %v, want a *ConflictError", err)
+                }
+            case tt.err != nil && !errors.Is(err, tt.err):
+                t.Fatalf("err = %v, want %v", err, tt.err)
+            }
+            cur, lerr := s.LoadRecipe(id)
+            if lerr != nil {
+                t.Fatal(lerr)
+            }
+            wantRev := r.Revision
+            if tt.err == nil {
+                wantRev++
+                if res.Recipe.Project.ID != id {
+                    t.Errorf("imported as %s, want %s", res.Recipe.Project.ID, id)
+                }
+            }
+            if cur.Revision != wantRev {
+                t.Errorf("revision = %d, want %d", cur.Revision, wantRev)
+            }
+            got := map[string]string{}
+            names, _ := s.AssetNames(id)
+            for _, n := range names {
+                data, _ := s.ReadAsset(id, n)
+                got[n] = string(data)
+            }
+            if !reflect.DeepEqual(got, tt.assets) {
+                t.Errorf("assets = %v, want %v", got, tt.assets)
+            }
+            rep, gerr := s.GC(true)
+            if gerr != nil {
+                t.Fatal(gerr)
+            }
+            if rep.Corrected != 0 || len(rep.Removed) != 0 {
+                t.Errorf("refcounts off after import: %+v", rep)
+            }
+            if tmp := blobTemps(t, s); len(tmp) > 0 {
+                t.Errorf("staged blobs left: %v", tmp)
+            }
+        })
+    }
+}
+
+func TestImportBundleMissingProject(t *testing.T) {
+    s := newTestStore(t)
+    dir := writeBundleDir(t, nil)
+    for _, id := range []string{"nope", "../escape"} {
+        if _, err := s.ImportBundle(dir, ImportOptions{Project: id}); err == nil {
+            t.Errorf("%s: import succeeded", id)
+        }
+        if _, err := os.Stat(filepath.Join(s.Root, id)); !os.IsNotExist(err) {
+            t.Errorf("%s: directory created: %v", id, err)
+        }
+    }
+}
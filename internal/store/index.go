@@ -0,0 +1,316 @@
+package store
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// ErrInvalidQuery is returned for project list queries with an unknown sort
+// or a malformed cursor.
+var ErrInvalidQuery = errors.New("invalid project query")
+
+// ProjectSummary is a project as listed: its metadata plus the current
+// revision, when it was saved and how many steps it has. Error is set for
+// projects whose recipe cannot be loaded, such as one of a newer schema;
+// they carry what metadata could still be read.
+type ProjectSummary struct {
+    recipe.ProjectMeta
+    Revision  int       `json:"revision"`
+    UpdatedAt time.Time `json:"updatedAt"`
+    Steps     int       `json:"steps"`
+    Error     string    `json:"error,omitempty"`
+}
+
+// indexEntry is a summary in projects.json with the size and mtime of the
+// recipe.json it was read from, so listing only re-reads recipes that
+// changed behind the index's back.
+type indexEntry struct {
+    ProjectSummary
+    ModTime time.Time `json:"modTime"`
+    Size    int64     `json:"size"`
+}
+
+// ProjectQuery selects a page of projects. Q matches every word against
+// the ID, name and description, case-insensitively; Target keeps projects
+// for that target. Sort is "updated" (newest first, the default) or "name".
+// Cursor is the NextCursor of the previous page.
+type ProjectQuery struct {
+    Q        string
+    Target   string
+    Archived bool
+    Sort     string
+    Limit    int
+    Cursor   string
+}
+
+// ProjectPage is one page of a project listing. Total counts every match.
+type ProjectPage struct {
+    Projects   []ProjectSummary `json:"projects"`
+    Total      int              `json:"total"`
+    NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// projectCursor is the position after the last project of a page.
+type projectCursor struct {
+    Sort    string `json:"s"`
+    Name    string `json:"n,omitempty"`
+    Updated int64  `json:"u,omitempty"`
+    ID      string `json:"id"`
+}
+
+// indexPath is projects.json, the project index next to the project directories.
+func (s *Store) indexPath() string {
+    return filepath.Join(filepath.Dir(s.Root), "projects.json")
+}
+
+func (s *Store) loadIndex() map[string]indexEntry {
+    idx := map[string]indexEntry{}
+    data, err := os.ReadFile(s.indexPath())
+    if err != nil {
+        return idx
+    }
+    // an unreadable index is rebuilt from the recipes
+    if json.Unmarshal(data, &idx) != nil {
+        return map[string]indexEntry{}
+    }
+    return idx
+}
+
+func (s *Store) saveIndex(idx map[string]indexEntry) error {
+    data, err := json.MarshalIndent(idx, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.indexPath()), 0o755); err != nil {
+        return err
+    }
+    return writeFileAtomic(s.indexPath(), data, 0o644)
+}
+
+func newIndexEntry(id string, r recipe.Recipe, info os.FileInfo) indexEntry {
+    meta := r.Project
+    meta.ID = id
+    return indexEntry{
+        ProjectSummary: ProjectSummary{ProjectMeta: meta, Revision: r.Revision, UpdatedAt: r.UpdatedAt, Steps: len(r.Steps)},
+        ModTime:        info.ModTime(),
+        Size:           info.Size(),
+    }
+}
+
+// brokenIndexEntry describes a project whose recipe fails to load with err.
+func brokenIndexEntry(id string, data []byte, err error, info os.FileInfo) indexEntry {
+    // best effort: fields of an unexpected shape are left empty
+    var doc struct {
+        Project recipe.ProjectMeta `json:"project"`
+    }
+    json.Unmarshal(data, &doc)
+    doc.Project.ID = id
+    return indexEntry{
+        ProjectSummary: ProjectSummary{ProjectMeta: doc.Project, UpdatedAt: info.ModTime(), Error: err.Error()},
+        ModTime:        info.ModTime(),
+        Size:           info.Size(),
+    }
+}
+
+// indexProject records the just-saved recipe of project id in the index.
+// The index is only a cache: when it cannot be written, listing repairs it.
+func (s *Store) indexProject(id string, r recipe.Recipe) {
+    info, err := os.Stat(s.recipePath(id))
+    if err != nil {
+        return
+    }
+    defer lockPath(s.indexPath())()
+    idx := s.loadIndex()
+    idx[id] = newIndexEntry(id, r, info)
+    s.saveIndex(idx)
+}
+
+// unindexProject drops project id from the index.
+func (s *Store) unindexProject(id string) {
+    defer lockPath(s.indexPath())()
+    idx := s.loadIndex()
+    if _, ok := idx[id]; ok {
+        delete(idx, id)
+        s.saveIndex(idx)
+    }
+}
+
+// projectIndex returns the index, brought up to date with the project
+// directories: recipes whose size or mtime differ from the index, that it
+// lacks or that failed to load before are read again, and projects that
+// are gone are dropped.
+func (s *Store) projectIndex() (map[string]indexEntry, error) {
+    defer lockPath(s.indexPath())()
+    idx := s.loadIndex()
+    entries, err := os.ReadDir(s.Root)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    changed := false
+    seen := map[string]bool{}
+    for _, e := range entries {
+        if !e.IsDir() {
+            continue
+        }
+        id := e.Name()
+        info, err := os.Stat(s.recipePath(id))
+        if err != nil {
+            continue
+        }
+        seen[id] = true
+        cur, ok := idx[id]
+        if ok && cur.Error == "" && cur.ModTime.Equal(info.ModTime()) && cur.Size == info.Size() {
+            continue
+        }
+        data, err := os.ReadFile(s.recipePath(id))
+        if err != nil {
+            continue
+        }
+        r, _, err := recipe.Migrate(data)
+        if err != nil {
+            e := brokenIndexEntry(id, data, err, info)
+            if ok && cur.Error == e.Error && cur.ModTime.Equal(e.ModTime) && cur.Size == e.Size {
+                continue
+            }
+            idx[id] = e
+        } else {
+            idx[id] = newIndexEntry(id, r, info)
+        }
+        changed = true
+    }
+    for id := range idx {
+        if !seen[id] {
+            delete(idx, id)
+            changed = true
+        }
+    }
+    if changed {
+        if err := s.saveIndex(idx); err != nil {
+            return nil, err
+        }
+    }
+    return idx, nil
+}
+
+// projectLess orders projects for sort, with the ID breaking ties.
+func projectLess(sortBy string, a, b ProjectSummary) bool {
+    switch sortBy {
+    case "name":
+        an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+        if an != bn {
+            return an < bn
+        }
+    default:
+        if !a.UpdatedAt.Equal(b.UpdatedAt) {
+            return a.UpdatedAt.After(b.UpdatedAt)
+        }
+    }
+    return a.ID < b.ID
+}
+
+// matchProject reports whether p passes the query's filters.
+func matchProject(q ProjectQuery, p ProjectSummary) bool {
+    if p.Archived && !q.Archived {
+        return false
+    }
+    if q.Target != "" {
+        found := false
+        for _, t := range p.Target {
+            if t == q.Target {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    text := strings.ToLower(p.ID + "\n" + p.Name + "\n" + p.Description)
+    for _, word := range strings.Fields(strings.ToLower(q.Q)) {
+        if !strings.Contains(text, word) {
+            return false
+        }
+    }
+    return true
+}
+
+func encodeCursor(c projectCursor) string {
+    data, _ := json.Marshal(c)
+    return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (projectCursor, error) {
+    var c projectCursor
+    data, err := base64.RawURLEncoding.DecodeString(s)
+    if err == nil {
+        err = json.Unmarshal(data, &c)
+    }
+    if err != nil || c.ID == "" {
+        return c, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    return c, nil
+}
+
+// ListProjects returns a page of the projects matching q, from the project
+// index. A data root that does not exist yet has no projects.
+func (s *Store) ListProjects(q ProjectQuery) (ProjectPage, error) {
+    if q.Sort == "" {
+        q.Sort = "updated"
+    }
+    if q.Sort != "updated" && q.Sort != "name" {
+        return ProjectPage{}, fmt.Errorf("%w: sort must be updated or name", ErrInvalidQuery)
+    }
+    var after *ProjectSummary
+    if q.Cursor != "" {
+        c, err := decodeCursor(q.Cursor)
+        if err != nil {
+            return ProjectPage{}, err
+        }
+        if c.Sort != q.Sort {
+            return ProjectPage{}, fmt.Errorf("%w: cursor was made for sort %s", ErrInvalidQuery, c.Sort)
+        }
+        after = &ProjectSummary{ProjectMeta: recipe.ProjectMeta{ID: c.ID, Name: c.Name}, UpdatedAt: time.Unix(0, c.Updated)}
+    }
+    idx, err := s.projectIndex()
+    if err != nil {
+        return ProjectPage{}, err
+    }
+    var matches []ProjectSummary
+    for _, e := range idx {
+        if matchProject(q, e.ProjectSummary) {
+            matches = append(matches, e.ProjectSummary)
+        }
+    }
+    sort.Slice(matches, func(i, j int) bool { return projectLess(q.Sort, matches[i], matches[j]) })
+
+    page := ProjectPage{Projects: []ProjectSummary{}, Total: len(matches)}
+    start := 0
+    if after != nil {
+        start = sort.Search(len(matches), func(i int) bool { return projectLess(q.Sort, *after, matches[i]) })
+    }
+    end := len(matches)
+    if q.Limit > 0 && start+q.Limit < end {
+        end = start + q.Limit
+        last := matches[end-1]
+        c := projectCursor{Sort: q.Sort, ID: last.ID}
+        if q.Sort == "name" {
+            c.Name = last.Name
+        } else {
+            c.Updated = last.UpdatedAt.UnixNano()
+        }
+        page.NextCursor = encodeCursor(c)
+    }
+    if start < end {
+        page.Projects = append(page.Projects, matches[start:end]...)
+    }
+    return page, nil
+}
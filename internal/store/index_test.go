@@ -0,0 +1,222 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+
+    "installforge/internal/recipe"
+)
+
+// createProjects creates projects with the given names, in order.
+func createProjects(t *testing.T, s *Store, names ...string) []recipe.Recipe {
+    t.Helper()
+    var res []recipe.Recipe
+    for _, n := range names {
+        r, err := s.CreateProject(n, "", []string{"oracle_linux_6_9"})
+        if err != nil {
+            t.Fatal(err)
+        }
+        res = append(res, r)
+    }
+    return res
+}
+
+// listAll pages through the projects matching q and returns their IDs.
+func listAll(t *testing.T, s *Store, q ProjectQuery, between func(page int)) []string {
+    t.Helper()
+    var ids []string
+    for page := 0; ; page++ {
+        p, err := s.ListProjects(q)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if q.Limit > 0 && len(p.Projects) > q.Limit {
+            t.Fatalf("page of %d projects, limit %d", len(p.Projects), q.Limit)
+        }
+        for _, e := range p.Projects {
+            ids = append(ids, e.ID)
+        }
+        if p.NextCursor == "" {
+            return ids
+        }
+        if between != nil {
+            between(page)
+        }
+        q.Cursor = p.NextCursor
+    }
+}
+
+func TestListProjectsPaging(t *testing.T) {
+    s := newTestStore(t)
+    names := []string{"delta", "Alpha", "echo", "bravo", "golf", "charlie", "foxtrot"}
+    projects := createProjects(t, s, names...)
+    byName := map[string]string{}
+    for _, r := range projects {
+        byName[r.Project.Name] = r.Project.ID
+    }
+    var wantName, wantUpdated []string
+    for _, n := range []string{"Alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf"} {
+        wantName = append(wantName, byName[n])
+    }
+    for i := len(projects) - 1; i >= 0; i-- {
+        wantUpdated = append(wantUpdated, projects[i].Project.ID)
+    }
+    for _, limit := range []int{0, 1, 3, 7, 10} {
+        if got := listAll(t, s, ProjectQuery{Sort: "name", Limit: limit}, nil); !reflect.DeepEqual(got, wantName) {
+            t.Errorf("by name, limit %d: %v, want %v", limit, got, wantName)
+        }
+        if got := listAll(t, s, ProjectQuery{Limit: limit}, nil); !reflect.DeepEqual(got, wantUpdated) {
+            t.Errorf("by update, limit %d: %v, want %v", limit, got, wantUpdated)
+        }
+    }
+}
+
+func TestListProjectsSaveWhilePaging(t *testing.T) {
+    // a project saved between pages jumps to the front; the others are
+    // still listed once each
+    s := newTestStore(t)
+    projects := createProjects(t, s, "a", "b", "c", "d", "e", "f")
+    moved := projects[1]
+    got := listAll(t, s, ProjectQuery{Limit: 2}, func(page int) {
+        if page == 0 {
+            if _, err := s.SaveRecipe(moved, Commit{}); err != nil {
+                t.Fatal(err)
+            }
+        }
+    })
+    seen := map[string]int{}
+    for _, id := range got {
+        seen[id]++
+    }
+    for _, r := range projects {
+        if r.Project.ID != moved.Project.ID && seen[r.Project.ID] != 1 {
+            t.Errorf("%s listed %d times: %v", r.Project.Name, seen[r.Project.ID], got)
+        }
+    }
+}
+
+func TestListProjectsFilters(t *testing.T) {
+    s := newTestStore(t)
+    web, err := s.CreateProject("Web Portal", "nginx front end", []string{"oracle_linux_6_9"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    db, err := s.CreateProject("Database", "postgres for the portal", []string{"kylinsec_3_4"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    old, err := s.CreateProject("Old portal", "", []string{"oracle_linux_6_9"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    old.Project.Archived = true
+    if _, err := s.SaveRecipe(old, Commit{}); err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name string
+        q    ProjectQuery
+        want []string
+    }{
+        {"all", ProjectQuery{Sort: "name"}, []string{db.Project.ID, web.Project.ID}},
+        {"archived", ProjectQuery{Sort: "name", Archived: true}, []string{db.Project.ID, old.Project.ID, web.Project.ID}},
+        {"word", ProjectQuery{Sort: "name", Q: "PORTAL"}, []string{db.Project.ID, web.Project.ID}},
+        {"words", ProjectQuery{Sort: "name", Q: "portal nginx"}, []string{web.Project.ID}},
+        {"id", ProjectQuery{Q: db.Project.ID}, []string{db.Project.ID}},
+        {"target", ProjectQuery{Target: "kylinsec_3_4"}, []string{db.Project.ID}},
+        {"no match", ProjectQuery{Q: "redis"}, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            p, err := s.ListProjects(tt.q)
+            if err != nil {
+                t.Fatal(err)
+            }
+            var got []string
+            for _, e := range p.Projects {
+                got = append(got, e.ID)
+            }
+            if !reflect.DeepEqual(got, tt.want) || p.Total != len(tt.want) {
+                t.Errorf("got %v (total %d), want %v", got, p.Total, tt.want)
+            }
+        })
+    }
+}
+
+func TestListProjectsInvalid(t *testing.T) {
+    s := newTestStore(t)
+    createProjects(t, s, "a", "b")
+    p, err := s.ListProjects(ProjectQuery{Sort: "name", Limit: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, q := range []ProjectQuery{
+        {Sort: "size"},
+        {Cursor: "not a cursor"},
+        {Cursor: encodeCursor(projectCursor{Sort: "name"})},
+        {Cursor: p.NextCursor},
+    } {
+        if _, err := s.ListProjects(q); !errors.Is(err, ErrInvalidQuery) {
+            t.Errorf("%+v: err = %v, want ErrInvalidQuery", q, err)
+        }
+    }
+}
+
+func TestProjectIndexRefresh(t *testing.T) {
+    s := newTestStore(t)
+    if p, err := s.ListProjects(ProjectQuery{}); err != nil || len(p.Projects) != 0 {
+        t.Fatalf("missing data root: %+v, %v", p, err)
+    }
+    projects := createProjects(t, s, "a", "b", "c")
+    list := func() map[string]ProjectSummary {
+        t.Helper()
+        p, err := s.ListProjects(ProjectQuery{})
+        if err != nil {
+            t.Fatal(err)
+        }
+        res := map[string]ProjectSummary{}
+        for _, e := range p.Projects {
+            res[e.ID] = e
+        }
+        return res
+    }
+
+    // edited by hand behind the index's back
+    edited := projects[0]
+    edited.Project.Name = "renamed by hand"
+    data, _ := json.Marshal(edited)
+    if err := os.WriteFile(s.recipePath(edited.Project.ID), data, 0o644); err != nil {
+        t.Fatal(err)
+    }
+    // removed by hand
+    if err := os.RemoveAll(filepath.Join(s.Root, projects[1].Project.ID)); err != nil {
+        t.Fatal(err)
+    }
+    // a recipe of a newer schema
+    if err := os.WriteFile(s.recipePath(projects[2].Project.ID), []byte(fmt.Sprintf(`{"schema_version":"9.0","project":{"id":%q,"name":"future"}}`, projects[2].Project.ID)), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    got := list()
+    if len(got) != 2 {
+        t.Fatalf("listed %v", got)
+    }
+    if got[edited.Project.ID].Name != "renamed by hand" {
+        t.Errorf("hand edit not picked up: %+v", got[edited.Project.ID])
+    }
+    if e := got[projects[2].Project.ID]; e.Error == "" || e.Name != "future" {
+        t.Errorf("broken recipe listed as %+v", e)
+    }
+
+    // a corrupt index is rebuilt
+    if err := os.WriteFile(s.indexPath(), []byte("{"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if again := list(); !reflect.DeepEqual(again, got) {
+        t.Errorf("after rebuild %v, want %v", again, got)
+    }
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+
+    "installforge/internal/inspect"
+    "installforge/internal/recipe"
+)
+
+// inspectPath caches the inspection of a blob. Blobs never change, so the
+// cache is only dropped when the blob goes.
+func (s *Store) inspectPath(sum string) string {
+    return filepath.Join(s.Blobs, "inspect", sum+".json")
+}
+
+// Inspect lists the archive or reads the RPM header stored under sum,
+// treating it as a file called name.
+func (s *Store) Inspect(sum, name string) (inspect.Result, error) {
+    kind := inspect.Kind(name)
+    if kind == "" {
+        return inspect.Result{}, inspect.ErrUnsupported
+    }
+    var res inspect.Result
+    if data, err := os.ReadFile(s.inspectPath(sum)); err == nil && json.Unmarshal(data, &res) == nil && res.Kind == kind && res.Format == inspect.Format {
+        return res, nil
+    }
+    f, err := s.OpenBlob(sum)
+    if err != nil {
+        return inspect.Result{}, err
+    }
+    defer f.Close()
+    info, err := f.Stat()
+    if err != nil {
+        return inspect.Result{}, err
+    }
+    if res, err = inspect.Inspect(name, f, info.Size()); err != nil {
+        return inspect.Result{}, err
+    }
+    if data, err := json.Marshal(res); err == nil {
+        if os.MkdirAll(filepath.Dir(s.inspectPath(sum)), 0o755) == nil {
+            writeFileAtomic(s.inspectPath(sum), data, 0o644)
+        }
+    }
+    return res, nil
+}
+
+// InspectAsset inspects one of the project's assets.
+func (s *Store) InspectAsset(id, name string) (inspect.Result, error) {
+    m, err := s.AssetMeta(id, name)
+    if err != nil {
+        return inspect.Result{}, err
+    }
+    return s.Inspect(m.SHA256, name)
+}
+
+// assetSum finds the blob of an asset name as a project sees it: its own
+// assets first, then the library files it pins. Library files it does not
+// pin are not its assets, even under the same name.
+func (s *Store) assetSum(id string, pinned []LibraryEntry, name string) string {
+    if idx, err := s.loadAssetIndex(id); err == nil {
+        if m, ok := idx[name]; ok {
+            return m.SHA256
+        }
+    }
+    for _, e := range pinned {
+        if e.Filename == name {
+            return e.SHA256
+        }
+    }
+    return ""
+}
+
+// pinnedEntries resolves pins against the library; pins that do not
+// resolve are left to validation.
+func pinnedEntries(entries []LibraryEntry, pins []recipe.LibraryRef) []LibraryEntry {
+    var res []LibraryEntry
+    for _, ref := range pins {
+        if i := findLibraryEntry(entries, ref.Name, ref.Version); i >= 0 {
+            res = append(res, entries[i])
+        }
+    }
+    return res
+}
+
+// archiveLookup returns the recipe.Options.Archive of a project.
+func (s *Store) archiveLookup(id string, pinned []LibraryEntry) func(name string) ([]string, bool) {
+    return func(name string) ([]string, bool) {
+        if inspect.Kind(name) != "tar.gz" && inspect.Kind(name) != "zip" {
+            return nil, false
+        }
+        sum := s.assetSum(id, pinned, name)
+        if sum == "" {
+            return nil, false
+        }
+        res, err := s.Inspect(sum, name)
+        if err != nil || res.Truncated {
+            return nil, false
+        }
+        return res.Paths(), true
+    }
+}
+
+// rpmLookup returns the recipe.Options.RPM of a project.
+func (s *Store) rpmLookup(id string, pinned []LibraryEntry) func(name string) (inspect.RPMInfo, bool) {
+    return func(name string) (inspect.RPMInfo, bool) {
+        if inspect.Kind(name) != "rpm" {
+            return inspect.RPMInfo{}, false
+        }
+        sum := s.assetSum(id, pinned, name)
+        if sum == "" {
+            return inspect.RPMInfo{}, false
+        }
+        res, err := s.Inspect(sum, name)
+        if err != nil || res.RPM == nil {
+            return inspect.RPMInfo{}, false
+        }
+        return *res.RPM, true
+    }
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// ErrLibraryExists is returned when a library package version is added twice;
+// published versions are immutable.
+var ErrLibraryExists = errors.New("library package version already exists")
+
+var libraryNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// LibraryEntry is a package of the shared library. Its content lives in the
+// blob store like project assets.
+type LibraryEntry struct {
+    recipe.LibraryItem
+    Description string    `json:"description,omitempty"`
+    Size        int64     `json:"size"`
+    SHA256      string    `json:"sha256"`
+    MIMEType    string    `json:"mimeType"`
+    UploadedAt  time.Time `json:"uploadedAt"`
+    Uploader    string    `json:"uploader"`
+}
+
+func (s *Store) libraryIndexPath() string {
+    return filepath.Join(s.Library, "library.json")
+}
+
+func (s *Store) loadLibrary() ([]LibraryEntry, error) {
+    var entries []LibraryEntry
+    data, err := os.ReadFile(s.libraryIndexPath())
+    if os.IsNotExist(err) {
+        return []LibraryEntry{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, &entries); err != nil {
+        return nil, fmt.Errorf("library.json: %w", err)
+    }
+    return entries, nil
+}
+
+func (s *Store) saveLibrary(entries []LibraryEntry) error {
+    sort.Slice(entries, func(i, j int) bool {
+        if entries[i].Name != entries[j].Name {
+            return entries[i].Name < entries[j].Name
+        }
+        return recipe.CompareVersions(entries[i].Version, entries[j].Version) < 0
+    })
+    if err := os.MkdirAll(s.Library, 0o755); err != nil {
+        return err
+    }
+    data, err := json.MarshalIndent(entries, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(s.libraryIndexPath(), data, 0o644)
+}
+
+func findLibraryEntry(entries []LibraryEntry, name, version string) int {
+    for i, e := range entries {
+        if e.Name == name && e.Version == version {
+            return i
+        }
+    }
+    return -1
+}
+
+// LibraryEntries lists the library's packages by name, oldest version first.
+func (s *Store) LibraryEntries() ([]LibraryEntry, error) {
+    return s.loadLibrary()
+}
+
+// LibraryEntry returns one package version.
+func (s *Store) LibraryEntry(name, version string) (LibraryEntry, error) {
+    entries, err := s.loadLibrary()
+    if err != nil {
+        return LibraryEntry{}, err
+    }
+    i := findLibraryEntry(entries, name, version)
+    if i < 0 {
+        return LibraryEntry{}, os.ErrNotExist
+    }
+    return entries[i], nil
+}
+
+// AddLibraryEntry stores a new package version read from src.
+func (s *Store) AddLibraryEntry(name, version, filename, description, uploader string, src io.Reader) (LibraryEntry, error) {
+    if !libraryNameRe.MatchString(name) || !libraryNameRe.MatchString(version) {
+        return LibraryEntry{}, fmt.Errorf("invalid library name or version")
+    }
+    if err := checkAssetName(filename); err != nil {
+        return LibraryEntry{}, err
+    }
+    if _, err := s.LibraryEntry(name, version); err == nil {
+        return LibraryEntry{}, ErrLibraryExists
+    }
+    h := newAssetHasher()
+    tmp, err := s.createBlobTemp(func(w io.Writer) error {
+        _, err := io.Copy(io.MultiWriter(w, h), src)
+        return err
+    })
+    if err != nil {
+        return LibraryEntry{}, err
+    }
+    meta := h.meta(filename, uploader, time.Now())
+    e := LibraryEntry{
+        LibraryItem: recipe.LibraryItem{Name: name, Version: version, Filename: filename},
+        Description: description,
+        Size:        meta.Size,
+        SHA256:      meta.SHA256,
+        MIMEType:    meta.MIMEType,
+        UploadedAt:  meta.UploadedAt,
+        Uploader:    uploader,
+    }
+
+    defer lockPath(s.Library)()
+    defer s.lockBlobs()()
+    entries, err := s.loadLibrary()
+    if err != nil {
+        os.Remove(tmp)
+        return LibraryEntry{}, err
+    }
+    if findLibraryEntry(entries, name, version) >= 0 {
+        os.Remove(tmp)
+        return LibraryEntry{}, ErrLibraryExists
+    }
+    if err := s.commitBlob(tmp, e.SHA256); err != nil {
+        return LibraryEntry{}, err
+    }
+    if err := s.saveLibrary(append(entries, e)); err != nil {
+        return LibraryEntry{}, err
+    }
+    return e, s.addRefs(map[string]int{e.SHA256: 1})
+}
+
+// UpdateLibraryEntry changes a package's description or deprecation; its
+// content and file name stay fixed.
+func (s *Store) UpdateLibraryEntry(name, version string, edit func(e *LibraryEntry)) (LibraryEntry, error) {
+    defer lockPath(s.Library)()
+    entries, err := s.loadLibrary()
+    if err != nil {
+        return LibraryEntry{}, err
+    }
+    i := findLibraryEntry(entries, name, version)
+    if i < 0 {
+        return LibraryEntry{}, os.ErrNotExist
+    }
+    e := entries[i]
+    edit(&e)
+    e.Name, e.Version, e.Filename = name, version, entries[i].Filename
+    if !e.Deprecated {
+        e.DeprecationNote = ""
+    }
+    entries[i] = e
+    return e, s.saveLibrary(entries)
+}
+
+// LibraryInUseError is returned when deleting a package version that
+// projects still pin; Users lists them, trashed ones as "trash/<id>".
+type LibraryInUseError struct {
+    Name    string
+    Version string
+    Users   []string
+}
+
+func (e *LibraryInUseError) Error() string {
+    return fmt.Sprintf("%s %s is pinned by projects", e.Name, e.Version)
+}
+
+// DeleteLibraryEntry removes a package version from the library. Unless
+// force is set, a version still pinned by a project, including one in the
+// trash, is kept and a *LibraryInUseError returned; the check runs under
+// the library lock so it cannot race another delete.
+func (s *Store) DeleteLibraryEntry(name, version string, force bool) error {
+    defer lockPath(s.Library)()
+    entries, err := s.loadLibrary()
+    if err != nil {
+        return err
+    }
+    i := findLibraryEntry(entries, name, version)
+    if i < 0 {
+        return os.ErrNotExist
+    }
+    if !force {
+        users, err := s.LibraryUsers(name, version)
+        if err != nil {
+            return err
+        }
+        if len(users) > 0 {
+            return &LibraryInUseError{Name: name, Version: version, Users: users}
+        }
+    }
+    defer s.lockBlobs()()
+    sum := entries[i].SHA256
+    if err := s.saveLibrary(append(entries[:i], entries[i+1:]...)); err != nil {
+        return err
+    }
+    return s.addRefs(map[string]int{sum: -1})
+}
+
+// LibraryUsers lists the projects whose recipe pins a package version.
+// Projects in the trash count too, since restoring them brings the pin
+// back; they are listed as "trash/<id>".
+func (s *Store) LibraryUsers(name, version string) ([]string, error) {
+    users := []string{}
+    for _, dir := range []struct{ root, prefix string }{{s.Root, ""}, {s.Trash, "trash/"}} {
+        dirs, err := os.ReadDir(dir.root)
+        if os.IsNotExist(err) {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        for _, d := range dirs {
+            if !d.IsDir() {
+                continue
+            }
+            data, err := os.ReadFile(filepath.Join(dir.root, d.Name(), "recipe.json"))
+            if err != nil {
+                continue
+            }
+            r, _, err := recipe.Migrate(data)
+            if err != nil {
+                continue
+            }
+            for _, ref := range r.Library {
+                if ref.Name == name && ref.Version == version {
+                    users = append(users, dir.prefix+d.Name())
+                    break
+                }
+            }
+        }
+    }
+    return users, nil
+}
+
+// LibraryAssets resolves the recipe's library pins to asset metadata;
+// pins that do not resolve are left to validation.
+func (s *Store) LibraryAssets(r recipe.Recipe) ([]AssetMeta, error) {
+    res := []AssetMeta{}
+    if len(r.Library) == 0 {
+        return res, nil
+    }
+    entries, err := s.loadLibrary()
+    if err != nil {
+        return nil, err
+    }
+    for _, ref := range r.Library {
+        i := findLibraryEntry(entries, ref.Name, ref.Version)
+        if i < 0 {
+            continue
+        }
+        e := entries[i]
+        res = append(res, AssetMeta{
+            Filename:     e.Filename,
+            Size:         e.Size,
+            SHA256:       e.SHA256,
+            MIMEType:     e.MIMEType,
+            UploadedAt:   e.UploadedAt,
+            Uploader:     e.Uploader,
+            Library:      e.Name + "@" + e.Version,
+            ReferencedBy: recipe.AssetUsers(r, e.Filename),
+        })
+    }
+    return res, nil
+}
+
+// BundleAssets lists what goes into the recipe's bundle: the project's
+// assets, then the library files it pins. A project asset wins a name clash.
+func (s *Store) BundleAssets(r recipe.Recipe) ([]AssetMeta, error) {
+    metas, err := s.AssetMetas(r.Project.ID)
+    if err != nil {
+        return nil, err
+    }
+    lib, err := s.LibraryAssets(r)
+    if err != nil {
+        return nil, err
+    }
+    taken := map[string]bool{}
+    for _, m := range metas {
+        taken[m.Filename] = true
+    }
+    for _, m := range lib {
+        if !taken[m.Filename] {
+            taken[m.Filename] = true
+            metas = append(metas, m)
+        }
+    }
+    return metas, nil
+}
+
+// ReadBlob reads the content stored under sum, as listed by BundleAssets.
+func (s *Store) ReadBlob(sum string) ([]byte, error) {
+    return os.ReadFile(s.blobPath(sum))
+}
+
+// OpenBlob opens the content of an asset or library package for download.
+func (s *Store) OpenBlob(sum string) (*os.File, error) {
+    return os.Open(s.blobPath(sum))
+}
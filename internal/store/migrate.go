@@ -0,0 +1,50 @@
+package store
+
+import (
+    "fmt"
+    "os"
+
+    "installforge/internal/recipe"
+)
+
+// ProjectMigration is one project's entry in a data root migration run.
+type ProjectMigration struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
+    recipe.MigrationReport
+    Error string `json:"error,omitempty"`
+}
+
+// MigrateAll upgrades every project's recipe.json to the current schema,
+// saving each changed recipe as a new revision. With dryRun nothing is written.
+func (s *Store) MigrateAll(dryRun bool) ([]ProjectMigration, error) {
+    entries, err := os.ReadDir(s.Root)
+    if err != nil {
+        return nil, err
+    }
+    var res []ProjectMigration
+    for _, e := range entries {
+        if !e.IsDir() {
+            continue
+        }
+        if _, err := os.Stat(s.recipePath(e.Name())); err != nil {
+            continue
+        }
+        r, report, err := s.loadRecipe(e.Name())
+        pm := ProjectMigration{ID: e.Name(), Name: r.Project.Name, MigrationReport: report}
+        if err != nil {
+            pm.Error = err.Error()
+            res = append(res, pm)
+            continue
+        }
+        if report.Changed() && !dryRun {
+            r.Project.ID = e.Name()
+            msg := fmt.Sprintf("migrate schema %s to %s", report.From, report.To)
+            if _, err := s.SaveRecipe(r, Commit{Author: "installforge", Message: msg}); err != nil {
+                pm.Error = err.Error()
+            }
+        }
+        res = append(res, pm)
+    }
+    return res, nil
+}
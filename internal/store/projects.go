@@ -0,0 +1,237 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// ErrProjectExists is returned when a project would be moved onto an ID
+// that is already taken, in the project list or in the trash.
+var ErrProjectExists = errors.New("project already exists")
+
+// A deleted project keeps its directory under Trash, with trash.json added,
+// until it is restored or purged. Its assets keep their blob references so
+// a restore brings them back.
+
+// TrashEntry describes a project in the trash.
+type TrashEntry struct {
+    ID        string    `json:"id"`
+    Name      string    `json:"name"`
+    DeletedAt time.Time `json:"deletedAt"`
+}
+
+// projectDirName rejects IDs that are not a single path element; no such
+// project can exist.
+func projectDirName(id string) error {
+    if id == "" || id == "." || id == ".." || filepath.Base(id) != id {
+        return fmt.Errorf("invalid project id %q: %w", id, os.ErrNotExist)
+    }
+    return nil
+}
+
+func (s *Store) trashDir(id string) string {
+    return filepath.Join(s.Trash, id)
+}
+
+// TrashProject moves a project to the trash.
+func (s *Store) TrashProject(id string) (TrashEntry, error) {
+    if err := projectDirName(id); err != nil {
+        return TrashEntry{}, err
+    }
+    defer s.lock(id)()
+    dir := filepath.Join(s.Root, id)
+    if _, err := os.Stat(dir); err != nil {
+        return TrashEntry{}, err
+    }
+    // a project whose recipe no longer loads can still be deleted
+    r, _ := s.LoadRecipe(id)
+    dest := s.trashDir(id)
+    if _, err := os.Stat(dest); err == nil {
+        return TrashEntry{}, ErrProjectExists
+    }
+    if err := os.MkdirAll(s.Trash, 0o755); err != nil {
+        return TrashEntry{}, err
+    }
+    e := TrashEntry{ID: id, Name: r.Project.Name, DeletedAt: time.Now()}
+    data, err := json.MarshalIndent(e, "", "  ")
+    if err != nil {
+        return TrashEntry{}, err
+    }
+    if err := writeFileAtomic(filepath.Join(dir, "trash.json"), data, 0o644); err != nil {
+        return TrashEntry{}, err
+    }
+    if err := os.Rename(dir, dest); err != nil {
+        os.Remove(filepath.Join(dir, "trash.json"))
+        return TrashEntry{}, err
+    }
+    syncDir(s.Trash)
+    s.unindexProject(id)
+    return e, nil
+}
+
+// ListTrash lists the projects in the trash, most recently deleted first.
+func (s *Store) ListTrash() ([]TrashEntry, error) {
+    entries, err := os.ReadDir(s.Trash)
+    if os.IsNotExist(err) {
+        return []TrashEntry{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    res := []TrashEntry{}
+    for _, d := range entries {
+        if !d.IsDir() {
+            continue
+        }
+        e, err := s.trashEntry(d.Name())
+        if err != nil {
+            continue
+        }
+        res = append(res, e)
+    }
+    sort.Slice(res, func(i, j int) bool { return res[i].DeletedAt.After(res[j].DeletedAt) })
+    return res, nil
+}
+
+func (s *Store) trashEntry(id string) (TrashEntry, error) {
+    var e TrashEntry
+    data, err := os.ReadFile(filepath.Join(s.trashDir(id), "trash.json"))
+    if err != nil {
+        return e, err
+    }
+    if err := json.Unmarshal(data, &e); err != nil {
+        return e, fmt.Errorf("trash.json: %w", err)
+    }
+    return e, nil
+}
+
+// RestoreProject moves a project back from the trash.
+func (s *Store) RestoreProject(id string) (recipe.Recipe, error) {
+    if err := projectDirName(id); err != nil {
+        return recipe.Recipe{}, err
+    }
+    defer s.lock(id)()
+    src := s.trashDir(id)
+    if _, err := s.trashEntry(id); err != nil {
+        return recipe.Recipe{}, err
+    }
+    dest := filepath.Join(s.Root, id)
+    if _, err := os.Stat(dest); err == nil {
+        return recipe.Recipe{}, ErrProjectExists
+    }
+    if err := os.MkdirAll(s.Root, 0o755); err != nil {
+        return recipe.Recipe{}, err
+    }
+    if err := os.Rename(src, dest); err != nil {
+        return recipe.Recipe{}, err
+    }
+    syncDir(s.Root)
+    os.Remove(filepath.Join(dest, "trash.json"))
+    r, err := s.LoadRecipe(id)
+    if err != nil {
+        return recipe.Recipe{}, err
+    }
+    s.indexProject(id, r)
+    return r, nil
+}
+
+// PurgeProject removes a project from the trash for good, releasing its
+// asset blobs.
+func (s *Store) PurgeProject(id string) error {
+    if err := projectDirName(id); err != nil {
+        return err
+    }
+    defer s.lock(id)()
+    defer s.lockBlobs()()
+    if _, err := s.trashEntry(id); err != nil {
+        return err
+    }
+    dir := s.trashDir(id)
+    idx, err := readAssetIndex(filepath.Join(dir, "assets.json"))
+    if err != nil {
+        return err
+    }
+    if err := os.RemoveAll(dir); err != nil {
+        return err
+    }
+    delta := map[string]int{}
+    for _, m := range idx {
+        delta[m.SHA256]--
+    }
+    return s.addRefs(delta)
+}
+
+// CloneProject copies a project's current recipe and assets to a new
+// project named name, or "<name> (copy)" when name is empty. Assets share
+// their blobs; the clone starts its own revision history and is never archived.
+func (s *Store) CloneProject(id, name string) (recipe.Recipe, error) {
+    r, err := s.LoadRecipe(id)
+    if err != nil {
+        return recipe.Recipe{}, err
+    }
+    newID := randomID()
+    if err := s.cloneAssets(id, newID); err != nil {
+        return recipe.Recipe{}, err
+    }
+    if name == "" {
+        name = r.Project.Name + " (copy)"
+    }
+    r.Project.ID = newID
+    r.Project.Name = name
+    r.Project.Archived = false
+    rec, err := s.createRecipe(r, Commit{Message: fmt.Sprintf("clone of %s revision %d", id, r.Revision)})
+    if err != nil {
+        if rerr := s.removeClone(newID); rerr != nil {
+            err = fmt.Errorf("%v; removing the partial clone failed: %v", err, rerr)
+        }
+        return recipe.Recipe{}, err
+    }
+    return rec, nil
+}
+
+// cloneAssets copies the asset index of project id to newID and counts the
+// new references.
+func (s *Store) cloneAssets(id, newID string) error {
+    defer s.lock(id)()
+    defer s.lock(newID)()
+    defer s.lockBlobs()()
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return err
+    }
+    dir, err := s.EnsureProjectDir(newID)
+    if err != nil {
+        return err
+    }
+    delta := map[string]int{}
+    for _, m := range idx {
+        delta[m.SHA256]++
+    }
+    if err := s.saveAssetIndex(newID, idx); err != nil {
+        os.RemoveAll(dir)
+        return err
+    }
+    if err := s.addRefs(delta); err != nil {
+        os.RemoveAll(dir)
+        return err
+    }
+    return nil
+}
+
+// removeClone undoes cloneAssets for a clone whose recipe could not be
+// written: it releases the clone's blob references and removes its
+// directory, so the clone neither lingers nor keeps blobs from GC.
+func (s *Store) removeClone(newID string) error {
+    defer s.lock(newID)()
+    if err := s.restoreAssetIndex(newID, nil); err != nil {
+        return err
+    }
+    return os.RemoveAll(filepath.Join(s.Root, newID))
+}
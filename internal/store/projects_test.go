@@ -0,0 +1,55 @@
+package store
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestCloneProject(t *testing.T) {
+    s := newTestStore(t)
+    r := newTestProject(t, s, map[string]string{"a.txt": "alpha"})
+    clone, err := s.CloneProject(r.Project.ID, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if clone.Project.Name != "demo (copy)" || clone.Revision != 1 {
+        t.Errorf("clone = %+v", clone.Project)
+    }
+    if names, _ := s.AssetNames(clone.Project.ID); len(names) != 1 || names[0] != "a.txt" {
+        t.Errorf("clone assets = %v", names)
+    }
+    if n := refcount(t, s, "alpha"); n != 2 {
+        t.Errorf("refcount = %d, want 2", n)
+    }
+}
+
+func TestRemoveClone(t *testing.T) {
+    // a clone whose recipe could not be written gives back its references
+    // and leaves no directory behind
+    s := newTestStore(t)
+    r := newTestProject(t, s, map[string]string{"a.txt": "alpha"})
+    newID := randomID()
+    if err := s.cloneAssets(r.Project.ID, newID); err != nil {
+        t.Fatal(err)
+    }
+    if n := refcount(t, s, "alpha"); n != 2 {
+        t.Fatalf("refcount after cloneAssets = %d, want 2", n)
+    }
+    if err := s.removeClone(newID); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(s.Root, newID)); !os.IsNotExist(err) {
+        t.Errorf("clone directory left behind: %v", err)
+    }
+    if n := refcount(t, s, "alpha"); n != 1 {
+        t.Errorf("refcount = %d, want 1", n)
+    }
+    rep, err := s.GC(true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rep.Corrected != 0 || len(rep.Removed) != 0 {
+        t.Errorf("gc report = %+v", rep)
+    }
+}
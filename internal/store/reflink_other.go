@@ -0,0 +1,10 @@
+//go:build !linux
+
+package store
+
+import "errors"
+
+// reflink is only supported on Linux; elsewhere bundles hardlink or copy.
+func reflink(src, dest string) error {
+    return errors.New("reflink not supported")
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "installforge/internal/recipe"
+)
+
+// Commit describes who saved a recipe and why. When BaseRevision is set the
+// save only succeeds if it is still the latest revision.
+type Commit struct {
+    Author       string `json:"author"`
+    Message      string `json:"message"`
+    BaseRevision *int   `json:"-"`
+}
+
+// ConflictError reports a save based on a revision that is no longer the latest.
+type ConflictError struct {
+    Base    int
+    Current int
+}
+
+func (e *ConflictError) Error() string {
+    return fmt.Sprintf("recipe is at revision %d, not %d", e.Current, e.Base)
+}
+
+// Revision describes one saved version of a recipe.
+type Revision struct {
+    Number    int       `json:"number"`
+    CreatedAt time.Time `json:"createdAt"`
+    Author    string    `json:"author"`
+    Message   string    `json:"message"`
+}
+
+// revisionFile is the on-disk form of revisions/<number>.json.
+type revisionFile struct {
+    Revision Revision        `json:"revision"`
+    Recipe   json.RawMessage `json:"recipe"`
+}
+
+func (s *Store) revisionDir(id string) string {
+    return filepath.Join(s.Root, id, "revisions")
+}
+
+func (s *Store) revisionPath(id string, n int) string {
+    return filepath.Join(s.revisionDir(id), fmt.Sprintf("%06d.json", n))
+}
+
+// revisionNumbers returns the stored revision numbers in ascending order.
+func (s *Store) revisionNumbers(id string) ([]int, error) {
+    entries, err := os.ReadDir(s.revisionDir(id))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var nums []int
+    for _, e := range entries {
+        n, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+        if err != nil || e.IsDir() {
+            continue
+        }
+        nums = append(nums, n)
+    }
+    sort.Ints(nums)
+    return nums, nil
+}
+
+// writeRevision stores r as revision n.
+func (s *Store) writeRevision(id string, n int, r recipe.Recipe, c Commit, at time.Time) (Revision, error) {
+    if err := os.MkdirAll(s.revisionDir(id), 0o755); err != nil {
+        return Revision{}, err
+    }
+    rev := Revision{Number: n, CreatedAt: at, Author: c.Author, Message: c.Message}
+    raw, err := json.Marshal(r)
+    if err != nil {
+        return Revision{}, err
+    }
+    data, err := json.MarshalIndent(revisionFile{Revision: rev, Recipe: raw}, "", "  ")
+    if err != nil {
+        return Revision{}, err
+    }
+    if err := writeFileAtomic(s.revisionPath(id, n), data, 0o644); err != nil {
+        return Revision{}, err
+    }
+    return rev, nil
+}
+
+// ListRevisions lists saved revisions, newest first.
+func (s *Store) ListRevisions(id string) ([]Revision, error) {
+    nums, err := s.revisionNumbers(id)
+    if err != nil {
+        return nil, err
+    }
+    res := make([]Revision, 0, len(nums))
+    for i := len(nums) - 1; i >= 0; i-- {
+        rev, _, err := s.LoadRevision(id, nums[i])
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, rev)
+    }
+    return res, nil
+}
+
+// LoadRevision reads one saved revision, migrating its recipe to the current schema.
+func (s *Store) LoadRevision(id string, n int) (Revision, recipe.Recipe, error) {
+    data, err := os.ReadFile(s.revisionPath(id, n))
+    if err != nil {
+        return Revision{}, recipe.Recipe{}, err
+    }
+    var f revisionFile
+    if err := json.Unmarshal(data, &f); err != nil {
+        return Revision{}, recipe.Recipe{}, err
+    }
+    r, _, err := recipe.Migrate(f.Recipe)
+    if err != nil {
+        return Revision{}, recipe.Recipe{}, err
+    }
+    return f.Revision, r, nil
+}
+
+// RestoreRevision saves the recipe of revision n as a new revision.
+func (s *Store) RestoreRevision(id string, n int, c Commit) (recipe.Recipe, error) {
+    _, old, err := s.LoadRevision(id, n)
+    if err != nil {
+        return recipe.Recipe{}, err
+    }
+    if c.Message == "" {
+        c.Message = fmt.Sprintf("restore revision %d", n)
+    }
+    old.Project.ID = id
+    return s.SaveRecipe(old, c)
+}
@@ -6,85 +6,157 @@ import (
     "encoding/json"
     "fmt"
     "io"
+    "io/fs"
     "net/http"
     "os"
     "path/filepath"
-    "strings"
     "time"
 
     "installforge/internal/recipe"
 )
 
-// Store handles local file storage.
+// Store handles local file storage. Projects live under Root, deleted ones
+// under Trash, the shared package library under Library and asset content
+// under Blobs.
 type Store struct {
-    Root string
+    Root    string
+    Trash   string
+    Blobs   string
+    Library string
+    // Quota caps the bytes of assets and open uploads per project; 0 means no limit.
+    Quota int64
+    // UploadTTL is how long an upload session may sit idle before GC drops
+    // it and its reserved quota; 0 keeps sessions until they are finished.
+    UploadTTL time.Duration
+    // Templates holds the built-in templates as catalog/<id>.json.
+    Templates fs.FS
+    // Imports is the directory bundles imported by server-side path must
+    // lie in; empty disables importing by path.
+    Imports string
 }
 
-// New creates a new store rooted at path.
+// New creates a new store rooted at path, with the trash, blob store, library
+// and import directory next to it.
 func New(root string) *Store {
-    return &Store{Root: root}
+    data := filepath.Dir(root)
+    return &Store{Root: root, Trash: filepath.Join(data, "trash"), Blobs: filepath.Join(data, "blobs"), Library: filepath.Join(data, "library"), Imports: filepath.Join(data, "import")}
 }
 
 // EnsureProjectDir makes project directory.
 func (s *Store) EnsureProjectDir(id string) (string, error) {
     path := filepath.Join(s.Root, id)
-    if err := os.MkdirAll(filepath.Join(path, "assets"), 0o755); err != nil {
+    if err := os.MkdirAll(path, 0o755); err != nil {
         return "", err
     }
     return path, nil
 }
 
-// ListProjects lists existing projects.
-func (s *Store) ListProjects() ([]recipe.ProjectMeta, error) {
-    entries, err := os.ReadDir(s.Root)
-    if err != nil {
-        return nil, err
+func (s *Store) recipePath(id string) string {
+    return filepath.Join(s.Root, id, "recipe.json")
+}
+
+// projectExists fails with os.ErrNotExist unless project id has a recipe.
+// Callers hold the project lock when the answer has to stay true.
+func (s *Store) projectExists(id string) error {
+    if err := projectDirName(id); err != nil {
+        return err
     }
-    var res []recipe.ProjectMeta
-    for _, e := range entries {
-        if !e.IsDir() {
-            continue
+    if _, err := os.Stat(s.recipePath(id)); err != nil {
+        if os.IsNotExist(err) {
+            return fmt.Errorf("project %s: %w", id, os.ErrNotExist)
         }
-        recPath := filepath.Join(s.Root, e.Name(), "recipe.json")
-        data, err := os.ReadFile(recPath)
-        if err != nil {
-            continue
-        }
-        var r recipe.Recipe
-        if err := json.Unmarshal(data, &r); err != nil {
-            continue
-        }
-        res = append(res, r.Project)
+        return err
     }
-    return res, nil
+    return nil
 }
 
-// LoadRecipe reads recipe.
+// LoadRecipe reads recipe, migrating it to the current schema in memory.
 func (s *Store) LoadRecipe(id string) (recipe.Recipe, error) {
-    recPath := filepath.Join(s.Root, id, "recipe.json")
-    data, err := os.ReadFile(recPath)
+    r, _, err := s.loadRecipe(id)
+    return r, err
+}
+
+func (s *Store) loadRecipe(id string) (recipe.Recipe, recipe.MigrationReport, error) {
+    data, err := os.ReadFile(s.recipePath(id))
     if err != nil {
-        return recipe.Recipe{}, err
+        return recipe.Recipe{}, recipe.MigrationReport{}, err
     }
-    var r recipe.Recipe
-    if err := json.Unmarshal(data, &r); err != nil {
+    return recipe.Migrate(data)
+}
+
+// SaveRecipe writes recipe as a new revision of an existing project and
+// returns it as stored. Saves of the same project are serialized and every
+// file is replaced atomically. A project without a recipe, such as one in
+// the trash, fails with os.ErrNotExist; saves never create projects.
+func (s *Store) SaveRecipe(r recipe.Recipe, c Commit) (recipe.Recipe, error) {
+    return s.saveRecipe(r, c, false)
+}
+
+// createRecipe saves the first recipe of a new project, making its directory.
+func (s *Store) createRecipe(r recipe.Recipe, c Commit) (recipe.Recipe, error) {
+    return s.saveRecipe(r, c, true)
+}
+
+func (s *Store) saveRecipe(r recipe.Recipe, c Commit, create bool) (recipe.Recipe, error) {
+    if err := projectDirName(r.Project.ID); err != nil {
         return recipe.Recipe{}, err
     }
-    return r, nil
+    defer s.lock(r.Project.ID)()
+    return s.writeRecipe(r, c, create)
 }
 
-// SaveRecipe writes recipe.
-func (s *Store) SaveRecipe(r recipe.Recipe) error {
-    dir, err := s.EnsureProjectDir(r.Project.ID)
+// writeRecipe is saveRecipe for callers holding the project lock.
+func (s *Store) writeRecipe(r recipe.Recipe, c Commit, create bool) (recipe.Recipe, error) {
+    id := r.Project.ID
+    dir := filepath.Join(s.Root, id)
+    if create {
+        if _, err := s.EnsureProjectDir(id); err != nil {
+            return recipe.Recipe{}, err
+        }
+    } else if err := s.projectExists(id); err != nil {
+        return recipe.Recipe{}, err
+    }
+    nums, err := s.revisionNumbers(id)
     if err != nil {
-        return err
+        return recipe.Recipe{}, err
+    }
+    if c.BaseRevision != nil {
+        current := 0
+        if len(nums) > 0 {
+            current = nums[len(nums)-1]
+        }
+        if current != *c.BaseRevision {
+            return recipe.Recipe{}, &ConflictError{Base: *c.BaseRevision, Current: current}
+        }
     }
+    next := 1
+    if len(nums) > 0 {
+        next = nums[len(nums)-1] + 1
+    } else if prev, err := s.LoadRecipe(id); err == nil {
+        // keep recipes saved before history existed as the first revision
+        prev.Revision = 1
+        if _, err := s.writeRevision(id, 1, prev, Commit{Message: "recipe before revision history"}, prev.UpdatedAt); err != nil {
+            return recipe.Recipe{}, err
+        }
+        next = 2
+    }
+    if r.SchemaVersion == "" {
+        r.SchemaVersion = recipe.CurrentSchemaVersion
+    }
+    r.Revision = next
     r.UpdatedAt = time.Now()
+    if _, err := s.writeRevision(id, next, r, c, r.UpdatedAt); err != nil {
+        return recipe.Recipe{}, err
+    }
     data, err := json.MarshalIndent(r, "", "  ")
     if err != nil {
-        return err
+        return recipe.Recipe{}, err
     }
-    return os.WriteFile(filepath.Join(dir, "recipe.json"), data, 0o644)
+    if err := writeFileAtomic(filepath.Join(dir, "recipe.json"), data, 0o644); err != nil {
+        return recipe.Recipe{}, err
+    }
+    s.indexProject(id, r)
+    return r, nil
 }
 
 // CreateProject creates new project with default recipe.
@@ -95,36 +167,120 @@ func (s *Store) CreateProject(name, description string, targets []string) (recip
     if len(targets) > 0 {
         r.Project.Target = targets
     }
-    if err := s.SaveRecipe(r); err != nil {
-        return recipe.Recipe{}, err
-    }
-    return r, nil
+    return s.createRecipe(r, Commit{Message: "create project"})
 }
 
-// AssetList lists assets for project.
-func (s *Store) AssetList(id string) ([]os.DirEntry, error) {
-    return os.ReadDir(filepath.Join(s.Root, id, "assets"))
+// AssetNames lists the names of the project's uploaded assets.
+func (s *Store) AssetNames(id string) ([]string, error) {
+    metas, err := s.AssetMetas(id)
+    if err != nil {
+        return nil, err
+    }
+    names := []string{}
+    for _, m := range metas {
+        names = append(names, m.Filename)
+    }
+    return names, nil
 }
 
-// SaveAsset saves uploaded asset.
-func (s *Store) SaveAsset(id, filename string, src io.Reader) error {
-    if strings.Contains(filename, "..") {
-        return fmt.Errorf("invalid filename")
+// ValidationOptions collects the project context recipe.ValidateWith checks
+// against. Archive and RPM contents are looked up among the project's assets
+// and the library versions in pins, the recipe's Library.
+func (s *Store) ValidationOptions(id string, pins []recipe.LibraryRef) (recipe.Options, error) {
+    assets, err := s.AssetNames(id)
+    if err != nil {
+        return recipe.Options{}, err
     }
-    dir, err := s.EnsureProjectDir(id)
+    entries, err := s.loadLibrary()
     if err != nil {
-        return err
+        return recipe.Options{}, err
+    }
+    library := make([]recipe.LibraryItem, 0, len(entries))
+    for _, e := range entries {
+        library = append(library, e.LibraryItem)
     }
-    f, err := os.Create(filepath.Join(dir, "assets", filename))
+    base, err := s.basePackageLists()
     if err != nil {
+        return recipe.Options{}, err
+    }
+    pinned := pinnedEntries(entries, pins)
+    return recipe.Options{
+        Assets:  assets,
+        Library: library,
+        Archive: s.archiveLookup(id, pinned),
+        RPM:     s.rpmLookup(id, pinned),
+        Base:    base,
+    }, nil
+}
+
+// ReadAsset reads an asset file into memory.
+func (s *Store) ReadAsset(id, filename string) ([]byte, error) {
+    path, err := s.assetBlob(id, filename)
+    if err != nil {
+        return nil, err
+    }
+    return os.ReadFile(path)
+}
+
+// SaveAsset saves uploaded asset and records its metadata. The upload is
+// streamed to a hidden temp file in the blob store and only committed once
+// complete; content already stored is kept once. It fails with
+// ErrQuotaExceeded once it outgrows the project's quota.
+func (s *Store) SaveAsset(id, filename, uploader string, src io.Reader) (AssetMeta, error) {
+    if err := checkAssetName(filename); err != nil {
+        return AssetMeta{}, err
+    }
+    if err := s.projectExists(id); err != nil {
+        return AssetMeta{}, err
+    }
+    left, err := s.quotaLeft(id, filename)
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    h := newAssetHasher()
+    tmp, err := s.createBlobTemp(func(w io.Writer) error {
+        if left < 0 {
+            _, err := io.Copy(io.MultiWriter(w, h), src)
+            return err
+        }
+        n, err := io.Copy(io.MultiWriter(w, h), io.LimitReader(src, left+1))
+        if err == nil && n > left {
+            err = fmt.Errorf("%w: %s is larger than the %d bytes left", ErrQuotaExceeded, filename, left)
+        }
         return err
+    })
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    meta := h.meta(filename, uploader, time.Now())
+    defer s.lock(id)()
+    // the project may have been trashed while this upload streamed
+    if err := s.projectExists(id); err != nil {
+        os.Remove(tmp)
+        return AssetMeta{}, err
+    }
+    // other uploads may have landed while this one streamed
+    if left >= 0 {
+        if left, err = s.quotaLeft(id, filename); err != nil {
+            os.Remove(tmp)
+            return AssetMeta{}, err
+        }
+        if meta.Size > left {
+            os.Remove(tmp)
+            return AssetMeta{}, fmt.Errorf("%w: %s is larger than the %d bytes left", ErrQuotaExceeded, filename, left)
+        }
+    }
+    if err := s.storeAsset(id, tmp, meta); err != nil {
+        os.Remove(tmp)
+        return AssetMeta{}, err
     }
-    defer f.Close()
-    _, err = io.Copy(f, src)
-    return err
+    return meta, nil
 }
 
-// WriteBundle exports recipe and assets into target dir.
+// WriteBundle exports recipe and assets, including pinned library files,
+// into target dir. Assets are
+// reflinked or hardlinked out of the blob store where the filesystem
+// allows, and copied otherwise.
 func (s *Store) WriteBundle(r recipe.Recipe, targetDir string) error {
     if err := os.MkdirAll(filepath.Join(targetDir, "assets"), 0o755); err != nil {
         return err
@@ -133,40 +289,48 @@ func (s *Store) WriteBundle(r recipe.Recipe, targetDir string) error {
     if err != nil {
         return err
     }
-    if err := os.WriteFile(filepath.Join(targetDir, "recipe.json"), recipeData, 0o644); err != nil {
+    if err := writeFileAtomic(filepath.Join(targetDir, "recipe.json"), recipeData, 0o644); err != nil {
         return err
     }
-    // copy assets
-    assetsDir := filepath.Join(s.Root, r.Project.ID, "assets")
-    entries, _ := os.ReadDir(assetsDir)
-    for _, e := range entries {
-        if e.IsDir() {
-            continue
-        }
-        src := filepath.Join(assetsDir, e.Name())
-        dest := filepath.Join(targetDir, "assets", e.Name())
-        if err := copyFile(src, dest); err != nil {
+    metas, err := s.BundleAssets(r)
+    if err != nil {
+        return err
+    }
+    for _, m := range metas {
+        dest := filepath.Join(targetDir, "assets", m.Filename)
+        if err := LinkFile(s.blobPath(m.SHA256), dest); err != nil {
             return err
         }
     }
     return nil
 }
 
+// LinkFile places src at dest as a reflink, then a hardlink, then a copy,
+// whichever the filesystem supports first. Blobs are read-only, so a
+// hardlinked bundle file cannot be edited in place by accident.
+func LinkFile(src, dest string) error {
+    if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    if reflink(src, dest) == nil {
+        return nil
+    }
+    if os.Link(src, dest) == nil {
+        return nil
+    }
+    return copyFile(src, dest)
+}
+
 func copyFile(src, dest string) error {
     in, err := os.Open(src)
     if err != nil {
         return err
     }
     defer in.Close()
-    out, err := os.Create(dest)
-    if err != nil {
+    return writeAtomic(dest, 0o644, func(w io.Writer) error {
+        _, err := io.Copy(w, in)
         return err
-    }
-    defer out.Close()
-    if _, err := io.Copy(out, in); err != nil {
-        return err
-    }
-    return out.Close()
+    })
 }
 
 // ExportHandler is helper to send file download.
@@ -0,0 +1,47 @@
+package store
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "installforge/internal/recipe"
+)
+
+// newTestStore returns a store under a fresh data directory.
+func newTestStore(t *testing.T) *Store {
+    t.Helper()
+    return New(filepath.Join(t.TempDir(), "projects"))
+}
+
+// newTestProject creates a project holding the given assets, name to content.
+func newTestProject(t *testing.T, s *Store, assets map[string]string) recipe.Recipe {
+    t.Helper()
+    r, err := s.CreateProject("demo", "", []string{"oracle_linux_6_9"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range assets {
+        if _, err := s.SaveAsset(r.Project.ID, name, "test", strings.NewReader(content)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return r
+}
+
+func sum(content string) string {
+    h := sha256.Sum256([]byte(content))
+    return hex.EncodeToString(h[:])
+}
+
+// refcount returns the stored reference count of content's blob.
+func refcount(t *testing.T, s *Store, content string) int {
+    t.Helper()
+    refs, err := s.loadRefcounts()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return refs[sum(content)]
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+
+    "installforge/internal/recipe"
+)
+
+// targetsDir holds workspace additions to the built-in base package lists,
+// one <target>.txt per target, in the same format.
+func (s *Store) targetsDir() string {
+    return filepath.Join(filepath.Dir(s.Root), "targets")
+}
+
+// BasePackages returns what a target's base install provides: the built-in
+// list plus the workspace's additions.
+func (s *Store) BasePackages(target string) ([]string, error) {
+    caps := recipe.BasePackages(target)
+    data, err := os.ReadFile(filepath.Join(s.targetsDir(), target+".txt"))
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    caps = append(caps, recipe.ParseCapabilities(string(data))...)
+    return caps, nil
+}
+
+// basePackageLists collects BasePackages for the known targets and any
+// target the workspace has a list for.
+func (s *Store) basePackageLists() (map[string][]string, error) {
+    targets := append([]string(nil), recipe.KnownTargets...)
+    entries, err := os.ReadDir(s.targetsDir())
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    for _, e := range entries {
+        if t := strings.TrimSuffix(e.Name(), ".txt"); t != e.Name() && !e.IsDir() {
+            targets = append(targets, t)
+        }
+    }
+    lists := map[string][]string{}
+    for _, t := range targets {
+        caps, err := s.BasePackages(t)
+        if err != nil {
+            return nil, err
+        }
+        if len(caps) > 0 {
+            lists[t] = caps
+        }
+    }
+    return lists, nil
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+
+    "installforge/internal/recipe"
+)
+
+// ErrBuiltinTemplate is returned when deleting a template that ships with
+// the binary; only workspace templates can be removed.
+var ErrBuiltinTemplate = errors.New("built-in templates cannot be deleted")
+
+// ErrInvalidTemplateID is returned for template IDs that are not lowercase
+// letters, digits, dots, dashes and underscores.
+var ErrInvalidTemplateID = errors.New("invalid template id")
+
+var templateIDRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Template is a starter recipe new projects can be created from. Built-in
+// templates come from Store.Templates; workspace ones are <id>.json files
+// in data/templates and replace a built-in template of the same ID. Assets
+// lists the files its steps expect to be uploaded. Recipe is left out of
+// listings.
+type Template struct {
+    ID          string         `json:"id"`
+    Name        string         `json:"name"`
+    Description string         `json:"description"`
+    Builtin     bool           `json:"builtin"`
+    Assets      []string       `json:"assets"`
+    Recipe      *recipe.Recipe `json:"recipe,omitempty"`
+}
+
+// templateFile is the on-disk form of a template.
+type templateFile struct {
+    Name        string          `json:"name"`
+    Description string          `json:"description"`
+    Recipe      json.RawMessage `json:"recipe"`
+}
+
+// templatesDir holds the workspace's own templates.
+func (s *Store) templatesDir() string {
+    return filepath.Join(filepath.Dir(s.Root), "templates")
+}
+
+// parseTemplate reads a template file, migrating its recipe to the current schema.
+func parseTemplate(id string, data []byte, builtin bool) (Template, error) {
+    var f templateFile
+    if err := json.Unmarshal(data, &f); err != nil {
+        return Template{}, fmt.Errorf("template %s: %w", id, err)
+    }
+    r, _, err := recipe.Migrate(f.Recipe)
+    if err != nil {
+        return Template{}, fmt.Errorf("template %s: %w", id, err)
+    }
+    t := Template{ID: id, Name: f.Name, Description: f.Description, Builtin: builtin, Assets: []string{}, Recipe: &r}
+    if t.Name == "" {
+        t.Name = id
+    }
+    seen := map[string]bool{}
+    for _, ref := range recipe.AssetRefs(r) {
+        if !seen[ref.Name] {
+            seen[ref.Name] = true
+            t.Assets = append(t.Assets, ref.Name)
+        }
+    }
+    return t, nil
+}
+
+// loadTemplates reads every template, workspace ones replacing built-in ones.
+func (s *Store) loadTemplates() (map[string]Template, error) {
+    res := map[string]Template{}
+    if s.Templates != nil {
+        files, err := fs.Glob(s.Templates, "catalog/*.json")
+        if err != nil {
+            return nil, err
+        }
+        for _, name := range files {
+            data, err := fs.ReadFile(s.Templates, name)
+            if err != nil {
+                return nil, err
+            }
+            id := strings.TrimSuffix(path.Base(name), ".json")
+            t, err := parseTemplate(id, data, true)
+            if err != nil {
+                return nil, err
+            }
+            res[id] = t
+        }
+    }
+    entries, err := os.ReadDir(s.templatesDir())
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    for _, e := range entries {
+        id := strings.TrimSuffix(e.Name(), ".json")
+        if e.IsDir() || id == e.Name() || !templateIDRe.MatchString(id) {
+            continue
+        }
+        data, err := os.ReadFile(filepath.Join(s.templatesDir(), e.Name()))
+        if err != nil {
+            return nil, err
+        }
+        // a broken workspace file is skipped rather than hiding every template
+        t, err := parseTemplate(id, data, false)
+        if err != nil {
+            continue
+        }
+        res[id] = t
+    }
+    return res, nil
+}
+
+// ListTemplates lists the available templates, without their recipes, sorted by ID.
+func (s *Store) ListTemplates() ([]Template, error) {
+    all, err := s.loadTemplates()
+    if err != nil {
+        return nil, err
+    }
+    res := make([]Template, 0, len(all))
+    for _, t := range all {
+        t.Recipe = nil
+        res = append(res, t)
+    }
+    sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+    return res, nil
+}
+
+// Template returns one template with its recipe.
+func (s *Store) Template(id string) (Template, error) {
+    all, err := s.loadTemplates()
+    if err != nil {
+        return Template{}, err
+    }
+    t, ok := all[id]
+    if !ok {
+        return Template{}, os.ErrNotExist
+    }
+    return t, nil
+}
+
+// SaveTemplate stores r as the workspace template id, replacing an earlier
+// workspace template of that ID. The recipe's project ID is dropped.
+func (s *Store) SaveTemplate(id, name, description string, r recipe.Recipe) (Template, error) {
+    if !templateIDRe.MatchString(id) {
+        return Template{}, fmt.Errorf("%w %q", ErrInvalidTemplateID, id)
+    }
+    r.Project.ID = ""
+    r.Project.Archived = false
+    r.Revision = 0
+    raw, err := json.Marshal(r)
+    if err != nil {
+        return Template{}, err
+    }
+    data, err := json.MarshalIndent(templateFile{Name: name, Description: description, Recipe: raw}, "", "  ")
+    if err != nil {
+        return Template{}, err
+    }
+    if err := os.MkdirAll(s.templatesDir(), 0o755); err != nil {
+        return Template{}, err
+    }
+    if err := writeFileAtomic(filepath.Join(s.templatesDir(), id+".json"), data, 0o644); err != nil {
+        return Template{}, err
+    }
+    return parseTemplate(id, data, false)
+}
+
+// DeleteTemplate removes a workspace template; a built-in template it
+// replaced becomes visible again.
+func (s *Store) DeleteTemplate(id string) error {
+    if !templateIDRe.MatchString(id) {
+        return os.ErrNotExist
+    }
+    err := os.Remove(filepath.Join(s.templatesDir(), id+".json"))
+    if os.IsNotExist(err) {
+        if _, terr := s.Template(id); terr == nil {
+            return ErrBuiltinTemplate
+        }
+    }
+    return err
+}
+
+// CreateProjectFromTemplate creates a project from the template's recipe.
+// Non-empty name, description and targets replace the template's.
+func (s *Store) CreateProjectFromTemplate(template, name, description string, targets []string) (recipe.Recipe, error) {
+    t, err := s.Template(template)
+    if err != nil {
+        return recipe.Recipe{}, err
+    }
+    r := *t.Recipe
+    r.Project.ID = randomID()
+    if name != "" {
+        r.Project.Name = name
+    }
+    if description != "" {
+        r.Project.Description = description
+    }
+    if len(targets) > 0 {
+        r.Project.Target = targets
+    }
+    return s.createRecipe(r, Commit{Message: "create project from template " + template})
+}
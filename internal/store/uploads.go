@@ -0,0 +1,309 @@
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+var (
+    // ErrQuotaExceeded is returned when a write would take a project over Store.Quota.
+    ErrQuotaExceeded = errors.New("project asset quota exceeded")
+    // ErrChecksumMismatch is returned when a finalized upload does not match its sha256.
+    ErrChecksumMismatch = errors.New("sha256 does not match the uploaded data")
+)
+
+// OffsetError reports a chunk sent for an offset other than the upload's current one.
+type OffsetError struct {
+    Offset int64
+}
+
+func (e *OffsetError) Error() string {
+    return fmt.Sprintf("upload is at offset %d", e.Offset)
+}
+
+// Upload is a resumable upload session. Offset is the number of bytes
+// received so far; SHA256, if given at creation, is checked on finalize.
+type Upload struct {
+    ID        string    `json:"id"`
+    Filename  string    `json:"filename"`
+    Size      int64     `json:"size"`
+    Offset    int64     `json:"offset"`
+    SHA256    string    `json:"sha256,omitempty"`
+    Uploader  string    `json:"uploader"`
+    CreatedAt time.Time `json:"createdAt"`
+}
+
+func (s *Store) uploadDir(id string) string {
+    return filepath.Join(s.Root, id, "uploads")
+}
+
+func (s *Store) uploadPath(id, uid, ext string) string {
+    return filepath.Join(s.uploadDir(id), uid+ext)
+}
+
+// uploadLock serializes chunk writes of one upload without blocking the project.
+func (s *Store) uploadLock(id, uid string) func() {
+    return s.lock(filepath.Join(id, "uploads", uid))
+}
+
+// AssetUsage returns the bytes a project's assets take plus the declared size
+// of its open uploads.
+func (s *Store) AssetUsage(id string) (int64, error) {
+    var total int64
+    idx, err := s.loadAssetIndex(id)
+    if err != nil {
+        return 0, err
+    }
+    for _, m := range idx {
+        total += m.Size
+    }
+    uploads, err := s.ListUploads(id)
+    if err != nil {
+        return 0, err
+    }
+    for _, u := range uploads {
+        total += u.Size
+    }
+    return total, nil
+}
+
+// checkQuota fails if adding n bytes would take the project over its quota.
+func (s *Store) checkQuota(id string, n int64) error {
+    if s.Quota <= 0 {
+        return nil
+    }
+    used, err := s.AssetUsage(id)
+    if err != nil {
+        return err
+    }
+    if used+n > s.Quota {
+        return fmt.Errorf("%w: %d of %d bytes used, %d more requested", ErrQuotaExceeded, used, s.Quota, n)
+    }
+    return nil
+}
+
+// quotaLeft returns how many bytes an asset called filename may take, counting
+// the file it would replace as free, or -1 when there is no quota.
+func (s *Store) quotaLeft(id, filename string) (int64, error) {
+    if s.Quota <= 0 {
+        return -1, nil
+    }
+    used, err := s.AssetUsage(id)
+    if err != nil {
+        return 0, err
+    }
+    if idx, err := s.loadAssetIndex(id); err == nil {
+        used -= idx[filename].Size
+    }
+    if used >= s.Quota {
+        return 0, nil
+    }
+    return s.Quota - used, nil
+}
+
+// CreateUpload opens an upload session for an asset of the given size.
+func (s *Store) CreateUpload(id, filename string, size int64, sum, uploader string) (Upload, error) {
+    if err := checkAssetName(filename); err != nil {
+        return Upload{}, err
+    }
+    if size < 0 {
+        return Upload{}, fmt.Errorf("size must not be negative")
+    }
+    defer s.lock(id)()
+    if err := s.projectExists(id); err != nil {
+        return Upload{}, err
+    }
+    if err := s.checkQuota(id, size); err != nil {
+        return Upload{}, err
+    }
+    if err := os.MkdirAll(s.uploadDir(id), 0o755); err != nil {
+        return Upload{}, err
+    }
+    u := Upload{ID: randomID(), Filename: filename, Size: size, SHA256: strings.ToLower(sum), Uploader: uploader, CreatedAt: time.Now()}
+    if err := os.WriteFile(s.uploadPath(id, u.ID, ".part"), nil, 0o644); err != nil {
+        return Upload{}, err
+    }
+    data, err := json.MarshalIndent(u, "", "  ")
+    if err != nil {
+        return Upload{}, err
+    }
+    return u, writeFileAtomic(s.uploadPath(id, u.ID, ".json"), data, 0o644)
+}
+
+// LoadUpload reads an upload session; Offset reflects the bytes on disk.
+func (s *Store) LoadUpload(id, uid string) (Upload, error) {
+    if err := checkAssetName(uid); err != nil {
+        return Upload{}, os.ErrNotExist
+    }
+    data, err := os.ReadFile(s.uploadPath(id, uid, ".json"))
+    if err != nil {
+        return Upload{}, err
+    }
+    var u Upload
+    if err := json.Unmarshal(data, &u); err != nil {
+        return Upload{}, err
+    }
+    info, err := os.Stat(s.uploadPath(id, uid, ".part"))
+    if err != nil {
+        return Upload{}, err
+    }
+    u.Offset = info.Size()
+    return u, nil
+}
+
+// ListUploads lists the project's open upload sessions.
+func (s *Store) ListUploads(id string) ([]Upload, error) {
+    entries, err := os.ReadDir(s.uploadDir(id))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var res []Upload
+    for _, e := range entries {
+        uid := strings.TrimSuffix(e.Name(), ".json")
+        if uid == e.Name() || isTemp(e.Name()) {
+            continue
+        }
+        if u, err := s.LoadUpload(id, uid); err == nil {
+            res = append(res, u)
+        }
+    }
+    return res, nil
+}
+
+// WriteUploadChunk appends the chunk read from r at offset, which must be the
+// upload's current offset. Data past the declared size is rejected.
+func (s *Store) WriteUploadChunk(id, uid string, offset int64, r io.Reader) (Upload, error) {
+    defer s.uploadLock(id, uid)()
+    u, err := s.LoadUpload(id, uid)
+    if err != nil {
+        return Upload{}, err
+    }
+    if offset != u.Offset {
+        return u, &OffsetError{Offset: u.Offset}
+    }
+    f, err := os.OpenFile(s.uploadPath(id, uid, ".part"), os.O_WRONLY|os.O_APPEND, 0)
+    if err != nil {
+        return Upload{}, err
+    }
+    defer f.Close()
+    n, err := io.Copy(f, io.LimitReader(r, u.Size-u.Offset+1))
+    if err == nil && u.Offset+n > u.Size {
+        err = fmt.Errorf("chunk runs past the declared size of %d bytes", u.Size)
+    }
+    if err != nil {
+        // drop the partial chunk so the client can resend it from the same offset
+        f.Truncate(u.Offset)
+        return u, err
+    }
+    if err := f.Sync(); err != nil {
+        return Upload{}, err
+    }
+    u.Offset += n
+    return u, nil
+}
+
+// FinalizeUpload checks a complete upload against sum (or the checksum given
+// at creation) and moves it into the project's assets.
+func (s *Store) FinalizeUpload(id, uid, sum string) (AssetMeta, error) {
+    unlockUpload := s.uploadLock(id, uid)
+    defer unlockUpload()
+    u, err := s.LoadUpload(id, uid)
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    if u.Offset != u.Size {
+        return AssetMeta{}, &OffsetError{Offset: u.Offset}
+    }
+    want := strings.ToLower(sum)
+    if want == "" {
+        want = u.SHA256
+    }
+    if want == "" {
+        return AssetMeta{}, fmt.Errorf("sha256 is required to finalize an upload")
+    }
+    if u.SHA256 != "" && want != u.SHA256 {
+        return AssetMeta{}, ErrChecksumMismatch
+    }
+    part := s.uploadPath(id, uid, ".part")
+    f, err := os.Open(part)
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    h := newAssetHasher()
+    _, err = io.Copy(h, f)
+    f.Close()
+    if err != nil {
+        return AssetMeta{}, err
+    }
+    meta := h.meta(u.Filename, u.Uploader, time.Now())
+    if meta.SHA256 != want {
+        return AssetMeta{}, ErrChecksumMismatch
+    }
+
+    defer s.lock(id)()
+    if err := s.storeAsset(id, part, meta); err != nil {
+        return AssetMeta{}, err
+    }
+    os.Remove(s.uploadPath(id, uid, ".json"))
+    return meta, nil
+}
+
+// AbortUpload discards an upload session and the data received so far.
+func (s *Store) AbortUpload(id, uid string) error {
+    defer s.uploadLock(id, uid)()
+    if _, err := s.LoadUpload(id, uid); err != nil {
+        return err
+    }
+    os.Remove(s.uploadPath(id, uid, ".part"))
+    return os.Remove(s.uploadPath(id, uid, ".json"))
+}
+
+// expireUploads drops the project's upload sessions that have been idle
+// longer than s.UploadTTL, returning their IDs. With dryRun it only lists them.
+func (s *Store) expireUploads(id string, dryRun bool) ([]string, error) {
+    if s.UploadTTL <= 0 {
+        return nil, nil
+    }
+    uploads, err := s.ListUploads(id)
+    if err != nil {
+        return nil, err
+    }
+    var expired []string
+    for _, u := range uploads {
+        if !s.uploadIdle(id, u.ID) {
+            continue
+        }
+        expired = append(expired, u.ID)
+        if dryRun {
+            continue
+        }
+        unlock := s.uploadLock(id, u.ID)
+        // a chunk may have arrived since the listing
+        if s.uploadIdle(id, u.ID) {
+            os.Remove(s.uploadPath(id, u.ID, ".part"))
+            os.Remove(s.uploadPath(id, u.ID, ".json"))
+        }
+        unlock()
+    }
+    return expired, nil
+}
+
+// uploadIdle reports whether neither the session nor its data changed within s.UploadTTL.
+func (s *Store) uploadIdle(id, uid string) bool {
+    for _, ext := range []string{".json", ".part"} {
+        info, err := os.Stat(s.uploadPath(id, uid, ext))
+        if err == nil && time.Since(info.ModTime()) < s.UploadTTL {
+            return false
+        }
+    }
+    return true
+}
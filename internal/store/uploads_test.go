@@ -0,0 +1,159 @@
+package store
+
+import (
+    "errors"
+    "os"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestUploadFinalize(t *testing.T) {
+    s := newTestStore(t)
+    id := newTestProject(t, s, nil).Project.ID
+    content := "hello, world"
+    u, err := s.CreateUpload(id, "big.bin", int64(len(content)), strings.ToUpper(sum(content)), "test")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var offErr *OffsetError
+    if _, err := s.WriteUploadChunk(id, u.ID, 3, strings.NewReader("lo")); !errors.As(err, &offErr) || offErr.Offset != 0 {
+        t.Errorf("chunk at wrong offset: err = %v", err)
+    }
+    if u, err = s.WriteUploadChunk(id, u.ID, 0, strings.NewReader(content[:5])); err != nil || u.Offset != 5 {
+        t.Fatalf("first chunk: offset %d, err %v", u.Offset, err)
+    }
+    if _, err := s.FinalizeUpload(id, u.ID, ""); !errors.As(err, &offErr) || offErr.Offset != 5 {
+        t.Errorf("finalize incomplete upload: err = %v", err)
+    }
+    if _, err := s.WriteUploadChunk(id, u.ID, 5, strings.NewReader(content[5:]+"extra")); err == nil {
+        t.Error("chunk past the declared size accepted")
+    }
+    if u, err = s.LoadUpload(id, u.ID); err != nil || u.Offset != 5 {
+        t.Fatalf("offset after rejected chunk = %d, err %v; want 5", u.Offset, err)
+    }
+    if u, err = s.WriteUploadChunk(id, u.ID, 5, strings.NewReader(content[5:])); err != nil || u.Offset != u.Size {
+        t.Fatalf("last chunk: offset %d, err %v", u.Offset, err)
+    }
+
+    meta, err := s.FinalizeUpload(id, u.ID, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if meta.Filename != "big.bin" || meta.SHA256 != sum(content) || meta.Size != int64(len(content)) {
+        t.Errorf("meta = %+v", meta)
+    }
+    data, err := s.ReadAsset(id, "big.bin")
+    if err != nil || string(data) != content {
+        t.Errorf("asset = %q, %v", data, err)
+    }
+    if _, err := s.LoadUpload(id, u.ID); !os.IsNotExist(err) {
+        t.Errorf("session left after finalize: %v", err)
+    }
+    if n := refcount(t, s, content); n != 1 {
+        t.Errorf("refcount = %d, want 1", n)
+    }
+}
+
+func TestUploadChecksum(t *testing.T) {
+    s := newTestStore(t)
+    id := newTestProject(t, s, nil).Project.ID
+    tests := []struct {
+        name    string
+        created string // sha256 given at creation
+        final   string // sha256 given at finalize
+        ok      bool
+    }{
+        {"at creation", sum("data"), "", true},
+        {"at finalize", "", sum("data"), true},
+        {"both", sum("data"), sum("data"), true},
+        {"none", "", "", false},
+        {"wrong at creation", sum("other"), "", false},
+        {"wrong at finalize", "", sum("other"), false},
+        {"disagreeing", sum("data"), sum("other"), false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            u, err := s.CreateUpload(id, "f.bin", 4, tt.created, "test")
+            if err != nil {
+                t.Fatal(err)
+            }
+            if _, err := s.WriteUploadChunk(id, u.ID, 0, strings.NewReader("data")); err != nil {
+                t.Fatal(err)
+            }
+            _, err = s.FinalizeUpload(id, u.ID, tt.final)
+            if (err == nil) != tt.ok {
+                t.Fatalf("err = %v, want ok %v", err, tt.ok)
+            }
+            if !tt.ok {
+                // a failed finalize keeps the session for another try
+                if _, err := s.LoadUpload(id, u.ID); err != nil {
+                    t.Errorf("session gone after failed finalize: %v", err)
+                }
+                s.AbortUpload(id, u.ID)
+            }
+        })
+    }
+}
+
+func TestUploadQuota(t *testing.T) {
+    // open sessions reserve their declared size
+    s := newTestStore(t)
+    s.Quota = 10
+    id := newTestProject(t, s, map[string]string{"a.txt": "1234"}).Project.ID
+    if _, err := s.CreateUpload(id, "b.bin", 7, "", "test"); !errors.Is(err, ErrQuotaExceeded) {
+        t.Errorf("err = %v, want ErrQuotaExceeded", err)
+    }
+    u, err := s.CreateUpload(id, "b.bin", 6, "", "test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.CreateUpload(id, "c.bin", 1, "", "test"); !errors.Is(err, ErrQuotaExceeded) {
+        t.Errorf("err = %v, want ErrQuotaExceeded", err)
+    }
+    if err := s.AbortUpload(id, u.ID); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.CreateUpload(id, "c.bin", 6, "", "test"); err != nil {
+        t.Errorf("quota still reserved after abort: %v", err)
+    }
+}
+
+func TestUploadExpiry(t *testing.T) {
+    s := newTestStore(t)
+    s.UploadTTL = time.Hour
+    id := newTestProject(t, s, nil).Project.ID
+    idle, err := s.CreateUpload(id, "idle.bin", 4, "", "test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    active, err := s.CreateUpload(id, "active.bin", 4, "", "test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    old := time.Now().Add(-2 * time.Hour)
+    for _, ext := range []string{".json", ".part"} {
+        os.Chtimes(s.uploadPath(id, idle.ID, ext), old, old)
+    }
+    rep, err := s.GC(false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := id + "/" + idle.ID; len(rep.Uploads) != 1 || rep.Uploads[0] != want {
+        t.Errorf("expired = %v, want [%s]", rep.Uploads, want)
+    }
+    if _, err := s.LoadUpload(id, idle.ID); !os.IsNotExist(err) {
+        t.Errorf("idle session kept: %v", err)
+    }
+    if _, err := s.LoadUpload(id, active.ID); err != nil {
+        t.Errorf("active session dropped: %v", err)
+    }
+}
+
+func TestUploadMissingProject(t *testing.T) {
+    s := newTestStore(t)
+    if _, err := s.CreateUpload("nope", "a.bin", 1, "", "test"); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("err = %v, want os.ErrNotExist", err)
+    }
+}
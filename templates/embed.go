@@ -0,0 +1,7 @@
+package templates
+
+import "embed"
+
+// Catalog holds the built-in starter recipes, one catalog/<id>.json per template.
+//go:embed catalog/*.json
+var Catalog embed.FS